@@ -0,0 +1,74 @@
+package praetorian
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Environment variables which configure the callers allowed to use the admin
+// endpoints.
+const (
+	EnvAdminToken = "PRAETORIAN_ADMIN_TOKEN"
+	EnvAdminUIDs  = "PRAETORIAN_ADMIN_UIDS"
+)
+
+// AdminAuth identifies the callers allowed to use the admin endpoints. A
+// request is allowed when it carries Token as a bearer token, or when it was
+// received over a Unix domain socket from a process running as one of UIDs.
+// The admin endpoints are not served unless one of them is set.
+type AdminAuth struct {
+	Token string
+	UIDs  []uint32
+}
+
+// Enabled reports whether any admin callers are configured.
+func (a AdminAuth) Enabled() bool {
+	return a.Token != "" || len(a.UIDs) > 0
+}
+
+// AdminAuthFromEnv reads the admin token from PRAETORIAN_ADMIN_TOKEN and the
+// comma separated admin UIDs from PRAETORIAN_ADMIN_UIDS.
+func AdminAuthFromEnv() (AdminAuth, error) {
+	uids, err := parseIDs(os.Getenv(EnvAdminUIDs))
+	if err != nil {
+		return AdminAuth{}, fmt.Errorf("%s: %w", EnvAdminUIDs, err)
+	}
+	return AdminAuth{Token: os.Getenv(EnvAdminToken), UIDs: uids}, nil
+}
+
+// WithAdminAuth allows the given callers to use the admin endpoints.
+func WithAdminAuth(a AdminAuth) ServerOption {
+	return func(s *Server) {
+		s.adminAuth = a
+	}
+}
+
+// NewAdminAuth only passes requests from admin callers to next, answering
+// any other request with 401.
+func NewAdminAuth(a AdminAuth, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.allowed(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="praetorian admin"`)
+		jsonResponse(w, http.StatusUnauthorized, &ErrorResponse{
+			Message: "admin authentication required",
+		})
+	})
+}
+
+func (a AdminAuth) allowed(r *http.Request) bool {
+	if p, ok := PeerCredentialsFromContext(r.Context()); ok && slices.Contains(a.UIDs, p.UID) {
+		return true
+	}
+	if a.Token == "" {
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(a.Token)) == 1
+}
@@ -0,0 +1,137 @@
+package praetorian_test
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+func TestAdminAuth(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	admin := []praetorian.ServerOption{
+		praetorian.WithEndpoints(praetorian.EndpointAdmin),
+		praetorian.WithAdminAuth(praetorian.AdminAuth{Token: "s3cret"}),
+	}
+
+	tests := []struct {
+		name       string
+		opts       []praetorian.ServerOption
+		auth       string
+		wantStatus int
+	}{
+		{
+			name:       "valid token",
+			opts:       admin,
+			auth:       "Bearer s3cret",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "missing token",
+			opts:       admin,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "invalid token",
+			opts:       admin,
+			auth:       "Bearer s3cre",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "not a bearer token",
+			opts:       admin,
+			auth:       "s3cret",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "disabled by default",
+			opts:       []praetorian.ServerOption{praetorian.WithAdminAuth(praetorian.AdminAuth{Token: "s3cret"})},
+			auth:       "Bearer s3cret",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "enabled without admin callers",
+			opts:       []praetorian.ServerOption{praetorian.WithEndpoints(praetorian.EndpointAdmin)},
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := praetorian.NewServer(newTestKeystore(t, testConfig), tt.opts...).Handler
+			for _, path := range []string{"/v1/admin/deprecations", "/v1/admin/keys/import"} {
+				req := httptest.NewRequest(http.MethodGet, path, nil)
+				if tt.auth != "" {
+					req.Header.Set("Authorization", tt.auth)
+				}
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, req)
+				if rec.Code != tt.wantStatus {
+					t.Errorf("GET %s status = %d, wantStatus = %d", path, rec.Code, tt.wantStatus)
+				}
+			}
+		})
+	}
+}
+
+func TestAdminAuth_PeerUID(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials are only supported on linux")
+	}
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	tests := []struct {
+		name       string
+		uids       []uint32
+		wantStatus int
+	}{
+		{name: "admin uid", uids: []uint32{uint32(os.Getuid())}, wantStatus: http.StatusOK},
+		{name: "other uid", uids: []uint32{uint32(os.Getuid()) + 1}, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "praetorian.sock")
+			ln, err := praetorian.ListenUnix(&praetorian.SocketConfig{Path: path, UID: -1, GID: -1})
+			if err != nil {
+				t.Fatalf("ListenUnix() error = %v", err)
+			}
+			srv := praetorian.NewServer(newTestKeystore(t, testConfig),
+				praetorian.WithEndpoints(praetorian.EndpointAdmin),
+				praetorian.WithAdminAuth(praetorian.AdminAuth{UIDs: tt.uids}),
+			)
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() { done <- srv.Serve(ctx, ln) }()
+			defer func() {
+				cancel()
+				<-done
+			}()
+
+			client := &http.Client{Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", path)
+				},
+			}}
+			res, err := client.Get("http://praetorian/v1/admin/deprecations")
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			res.Body.Close()
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("GET /v1/admin/deprecations status = %d, wantStatus = %d", res.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package praetorian
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAnomalyWindow is the length of the sliding window in seconds.
+	DefaultAnomalyWindow = 60
+	// the learned baseline is only used after this many windows.
+	anomalyLearnWindows = 5
+	// weight given to the latest window when learning the baseline.
+	anomalyLearnRate = 0.1
+	// windows which have been idle for this long are forgotten.
+	anomalyIdle = time.Hour
+)
+
+// Anomaly scopes identify whether a caller or a key exceeded its baseline.
+const (
+	AnomalyScopeCaller = "caller"
+	AnomalyScopeKey    = "key"
+)
+
+// AnomalyDetection configures the unwrap anomaly detector. Callers and keys
+// are flagged when their unwraps within the sliding window exceed the
+// configured maximum, or Factor times the baseline learned from previous
+// windows. With Lockout, flagged callers are refused until an admin clears
+// them.
+type AnomalyDetection struct {
+	WindowSeconds    int     `json:"windowSeconds,omitempty"`
+	MaxCallerUnwraps int     `json:"maxCallerUnwraps,omitempty"`
+	MaxKeyUnwraps    int     `json:"maxKeyUnwraps,omitempty"`
+	Factor           float64 `json:"factor,omitempty"`
+	Lockout          bool    `json:"lockout,omitempty"`
+}
+
+// Enabled reports whether any threshold is configured.
+func (a AnomalyDetection) Enabled() bool {
+	return a.MaxCallerUnwraps > 0 || a.MaxKeyUnwraps > 0 || a.Factor > 0
+}
+
+func (a AnomalyDetection) validate() error {
+	if a.WindowSeconds < 0 || a.MaxCallerUnwraps < 0 || a.MaxKeyUnwraps < 0 {
+		return ErrInvalidAnomalyDetection
+	}
+	if a.Factor != 0 && (a.Factor <= 1 || math.IsInf(a.Factor, 0)) {
+		return ErrInvalidAnomalyDetection
+	}
+	return nil
+}
+
+// AnomalyEvent describes an unwrap rate which exceeded its threshold.
+type AnomalyEvent struct {
+	Time      time.Time `json:"time"`
+	Scope     string    `json:"scope"`
+	Caller    string    `json:"caller"`
+	KeyID     string    `json:"keyId"`
+	Rate      float64   `json:"rate"`
+	Threshold float64   `json:"threshold"`
+	LockedOut bool      `json:"lockedOut"`
+}
+
+// Lockout is a caller which is refused until it is cleared.
+type Lockout struct {
+	Caller string    `json:"caller"`
+	Since  time.Time `json:"since"`
+	KeyID  string    `json:"keyId"`
+}
+
+// AnomalyStats are counters of the events emitted by the detector.
+type AnomalyStats struct {
+	Unwraps   uint64    `json:"unwraps"`
+	Anomalies uint64    `json:"anomalies"`
+	Lockouts  []Lockout `json:"lockouts"`
+}
+
+// slidingWindow estimates the count over the last window from the current and
+// previous fixed windows, and learns a baseline from completed windows.
+type slidingWindow struct {
+	start    time.Time
+	count    float64
+	prev     float64
+	baseline float64
+	windows  int
+}
+
+func (w *slidingWindow) add(now time.Time, size time.Duration) float64 {
+	if w.start.IsZero() {
+		w.start = now
+	}
+	if elapsed := now.Sub(w.start); elapsed >= size {
+		n := int(elapsed / size)
+		w.learn(w.count)
+		w.prev = w.count
+		// windows without any unwraps also count towards the baseline.
+		for i := 1; i < n && i <= anomalyLearnWindows; i++ {
+			w.learn(0)
+			w.prev = 0
+		}
+		w.count = 0
+		w.start = w.start.Add(time.Duration(n) * size)
+	}
+	w.count++
+	overlap := 1 - float64(now.Sub(w.start))/float64(size)
+	return w.count + w.prev*overlap
+}
+
+func (w *slidingWindow) learn(count float64) {
+	if w.windows == 0 {
+		w.baseline = count
+	} else {
+		w.baseline += anomalyLearnRate * (count - w.baseline)
+	}
+	w.windows++
+}
+
+// AnomalyDetector tracks unwraps per caller and per key.
+type AnomalyDetector struct {
+	cfg     AnomalyDetection
+	window  time.Duration
+	onEvent func(AnomalyEvent)
+	now     func() time.Time
+
+	mu        sync.Mutex
+	callers   map[string]*slidingWindow
+	keys      map[string]*slidingWindow
+	lockouts  map[string]Lockout
+	unwraps   uint64
+	anomalies uint64
+	lastSweep time.Time
+}
+
+type anomalyDetectorKey struct{}
+
+// NewAnomalyDetector returns a detector which calls onEvent for every
+// anomaly, in addition to writing it to the audit log.
+func NewAnomalyDetector(cfg AnomalyDetection, onEvent func(AnomalyEvent)) *AnomalyDetector {
+	window := cfg.WindowSeconds
+	if window == 0 {
+		window = DefaultAnomalyWindow
+	}
+	return &AnomalyDetector{
+		cfg:      cfg,
+		window:   time.Duration(window) * time.Second,
+		onEvent:  onEvent,
+		now:      time.Now,
+		callers:  make(map[string]*slidingWindow),
+		keys:     make(map[string]*slidingWindow),
+		lockouts: make(map[string]Lockout),
+	}
+}
+
+// Middleware makes the detector available to the unwrap handlers, which
+// refuse callers that are locked out. Other routes, such as the health checks
+// and the admin endpoints which clear lockouts, are still served.
+func (d *AnomalyDetector) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), anomalyDetectorKey{}, d)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Observe records an unwrap of the key by the caller, returning
+// ErrCallerLockedOut when the caller is, or has just been, locked out.
+func (d *AnomalyDetector) Observe(caller, keyID string) error {
+	d.mu.Lock()
+	if _, ok := d.lockouts[caller]; ok {
+		d.mu.Unlock()
+		return ErrCallerLockedOut
+	}
+	d.unwraps++
+	now := d.now()
+	d.sweep(now)
+
+	var events []AnomalyEvent
+	if ev, ok := d.check(d.callers, caller, now, d.cfg.MaxCallerUnwraps); ok {
+		ev.Scope, ev.Caller, ev.KeyID = AnomalyScopeCaller, caller, keyID
+		events = append(events, ev)
+	}
+	if ev, ok := d.check(d.keys, keyID, now, d.cfg.MaxKeyUnwraps); ok {
+		ev.Scope, ev.Caller, ev.KeyID = AnomalyScopeKey, caller, keyID
+		events = append(events, ev)
+	}
+	locked := len(events) > 0 && d.cfg.Lockout
+	if locked {
+		d.lockouts[caller] = Lockout{Caller: caller, Since: now, KeyID: keyID}
+	}
+	d.anomalies += uint64(len(events))
+	d.mu.Unlock()
+
+	for _, ev := range events {
+		ev.LockedOut = locked
+		log.Printf("audit: unwrap anomaly scope=%s caller=%s key=%s rate=%.1f threshold=%.1f locked=%t\n",
+			ev.Scope, ev.Caller, ev.KeyID, ev.Rate, ev.Threshold, ev.LockedOut)
+		if d.onEvent != nil {
+			d.onEvent(ev)
+		}
+	}
+	if locked {
+		return ErrCallerLockedOut
+	}
+	return nil
+}
+
+// check adds an unwrap to the window and reports whether its rate is above
+// the configured maximum or the learned baseline.
+func (d *AnomalyDetector) check(windows map[string]*slidingWindow, id string, now time.Time, maximum int) (AnomalyEvent, bool) {
+	w, ok := windows[id]
+	if !ok {
+		w = &slidingWindow{}
+		windows[id] = w
+	}
+	rate := w.add(now, d.window)
+
+	if maximum > 0 && rate > float64(maximum) {
+		return AnomalyEvent{Time: now, Rate: rate, Threshold: float64(maximum)}, true
+	}
+	if d.cfg.Factor > 0 && w.windows >= anomalyLearnWindows {
+		threshold := d.cfg.Factor * math.Max(w.baseline, 1)
+		if rate > threshold {
+			return AnomalyEvent{Time: now, Rate: rate, Threshold: threshold}, true
+		}
+	}
+	return AnomalyEvent{}, false
+}
+
+// sweep forgets callers and keys which have not been unwrapped recently.
+func (d *AnomalyDetector) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < d.window {
+		return
+	}
+	d.lastSweep = now
+	for _, windows := range []map[string]*slidingWindow{d.callers, d.keys} {
+		for id, w := range windows {
+			if now.Sub(w.start) > anomalyIdle {
+				delete(windows, id)
+			}
+		}
+	}
+}
+
+// Clear removes the lockout of a caller, reporting whether it was locked.
+func (d *AnomalyDetector) Clear(caller string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, ok := d.lockouts[caller]
+	delete(d.lockouts, caller)
+	// the caller starts again with the baseline it had learned.
+	if w, exists := d.callers[caller]; exists {
+		w.count, w.prev = 0, 0
+	}
+	if ok {
+		log.Printf("audit: unwrap lockout cleared caller=%s\n", caller)
+	}
+	return ok
+}
+
+// Stats returns the counters and current lockouts of the detector.
+func (d *AnomalyDetector) Stats() AnomalyStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stats := AnomalyStats{Unwraps: d.unwraps, Anomalies: d.anomalies, Lockouts: []Lockout{}}
+	for _, l := range d.lockouts {
+		stats.Lockouts = append(stats.Lockouts, l)
+	}
+	sort.Slice(stats.Lockouts, func(i, j int) bool {
+		return stats.Lockouts[i].Since.Before(stats.Lockouts[j].Since)
+	})
+	return stats
+}
+
+// observeUnwrap records an unwrap with the detector of the request, if any.
+func observeUnwrap(r *http.Request, keyID string) error {
+	d, ok := r.Context().Value(anomalyDetectorKey{}).(*AnomalyDetector)
+	if !ok {
+		return nil
+	}
+	return d.Observe(caller(r), keyID)
+}
@@ -0,0 +1,120 @@
+package praetorian_test
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+func TestAnomalyDetector_Observe(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	tests := []struct {
+		name       string
+		cfg        praetorian.AnomalyDetection
+		unwraps    [][2]string // caller and key pairs
+		wantScope  string
+		wantLocked bool
+	}{
+		{
+			name:    "below thresholds",
+			cfg:     praetorian.AnomalyDetection{MaxCallerUnwraps: 3, MaxKeyUnwraps: 3},
+			unwraps: [][2]string{{"10.0.0.1", "1"}, {"10.0.0.1", "1"}, {"10.0.0.2", "1"}},
+		},
+		{
+			name:      "caller threshold",
+			cfg:       praetorian.AnomalyDetection{MaxCallerUnwraps: 2},
+			unwraps:   [][2]string{{"10.0.0.1", "1"}, {"10.0.0.1", "2"}, {"10.0.0.1", "3"}},
+			wantScope: praetorian.AnomalyScopeCaller,
+		},
+		{
+			name:      "key threshold",
+			cfg:       praetorian.AnomalyDetection{MaxKeyUnwraps: 2},
+			unwraps:   [][2]string{{"10.0.0.1", "1"}, {"10.0.0.2", "1"}, {"10.0.0.3", "1"}},
+			wantScope: praetorian.AnomalyScopeKey,
+		},
+		{
+			name:       "lockout",
+			cfg:        praetorian.AnomalyDetection{MaxCallerUnwraps: 1, Lockout: true},
+			unwraps:    [][2]string{{"10.0.0.1", "1"}, {"10.0.0.1", "1"}},
+			wantScope:  praetorian.AnomalyScopeCaller,
+			wantLocked: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var events []praetorian.AnomalyEvent
+			d := praetorian.NewAnomalyDetector(tt.cfg, func(ev praetorian.AnomalyEvent) {
+				events = append(events, ev)
+			})
+
+			var err error
+			for _, u := range tt.unwraps {
+				err = d.Observe(u[0], u[1])
+			}
+
+			if tt.wantScope == "" {
+				if len(events) != 0 {
+					t.Errorf("Observe() events = %+v, want none", events)
+				}
+				return
+			}
+			if len(events) != 1 || events[0].Scope != tt.wantScope {
+				t.Fatalf("Observe() events = %+v, want one %s event", events, tt.wantScope)
+			}
+			if events[0].LockedOut != tt.wantLocked {
+				t.Errorf("Observe() locked out = %v, want %v", events[0].LockedOut, tt.wantLocked)
+			}
+			if gotLocked := errors.Is(err, praetorian.ErrCallerLockedOut); gotLocked != tt.wantLocked {
+				t.Errorf("Observe() error = %v, wantLocked = %v", err, tt.wantLocked)
+			}
+		})
+	}
+}
+
+func TestAnomalyDetector_Middleware(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	ks := newTestKeystore(t, testConfig)
+	d := praetorian.NewAnomalyDetector(praetorian.AnomalyDetection{MaxCallerUnwraps: 1, Lockout: true}, nil)
+	wrap := d.Middleware(praetorian.HandleWrap(praetorian.ActiveKeyID, ks))
+	unwrap := d.Middleware(praetorian.HandleUnwrap(ks))
+
+	request := func(h http.Handler, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.RemoteAddr = "10.0.0.1:1234"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	wrapped := request(wrap, "/wrap", `{"key": "secret"}`).Body.String()
+	wantStatus := []int{http.StatusOK, http.StatusForbidden, http.StatusForbidden}
+	for i, want := range wantStatus {
+		if rec := request(unwrap, "/unwrap", wrapped); rec.Code != want {
+			t.Errorf("unwrap %d status = %d, want %d", i, rec.Code, want)
+		}
+	}
+
+	// locked out callers are only refused unwraps.
+	if rec := request(wrap, "/wrap", `{"key": "secret"}`); rec.Code != http.StatusCreated {
+		t.Errorf("wrap status = %d, want %d", rec.Code, http.StatusCreated)
+	}
+
+	if !d.Clear("10.0.0.1") {
+		t.Fatal("Clear() = false, want true")
+	}
+	if rec := request(unwrap, "/unwrap", wrapped); rec.Code != http.StatusOK {
+		t.Errorf("unwrap after Clear() status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,153 @@
+package client
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/karlbateman/praetorian"
+)
+
+const (
+	DefaultCacheTTL        = 5 * time.Minute
+	DefaultCacheMaxEntries = 1000
+)
+
+// CacheOptions bounds how long and how often an unwrapped key may be reused.
+// MaxUses includes the unwrap which filled the cache, and a zero MaxUses allows
+// unlimited uses until the entry expires.
+type CacheOptions struct {
+	TTL        time.Duration
+	MaxEntries int
+	MaxUses    int
+}
+
+// CacheStats reports the effectiveness of the unwrap cache.
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+}
+
+type cacheEntry struct {
+	key     [sha256.Size]byte
+	value   []byte
+	expires time.Time
+	uses    int
+}
+
+// cache holds unwrapped responses in least recently used order. Values are
+// zeroed as soon as they are evicted.
+type cache struct {
+	mu      sync.Mutex
+	opts    CacheOptions
+	entries map[[sha256.Size]byte]*list.Element
+	order   *list.List
+	stats   CacheStats
+}
+
+func newCache(opts CacheOptions) *cache {
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultCacheTTL
+	}
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = DefaultCacheMaxEntries
+	}
+	return &cache{
+		opts:    opts,
+		entries: make(map[[sha256.Size]byte]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// cacheKey hashes the wrapped key so tokens are not retained in memory.
+func cacheKey(w *praetorian.WrapResponse) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write([]byte(w.ID))
+	h.Write([]byte{0})
+	h.Write([]byte(w.Token))
+
+	var k [sha256.Size]byte
+	h.Sum(k[:0])
+	return k
+}
+
+// get returns a copy of the cached value which the caller must clear.
+func (c *cache) get(k [sha256.Size]byte) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[k]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if time.Now().After(e.expires) {
+		c.remove(el)
+		c.stats.Misses++
+		return nil, false
+	}
+
+	c.stats.Hits++
+	e.uses++
+	v := append([]byte(nil), e.value...)
+	if c.opts.MaxUses > 0 && e.uses >= c.opts.MaxUses {
+		c.remove(el)
+	} else {
+		c.order.MoveToFront(el)
+	}
+	return v, true
+}
+
+// put stores a value which has just been fetched. The fetch counts as the
+// first use, so a value allowed a single use is not stored at all.
+func (c *cache) put(k [sha256.Size]byte, v []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[k]; ok {
+		c.remove(el)
+	}
+	if c.opts.MaxUses == 1 {
+		return
+	}
+	e := &cacheEntry{
+		key:     k,
+		value:   append([]byte(nil), v...),
+		expires: time.Now().Add(c.opts.TTL),
+		uses:    1,
+	}
+	c.entries[k] = c.order.PushFront(e)
+
+	for c.order.Len() > c.opts.MaxEntries {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *cache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for c.order.Len() > 0 {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *cache) snapshot() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.stats
+	s.Entries = c.order.Len()
+	return s
+}
+
+func (c *cache) remove(el *list.Element) {
+	e := c.order.Remove(el).(*cacheEntry)
+	delete(c.entries, e.key)
+	clear(e.value)
+	c.stats.Evictions++
+}
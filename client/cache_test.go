@@ -0,0 +1,172 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/karlbateman/praetorian"
+	"github.com/karlbateman/praetorian/client"
+)
+
+func newCountingServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	srv := newTestServer(t)
+
+	var unwraps atomic.Int32
+	counting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/unwrap" {
+			unwraps.Add(1)
+		}
+		srv.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(counting.Close)
+	return counting, &unwraps
+}
+
+func TestClient_Cache(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        client.CacheOptions
+		calls       int
+		delay       time.Duration
+		wantUnwraps int32
+		wantStats   client.CacheStats
+	}{
+		{
+			name:        "hits within TTL",
+			opts:        client.CacheOptions{TTL: time.Minute},
+			calls:       3,
+			wantUnwraps: 1,
+			wantStats:   client.CacheStats{Hits: 2, Misses: 1, Entries: 1},
+		},
+		{
+			name:        "max uses",
+			opts:        client.CacheOptions{TTL: time.Minute, MaxUses: 2},
+			calls:       4,
+			wantUnwraps: 2,
+			wantStats:   client.CacheStats{Hits: 2, Misses: 2, Evictions: 2},
+		},
+		{
+			name:        "expired entries",
+			opts:        client.CacheOptions{TTL: 10 * time.Millisecond},
+			calls:       2,
+			delay:       20 * time.Millisecond,
+			wantUnwraps: 2,
+			wantStats:   client.CacheStats{Misses: 2, Evictions: 1, Entries: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, unwraps := newCountingServer(t)
+			c := client.New(srv.URL, client.WithCache(tt.opts))
+			ctx := context.Background()
+
+			w, err := c.WrapKey(ctx, []byte("0123456789abcdef0123456789abcdef"))
+			if err != nil {
+				t.Fatalf("Client.WrapKey() failed to wrap key: %v", err)
+			}
+
+			for range tt.calls {
+				dek, err := c.UnwrapKey(ctx, w)
+				if err != nil {
+					t.Fatalf("Client.UnwrapKey() failed to unwrap key: %v", err)
+				}
+				if string(dek) != "0123456789abcdef0123456789abcdef" {
+					t.Errorf("Client.UnwrapKey() got = %q", dek)
+				}
+				time.Sleep(tt.delay)
+			}
+
+			if got := unwraps.Load(); got != tt.wantUnwraps {
+				t.Errorf("Client.UnwrapKey() unwraps = %d, wantUnwraps = %d", got, tt.wantUnwraps)
+			}
+			if got := c.CacheStats(); got != tt.wantStats {
+				t.Errorf("Client.CacheStats() got = %+v, wantStats = %+v", got, tt.wantStats)
+			}
+		})
+	}
+}
+
+func TestClient_CacheMaxUses(t *testing.T) {
+	for _, maxUses := range []int{1, 2, 3} {
+		t.Run(fmt.Sprintf("%d uses", maxUses), func(t *testing.T) {
+			srv, unwraps := newCountingServer(t)
+			c := client.New(srv.URL, client.WithCache(client.CacheOptions{TTL: time.Minute, MaxUses: maxUses}))
+			ctx := context.Background()
+
+			w, err := c.WrapKey(ctx, []byte("0123456789abcdef0123456789abcdef"))
+			if err != nil {
+				t.Fatalf("Client.WrapKey() failed to wrap key: %v", err)
+			}
+
+			// the key is fetched again after exactly maxUses uses.
+			for i := range 3 * maxUses {
+				if _, err := c.UnwrapKey(ctx, w); err != nil {
+					t.Fatalf("Client.UnwrapKey() failed to unwrap key: %v", err)
+				}
+				if got, want := unwraps.Load(), int32(i/maxUses+1); got != want {
+					t.Fatalf("Client.UnwrapKey() unwraps = %d after %d uses, want = %d", got, i+1, want)
+				}
+			}
+		})
+	}
+}
+
+func TestClient_CacheMaxEntries(t *testing.T) {
+	srv, unwraps := newCountingServer(t)
+	c := client.New(srv.URL, client.WithCache(client.CacheOptions{MaxEntries: 1}))
+	ctx := context.Background()
+
+	first, err := c.WrapKey(ctx, []byte("first"))
+	if err != nil {
+		t.Fatalf("Client.WrapKey() failed to wrap key: %v", err)
+	}
+	second, err := c.WrapKey(ctx, []byte("second"))
+	if err != nil {
+		t.Fatalf("Client.WrapKey() failed to wrap key: %v", err)
+	}
+
+	for _, w := range []*praetorian.WrapResponse{first, second, first} {
+		if _, err := c.UnwrapKey(ctx, w); err != nil {
+			t.Fatalf("Client.UnwrapKey() failed to unwrap key: %v", err)
+		}
+	}
+
+	if got := unwraps.Load(); got != 3 {
+		t.Errorf("Client.UnwrapKey() unwraps = %d, want = 3", got)
+	}
+
+	c.PurgeCache()
+	if got := c.CacheStats(); got.Entries != 0 || got.Evictions != 3 {
+		t.Errorf("Client.CacheStats() got = %+v, want no entries and 3 evictions", got)
+	}
+}
+
+func TestClient_CacheDisabled(t *testing.T) {
+	srv, unwraps := newCountingServer(t)
+	c := client.New(srv.URL)
+	ctx := context.Background()
+
+	w, err := c.WrapKey(ctx, []byte("key"))
+	if err != nil {
+		t.Fatalf("Client.WrapKey() failed to wrap key: %v", err)
+	}
+	for range 2 {
+		if _, err := c.UnwrapKey(ctx, w); err != nil {
+			t.Fatalf("Client.UnwrapKey() failed to unwrap key: %v", err)
+		}
+	}
+
+	if got := unwraps.Load(); got != 2 {
+		t.Errorf("Client.UnwrapKey() unwraps = %d, want = 2", got)
+	}
+	if got := c.CacheStats(); got != (client.CacheStats{}) {
+		t.Errorf("Client.CacheStats() got = %+v, want empty", got)
+	}
+}
@@ -0,0 +1,252 @@
+// Package client provides a typed HTTP client for the Praetorian service.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/karlbateman/praetorian"
+)
+
+const (
+	DefaultTimeout = 10 * time.Second
+	DefaultRetries = 2
+	DefaultBackoff = 100 * time.Millisecond
+)
+
+// ErrUnavailable is matched by a 503 Service Unavailable response which does
+// not report a sealed keystore, such as a request timeout or a server that is
+// shutting down.
+var ErrUnavailable = errors.New("praetorian: service unavailable")
+
+// sentinel errors which the server reports by message.
+var sentinels = []error{
+	praetorian.ErrRootKeyNotFound,
+	praetorian.ErrKeystoreSealed,
+	praetorian.ErrNewCipherBlock,
+	praetorian.ErrNewGCMWithRandomNonce,
+	praetorian.ErrGCMOpen,
+}
+
+// Error is returned when the server responds with an error status.
+type Error struct {
+	StatusCode int
+	Message    string
+	Err        error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("praetorian: %d %s", e.StatusCode, e.Message)
+}
+
+// Unwrap returns the matching sentinel error from the praetorian package.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Client calls the wrap and unwrap endpoints of a Praetorian server.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	retries    int
+	backoff    time.Duration
+	cache      *cache
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient replaces the underlying HTTP client.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithTimeout sets the timeout applied to each attempt.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		hc := *c.httpClient
+		hc.Timeout = d
+		c.httpClient = &hc
+	}
+}
+
+// WithRetries sets how many times a request is retried after a server error
+// and the initial delay, which doubles after every attempt.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = n
+		c.backoff = backoff
+	}
+}
+
+// WithCache enables an in-memory cache of unwrapped keys. Each cached key
+// widens the window in which it is exposed in process memory, so the options
+// should be as strict as the workload allows.
+func WithCache(opts CacheOptions) Option {
+	return func(c *Client) {
+		c.cache = newCache(opts)
+	}
+}
+
+// New returns a client for the Praetorian server at baseURL.
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: DefaultTimeout},
+		retries:    DefaultRetries,
+		backoff:    DefaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Wrap encrypts the JSON encoding of key with the active root key.
+func (c *Client) Wrap(key any) (*praetorian.WrapResponse, error) {
+	return c.WrapContext(context.Background(), key)
+}
+
+// WrapContext encrypts the JSON encoding of key with the active root key.
+func (c *Client) WrapContext(ctx context.Context, key any) (*praetorian.WrapResponse, error) {
+	var res praetorian.WrapResponse
+	if err := c.post(ctx, "/v1/wrap", key, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Unwrap decrypts a wrapped key and decodes the result into v.
+func (c *Client) Unwrap(w *praetorian.WrapResponse, v any) error {
+	return c.UnwrapContext(context.Background(), w, v)
+}
+
+// UnwrapContext decrypts a wrapped key and decodes the result into v. When the
+// cache is enabled, repeated calls for the same wrapped key are served locally.
+func (c *Client) UnwrapContext(ctx context.Context, w *praetorian.WrapResponse, v any) error {
+	if c.cache == nil {
+		return c.post(ctx, "/v1/unwrap", w, v)
+	}
+
+	k := cacheKey(w)
+	raw, ok := c.cache.get(k)
+	if !ok {
+		var res json.RawMessage
+		if err := c.post(ctx, "/v1/unwrap", w, &res); err != nil {
+			return err
+		}
+		raw = res
+		c.cache.put(k, raw)
+	}
+	defer clear(raw)
+	return json.Unmarshal(raw, v)
+}
+
+// CacheStats returns the unwrap cache statistics, which are empty when the
+// cache is disabled.
+func (c *Client) CacheStats() CacheStats {
+	if c.cache == nil {
+		return CacheStats{}
+	}
+	return c.cache.snapshot()
+}
+
+// PurgeCache evicts and zeroes every cached key.
+func (c *Client) PurgeCache() {
+	if c.cache != nil {
+		c.cache.purge()
+	}
+}
+
+// WrapKey wraps a raw data encryption key.
+func (c *Client) WrapKey(ctx context.Context, dek []byte) (*praetorian.WrapResponse, error) {
+	return c.WrapContext(ctx, &praetorian.DataKey{Key: dek})
+}
+
+// UnwrapKey returns the raw data encryption key wrapped by WrapKey.
+func (c *Client) UnwrapKey(ctx context.Context, w *praetorian.WrapResponse) ([]byte, error) {
+	var dk praetorian.DataKey
+	if err := c.UnwrapContext(ctx, w, &dk); err != nil {
+		return nil, err
+	}
+	return dk.Key, nil
+}
+
+func (c *Client) post(ctx context.Context, path string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	delay := c.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := c.do(ctx, path, body, out)
+		if !retry || attempt >= c.retries || ctx.Err() != nil {
+			return err
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		delay *= 2
+	}
+}
+
+// do performs a single attempt and reports whether a failure may be retried,
+// which is the case for transport errors and server errors.
+func (c *Client) do(ctx context.Context, path string, body []byte, out any) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		retry := res.StatusCode >= http.StatusInternalServerError && res.StatusCode != http.StatusNotImplemented
+		return retry, decodeError(res)
+	}
+	return false, json.NewDecoder(res.Body).Decode(out)
+}
+
+func decodeError(res *http.Response) error {
+	e := &Error{StatusCode: res.StatusCode, Message: http.StatusText(res.StatusCode)}
+
+	var body praetorian.ErrorResponse
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 1<<16))
+	if json.Unmarshal(b, &body) == nil && body.Message != "" {
+		e.Message = body.Message
+	}
+
+	for _, s := range sentinels {
+		if s.Error() == e.Message {
+			e.Err = s
+		}
+	}
+	switch {
+	case e.Err != nil:
+	case res.StatusCode == http.StatusUnprocessableEntity:
+		e.Err = praetorian.ErrGCMOpen
+	case res.StatusCode == http.StatusServiceUnavailable:
+		e.Err = ErrUnavailable
+	}
+	return e
+}
@@ -0,0 +1,194 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/karlbateman/praetorian"
+	"github.com/karlbateman/praetorian/client"
+)
+
+const (
+	testConfig = `{"activeKeyId": "1", "rootKeys": {"1": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}}`
+)
+
+type dataKey struct {
+	Key string `json:"key"`
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	t.Setenv(praetorian.EnvKey, testConfig)
+	cfg, err := praetorian.NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig() failed to create config: %v", err)
+	}
+	ks, err := praetorian.NewKeystore(cfg)
+	if err != nil {
+		t.Fatalf("NewKeystore() failed to create keystore: %v", err)
+	}
+	srv := httptest.NewServer(praetorian.NewServer(ks).Handler)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClient_WrapUnwrap(t *testing.T) {
+	srv := newTestServer(t)
+	c := client.New(srv.URL)
+
+	w, err := c.Wrap(&dataKey{Key: "abc123"})
+	if err != nil {
+		t.Fatalf("Client.Wrap() failed to wrap key: %v", err)
+	}
+	if w.ID != "1" || w.Token == "" {
+		t.Errorf("Client.Wrap() got = %+v", w)
+	}
+
+	var got dataKey
+	if err := c.UnwrapContext(context.Background(), w, &got); err != nil {
+		t.Fatalf("Client.Unwrap() failed to unwrap key: %v", err)
+	}
+	if got.Key != "abc123" {
+		t.Errorf("Client.Unwrap() got = %q, want = %q", got.Key, "abc123")
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		wrapped    *praetorian.WrapResponse
+		wantStatus int
+		wantErr    error
+	}{
+		{
+			name:       "root key not found",
+			wrapped:    &praetorian.WrapResponse{ID: "2", Token: "ZW5jcnlwdGVk"},
+			wantStatus: http.StatusNotFound,
+			wantErr:    praetorian.ErrRootKeyNotFound,
+		},
+		{
+			name:       "data authentication failed",
+			wrapped:    &praetorian.WrapResponse{ID: "1", Token: "ZW5jcnlwdGVkIG1lc3NhZ2UgdGhhdCBpcyBsb25n"},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantErr:    praetorian.ErrGCMOpen,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t)
+			c := client.New(srv.URL)
+
+			var got dataKey
+			err := c.Unwrap(tt.wrapped, &got)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Client.Unwrap() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+
+			var ce *client.Error
+			if !errors.As(err, &ce) || ce.StatusCode != tt.wantStatus {
+				t.Errorf("Client.Unwrap() error = %v, wantStatus = %d", err, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestClient_Unavailable(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		wantErr error
+	}{
+		{
+			name:    "keystore sealed",
+			message: praetorian.ErrKeystoreSealed.Error(),
+			wantErr: praetorian.ErrKeystoreSealed,
+		},
+		{
+			name:    "request timeout",
+			message: context.DeadlineExceeded.Error(),
+			wantErr: client.ErrUnavailable,
+		},
+		{
+			name:    "no message",
+			wantErr: client.ErrUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				_ = json.NewEncoder(w).Encode(praetorian.ErrorResponse{Message: tt.message})
+			}))
+			defer srv.Close()
+
+			c := client.New(srv.URL, client.WithRetries(0, 0))
+			_, err := c.Wrap(&dataKey{Key: "abc123"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Client.Wrap() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+			if tt.wantErr != praetorian.ErrKeystoreSealed && errors.Is(err, praetorian.ErrKeystoreSealed) {
+				t.Errorf("Client.Wrap() error = %v, reported as sealed", err)
+			}
+		})
+	}
+}
+
+func TestClient_Retries(t *testing.T) {
+	srv := newTestServer(t)
+
+	var calls atomic.Int32
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		srv.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer flaky.Close()
+
+	c := client.New(flaky.URL, client.WithRetries(2, time.Millisecond))
+	if _, err := c.Wrap(&dataKey{Key: "abc123"}); err != nil {
+		t.Fatalf("Client.Wrap() failed after retries: %v", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("Client.Wrap() calls = %d, want = 3", got)
+	}
+
+	calls.Store(0)
+	c = client.New(flaky.URL, client.WithRetries(1, time.Millisecond))
+	_, err := c.Wrap(&dataKey{Key: "abc123"})
+	if !errors.Is(err, client.ErrUnavailable) {
+		t.Errorf("Client.Wrap() error = %v, wantErr = %v", err, client.ErrUnavailable)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("Client.Wrap() calls = %d, want = 2", got)
+	}
+}
+
+func TestClient_Timeout(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	c := client.New(slow.URL, client.WithTimeout(10*time.Millisecond), client.WithRetries(0, 0))
+	if _, err := c.Wrap(&dataKey{Key: "abc123"}); err == nil {
+		t.Errorf("Client.Wrap() error = nil, want timeout")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c = client.New(slow.URL)
+	if _, err := c.WrapContext(ctx, &dataKey{Key: "abc123"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Client.WrapContext() error = %v, wantErr = %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/karlbateman/praetorian"
+)
+
+// configData reads the config from a file, or the environment when empty.
+func configData(file string) ([]byte, error) {
+	if file == "" {
+		val := os.Getenv(praetorian.EnvKey)
+		if val == "" {
+			return nil, praetorian.ErrEnvConfigEmpty
+		}
+		return []byte(val), nil
+	}
+	return os.ReadFile(file)
+}
+
+func (c *cli) keygen(args []string) error {
+	fs := flag.NewFlagSet("keygen", flag.ContinueOnError)
+	id := fs.String("id", "1", "identifier of the generated root key")
+	keyOnly := fs.Bool("key-only", false, "print only the base64 encoded key")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	k := make([]byte, praetorian.RootKeyLength)
+	if _, err := rand.Read(k); err != nil {
+		return err
+	}
+	defer clear(k)
+
+	if *keyOnly {
+		_, err := fmt.Fprintln(c.stdout, base64.StdEncoding.EncodeToString(k))
+		return err
+	}
+
+	b, err := json.Marshal(map[string]any{
+		"activeKeyId": *id,
+		"rootKeys":    map[string]string{*id: base64.StdEncoding.EncodeToString(k)},
+	})
+	if err != nil {
+		return err
+	}
+	cfg, err := praetorian.ParseConfig(b)
+	if err != nil {
+		return err
+	}
+	return c.printJSON(cfg)
+}
+
+func (c *cli) config(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing config command\n\n%s", usage)
+	}
+
+	switch args[0] {
+	case "validate":
+		return c.configValidate(args[1:])
+	case "add-key":
+		return c.configAddKey(args[1:])
+	case "set-active":
+		return c.configSetActive(args[1:])
+	}
+	return fmt.Errorf("unknown config command %q\n\n%s", args[0], usage)
+}
+
+func (c *cli) configValidate(args []string) error {
+	fs := flag.NewFlagSet("config validate", flag.ContinueOnError)
+	file := fs.String("config", "", "path to a config file, defaults to $"+praetorian.EnvKey)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	b, err := configData(*file)
+	if err != nil {
+		return err
+	}
+	cfg, err := praetorian.ParseConfig(b)
+	if err != nil {
+		return err
+	}
+	if _, err := praetorian.NewKeystore(cfg); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(c.stdout, "config is valid")
+	return err
+}
+
+func (c *cli) configAddKey(args []string) error {
+	fs := flag.NewFlagSet("config add-key", flag.ContinueOnError)
+	file := fs.String("config", "", "path to a config file, defaults to $"+praetorian.EnvKey)
+	id := fs.String("id", "", "identifier of the new root key, defaults to the next number")
+	activate := fs.Bool("activate", false, "make the new root key the active key")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	b, err := configData(*file)
+	if err != nil {
+		return err
+	}
+	cfg, err := praetorian.ParseConfig(b)
+	if err != nil {
+		return err
+	}
+	if *id == "" {
+		*id, err = nextKeyID(b)
+		if err != nil {
+			return err
+		}
+	}
+
+	k := make([]byte, praetorian.RootKeyLength)
+	if _, err := rand.Read(k); err != nil {
+		return err
+	}
+	defer clear(k)
+
+	if err := cfg.Import(*id, k); err != nil {
+		return err
+	}
+	if *activate {
+		if err := cfg.SetActiveKey(*id); err != nil {
+			return err
+		}
+	}
+	return c.printJSON(cfg)
+}
+
+func (c *cli) configSetActive(args []string) error {
+	fs := flag.NewFlagSet("config set-active", flag.ContinueOnError)
+	file := fs.String("config", "", "path to a config file, defaults to $"+praetorian.EnvKey)
+	id := fs.String("id", "", "identifier of the root, signing or MAC key to activate")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	b, err := configData(*file)
+	if err != nil {
+		return err
+	}
+	cfg, err := praetorian.ParseConfig(b)
+	if err != nil {
+		return err
+	}
+	if err := cfg.SetActiveKey(*id); err != nil {
+		return err
+	}
+	return c.printJSON(cfg)
+}
+
+// nextKeyID returns one more than the largest numeric key identifier.
+func nextKeyID(b []byte) (string, error) {
+	var env map[string]json.RawMessage
+	if err := json.Unmarshal(b, &env); err != nil {
+		return "", err
+	}
+
+	next := 1
+	for _, field := range []string{"rootKeys", "ecdhKeys", "signingKeys", "macKeys"} {
+		var keys map[string]json.RawMessage
+		if err := json.Unmarshal(env[field], &keys); err != nil {
+			continue
+		}
+		for id := range keys {
+			if n, err := strconv.Atoi(id); err == nil && n >= next {
+				next = n + 1
+			}
+		}
+	}
+	return strconv.Itoa(next), nil
+}
+
+func (c *cli) printJSON(v any) error {
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
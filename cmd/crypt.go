@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/karlbateman/praetorian"
+	"github.com/karlbateman/praetorian/client"
+	"github.com/karlbateman/praetorian/envelope"
+)
+
+// wrap encrypts a JSON data encryption key read from stdin and prints the
+// wrap response.
+func (c *cli) wrap(args []string) error {
+	fs := flag.NewFlagSet("wrap", flag.ContinueOnError)
+	file := fs.String("config", "", "path to a config file, defaults to $"+praetorian.EnvKey)
+	server := fs.String("server", "", "base URL of a Praetorian server, defaults to the local config")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var data json.RawMessage
+	if err := json.NewDecoder(c.stdin).Decode(&data); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	if *server != "" {
+		res, err := client.New(*server).Wrap(data)
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(c.stdout).Encode(res)
+	}
+
+	ks, err := keystore(*file)
+	if err != nil {
+		return err
+	}
+	k, err := ks.Find(praetorian.ActiveKeyID)
+	if err != nil {
+		return err
+	}
+	enc, err := k.Encrypt(data)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(c.stdout).Encode(&praetorian.WrapResponse{
+		ID:    k.ID(),
+		Token: base64.StdEncoding.EncodeToString(enc),
+	})
+}
+
+// unwrap decrypts a wrap response read from stdin and prints the JSON data
+// encryption key.
+func (c *cli) unwrap(args []string) error {
+	fs := flag.NewFlagSet("unwrap", flag.ContinueOnError)
+	file := fs.String("config", "", "path to a config file, defaults to $"+praetorian.EnvKey)
+	server := fs.String("server", "", "base URL of a Praetorian server, defaults to the local config")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var w praetorian.WrapResponse
+	if err := json.NewDecoder(c.stdin).Decode(&w); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	var data json.RawMessage
+	if *server != "" {
+		if err := client.New(*server).Unwrap(&w, &data); err != nil {
+			return err
+		}
+		return json.NewEncoder(c.stdout).Encode(data)
+	}
+
+	ks, err := keystore(*file)
+	if err != nil {
+		return err
+	}
+	k, err := ks.Find(w.ID)
+	if err != nil {
+		return err
+	}
+	token, err := base64.StdEncoding.DecodeString(w.Token)
+	if err != nil {
+		return err
+	}
+	data, err = k.Decrypt(token)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(c.stdout).Encode(data)
+}
+
+// crypt streams a file through envelope encryption, wrapping the data
+// encryption key with a remote server or with the local config. An output
+// file is removed again when the command fails, rather than being left with
+// partial output.
+func (c *cli) crypt(args []string, encrypt bool) (err error) {
+	name := "decrypt"
+	if encrypt {
+		name = "encrypt"
+	}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	file := fs.String("config", "", "path to a config file, defaults to $"+praetorian.EnvKey)
+	in := fs.String("in", "-", "input file, or - for stdin")
+	out := fs.String("out", "-", "output file, or - for stdout")
+	server := fs.String("server", "", "base URL of a Praetorian server, defaults to the local config")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var w envelope.Wrapper
+	if *server != "" {
+		w = client.New(*server)
+	} else {
+		ks, err := keystore(*file)
+		if err != nil {
+			return err
+		}
+		w = envelope.KeyFinderWrapper(ks)
+	}
+	s := envelope.New(w)
+	ctx := context.Background()
+
+	src := c.stdin
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		src = f
+	}
+
+	dst := c.stdout
+	if *out != "-" {
+		// err is the result of crypt, so that the deferred close sees it.
+		var f *os.File
+		if f, err = os.Create(*out); err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(*out)
+			}
+		}()
+		dst = f
+	}
+
+	if !encrypt {
+		r, err := s.NewReader(ctx, src)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(dst, r)
+		return err
+	}
+
+	ew, err := s.NewWriter(ctx, dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(ew, src); err != nil {
+		return err
+	}
+	return ew.Close()
+}
+
+// keystore builds a keystore from a config file or the environment.
+func keystore(file string) (praetorian.KeyFinder, error) {
+	b, err := configData(file)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := praetorian.ParseConfig(b)
+	if err != nil {
+		return nil, err
+	}
+	return praetorian.NewKeystore(cfg)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"os"
+
+	"github.com/karlbateman/praetorian"
+)
+
+// split seals the root keys of a config under a new master key and prints the
+// sealed config alongside the master key shares.
+func (c *cli) split(args []string) error {
+	fs := flag.NewFlagSet("split", flag.ContinueOnError)
+	file := fs.String("config", "", "path to a config file, defaults to $"+praetorian.EnvKey)
+	n := fs.Int("shares", 5, "number of master key shares to generate")
+	k := fs.Int("threshold", 3, "number of shares required to unseal")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	b, err := configData(*file)
+	if err != nil {
+		return err
+	}
+	cfg, err := praetorian.ParseConfig(b)
+	if err != nil {
+		return err
+	}
+	sealed, shares, err := praetorian.SealConfig(cfg, *n, *k)
+	if err != nil {
+		return err
+	}
+
+	out := struct {
+		Config any      `json:"config"`
+		Shares []string `json:"shares"`
+	}{Config: sealed}
+	for _, s := range shares {
+		out.Shares = append(out.Shares, base64.StdEncoding.EncodeToString(s))
+	}
+	return c.printJSON(out)
+}
+
+// export encrypts a root key from a config under the public key of a
+// recipient and prints the resulting key export.
+func (c *cli) export(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	file := fs.String("config", "", "path to a config file, defaults to $"+praetorian.EnvKey)
+	id := fs.String("id", "", "identifier of the root key to export")
+	recipient := fs.String("recipient", "", "path to the recipient PEM public key")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	pb, err := os.ReadFile(*recipient)
+	if err != nil {
+		return err
+	}
+	pub, err := praetorian.ParsePublicKeyPEM(pb)
+	if err != nil {
+		return err
+	}
+
+	ks, err := keystore(*file)
+	if err != nil {
+		return err
+	}
+	k, err := ks.Find(*id)
+	if err != nil {
+		return err
+	}
+	e, err := praetorian.ExportKey(k, pub)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(c.stdout).Encode(e)
+}
+
+// importKey decrypts a key export read from stdin with the recipient private
+// key and prints the config with the root key added.
+func (c *cli) importKey(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	file := fs.String("config", "", "path to a config file, defaults to $"+praetorian.EnvKey)
+	keyFile := fs.String("key", "", "path to the recipient PEM private key")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	kb, err := os.ReadFile(*keyFile)
+	if err != nil {
+		return err
+	}
+	priv, err := praetorian.ParsePrivateKeyPEM(kb)
+	if err != nil {
+		return err
+	}
+
+	var e praetorian.KeyExport
+	if err := json.NewDecoder(c.stdin).Decode(&e); err != nil {
+		return err
+	}
+
+	b, err := configData(*file)
+	if err != nil {
+		return err
+	}
+	cfg, err := praetorian.ParseConfig(b)
+	if err != nil {
+		return err
+	}
+	if err := praetorian.ImportKey(cfg, &e, priv); err != nil {
+		return err
+	}
+	return c.printJSON(cfg)
+}
@@ -1,27 +1,164 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/karlbateman/praetorian"
 )
 
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
+const usage = `Usage: praetorian <command> [flags]
+
+Commands:
+  serve               start the HTTP server (default)
+  keygen              generate a root key and a config which uses it
+  config validate     check a config for errors
+  config add-key      add a new root key to a config
+  config set-active   change the active root, signing or MAC key
+  wrap                wrap a JSON data encryption key read from stdin
+  unwrap              unwrap a wrap response read from stdin
+  encrypt             encrypt a file as an envelope encrypted stream
+  decrypt             decrypt a file produced by encrypt
+  split               seal a config under a master key split into shares
+  export              export a root key to a recipient public key
+  import              import a root key export into a config
+  version             print the version
+
+Run 'praetorian <command> -h' for the flags of a command.
+`
+
+// cli holds the streams used by each command.
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+}
+
 func main() {
-	if err := run(); err != nil {
+	c := &cli{stdin: os.Stdin, stdout: os.Stdout}
+	if err := c.run(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
 }
 
-func run() error {
-	c, err := praetorian.NewConfig()
+func (c *cli) run(args []string) error {
+	if len(args) == 0 {
+		return c.serve(nil)
+	}
+
+	switch args[0] {
+	case "serve":
+		return c.serve(args[1:])
+	case "keygen":
+		return c.keygen(args[1:])
+	case "config":
+		return c.config(args[1:])
+	case "wrap":
+		return c.wrap(args[1:])
+	case "unwrap":
+		return c.unwrap(args[1:])
+	case "encrypt":
+		return c.crypt(args[1:], true)
+	case "decrypt":
+		return c.crypt(args[1:], false)
+	case "split":
+		return c.split(args[1:])
+	case "export":
+		return c.export(args[1:])
+	case "import":
+		return c.importKey(args[1:])
+	case "version":
+		_, err := fmt.Fprintln(c.stdout, version)
+		return err
+	case "help", "-h", "-help", "--help":
+		_, err := fmt.Fprint(c.stdout, usage)
+		return err
+	}
+	return fmt.Errorf("unknown command %q\n\n%s", args[0], usage)
+}
+
+func (c *cli) serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	file := fs.String("config", "", "path to a config file, defaults to $"+praetorian.EnvKey)
+	opts, err := praetorian.ServerOptionsFromEnv()
 	if err != nil {
 		return err
 	}
-	ks, err := praetorian.NewKeystore(c)
+	endpoints := fs.String("endpoints", "", "comma separated endpoints to enable, defaults to $"+praetorian.EnvEndpoints+" or all")
+	fs.StringVar(&opts.Addr, "addr", opts.Addr, "TCP address to listen on, defaults to $"+praetorian.EnvAddr+" or :$PORT")
+	fs.Int64Var(&opts.MaxBodyBytes, "max-body-bytes", praetorian.DefaultMaxBodyBytes, "maximum size of request bodies")
+	fs.DurationVar(&opts.ShutdownTimeout, "shutdown-timeout", praetorian.DefaultShutdownTimeout, "time allowed for requests to complete on shutdown")
+	fs.DurationVar(&opts.DrainDelay, "drain-delay", 0, "time to keep serving after readiness fails on shutdown")
+	sunset := fs.String("legacy-sunset", "", "RFC 3339 time the unversioned routes will be removed, defaults to $"+praetorian.EnvLegacySunset)
+	fs.BoolVar(&opts.DisableLegacyRoutes, "disable-legacy-routes", false, "serve the endpoints only below /v1")
+	tlsCert := fs.String("tls-cert", "", "path to a TLS certificate, defaults to $"+praetorian.EnvTLSCert)
+	tlsKey := fs.String("tls-key", "", "path to the TLS private key, defaults to $"+praetorian.EnvTLSKey)
+	fs.BoolVar(&opts.HTTP2.Cleartext, "h2c", opts.HTTP2.Cleartext, "accept HTTP/2 without TLS, defaults to $"+praetorian.EnvH2C)
+	fs.IntVar(&opts.HTTP2.MaxConcurrentStreams, "http2-max-streams", opts.HTTP2.MaxConcurrentStreams, "maximum concurrent HTTP/2 streams per connection")
+	fs.DurationVar(&opts.Timeouts.ReadHeader, "read-header-timeout", 0, "time allowed to read request headers, negative to disable")
+	fs.DurationVar(&opts.Timeouts.Read, "read-timeout", 0, "time allowed to read a request, negative to disable")
+	fs.DurationVar(&opts.Timeouts.Write, "write-timeout", 0, "time allowed to write a response, negative to disable")
+	fs.DurationVar(&opts.Timeouts.Idle, "idle-timeout", 0, "time a keep-alive connection may stay idle, negative to disable")
+	fs.DurationVar(&opts.Timeouts.Request, "request-timeout", 0, "deadline for handling a request, negative to disable")
+	fs.IntVar(&opts.MaxHeaderBytes, "max-header-bytes", praetorian.DefaultMaxHeaderBytes, "maximum size of request headers")
+	fs.IntVar(&opts.MaxConnections, "max-connections", 0, "maximum open connections per listener, 0 for no limit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *sunset != "" {
+		if opts.LegacySunset, err = time.Parse(time.RFC3339, *sunset); err != nil {
+			return err
+		}
+	}
+	if *tlsCert != "" {
+		cert, err := tls.LoadX509KeyPair(*tlsCert, *tlsKey)
+		if err != nil {
+			return err
+		}
+		opts.TLS = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+	if *endpoints != "" {
+		if opts.Endpoints, err = praetorian.ParseEndpoints(*endpoints); err != nil {
+			return err
+		}
+	}
+
+	b, err := configData(*file)
 	if err != nil {
 		return err
 	}
-	return praetorian.NewServer(ks).Start()
+	cfg, err := praetorian.ParseConfig(b)
+	if err != nil {
+		return err
+	}
+	ks, err := praetorian.NewKeystore(cfg)
+	if err != nil {
+		return err
+	}
+	srv := praetorian.NewServer(ks,
+		praetorian.WithRateLimits(cfg.RateLimits),
+		praetorian.WithAnomalyDetection(cfg.AnomalyDetection, nil),
+		praetorian.WithOptions(opts),
+	)
+
+	// a second signal exits immediately rather than waiting for the drain
+	// delay and in-flight requests.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+	return srv.Start(ctx)
 }
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+func runCLI(t *testing.T, stdin string, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	c := &cli{stdin: strings.NewReader(stdin), stdout: &out}
+	err := c.run(args)
+	return out.String(), err
+}
+
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+		t.Fatalf("os.WriteFile() failed: %v", err)
+	}
+	return file
+}
+
+func TestCLI_Config(t *testing.T) {
+	out, err := runCLI(t, "", "keygen", "-id", "a")
+	if err != nil {
+		t.Fatalf("keygen failed: %v", err)
+	}
+	file := writeConfig(t, out)
+
+	if out, err := runCLI(t, "", "config", "validate", "-config", file); err != nil || !strings.Contains(out, "valid") {
+		t.Fatalf("config validate out = %q, err = %v", out, err)
+	}
+
+	out, err = runCLI(t, "", "config", "add-key", "-config", file)
+	if err != nil {
+		t.Fatalf("config add-key failed: %v", err)
+	}
+	file = writeConfig(t, out)
+
+	out, err = runCLI(t, "", "config", "set-active", "-config", file, "-id", "1")
+	if err != nil {
+		t.Fatalf("config set-active failed: %v", err)
+	}
+
+	var got struct {
+		ActiveKeyID string            `json:"activeKeyId"`
+		RootKeys    map[string]string `json:"rootKeys"`
+	}
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("config set-active printed invalid JSON: %v", err)
+	}
+	if got.ActiveKeyID != "1" || len(got.RootKeys) != 2 {
+		t.Errorf("config set-active got = %+v, want active key 1 of 2", got)
+	}
+
+	if _, err := runCLI(t, "", "config", "set-active", "-config", file, "-id", "9"); !errors.Is(err, praetorian.ErrActiveRootKeyNotFound) {
+		t.Errorf("config set-active error = %v, wantErr = %v", err, praetorian.ErrActiveRootKeyNotFound)
+	}
+}
+
+func TestCLI_SetActiveSigningKey(t *testing.T) {
+	file := writeConfig(t, `{"activeKeyId": "1", "rootKeys": {"1": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}, "activeSigningKeyId": "4", "signingKeys": {"4": {"algorithm": "EdDSA", "privateKey": "BPK//lj6hlpjuA5gPZo19OIjUDgnIQAAAAAAAAAAAAA="}, "5": {"algorithm": "ES256", "privateKey": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}}}`)
+
+	out, err := runCLI(t, "", "config", "set-active", "-config", file, "-id", "5")
+	if err != nil {
+		t.Fatalf("config set-active failed: %v", err)
+	}
+	var got struct {
+		ActiveKeyID        string `json:"activeKeyId"`
+		ActiveSigningKeyID string `json:"activeSigningKeyId"`
+	}
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("config set-active printed invalid JSON: %v", err)
+	}
+	if got.ActiveKeyID != "1" || got.ActiveSigningKeyID != "5" {
+		t.Errorf("config set-active got = %+v, want active root key 1 and signing key 5", got)
+	}
+}
+
+func TestCLI_SetActiveMACKey(t *testing.T) {
+	file := writeConfig(t, `{"activeKeyId": "1", "rootKeys": {"1": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}, "activeMacKeyId": "7", "macKeys": {"6": "BPK//lj6hlpjuA5gPZo19OIjUDgnIQAAAAAAAAAAAAA=", "7": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}}`)
+
+	out, err := runCLI(t, "", "config", "set-active", "-config", file, "-id", "6")
+	if err != nil {
+		t.Fatalf("config set-active failed: %v", err)
+	}
+	var got struct {
+		ActiveKeyID    string `json:"activeKeyId"`
+		ActiveMACKeyID string `json:"activeMacKeyId"`
+	}
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("config set-active printed invalid JSON: %v", err)
+	}
+	if got.ActiveKeyID != "1" || got.ActiveMACKeyID != "6" {
+		t.Errorf("config set-active got = %+v, want active root key 1 and MAC key 6", got)
+	}
+}
+
+func TestCLI_WrapUnwrap(t *testing.T) {
+	file := writeConfig(t, `{"activeKeyId": "1", "rootKeys": {"1": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}}`)
+
+	wrapped, err := runCLI(t, `{"key": "abc123"}`, "wrap", "-config", file)
+	if err != nil {
+		t.Fatalf("wrap failed: %v", err)
+	}
+	out, err := runCLI(t, wrapped, "unwrap", "-config", file)
+	if err != nil {
+		t.Fatalf("unwrap failed: %v", err)
+	}
+	if strings.TrimSpace(out) != `{"key":"abc123"}` {
+		t.Errorf("unwrap got = %q", out)
+	}
+}
+
+func TestCLI_Commands(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "version",
+			args: []string{"version"},
+			want: "dev",
+		},
+		{
+			name: "help",
+			args: []string{"help"},
+			want: "Usage: praetorian",
+		},
+		{
+			name:    "unknown command",
+			args:    []string{"unknown"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown config command",
+			args:    []string{"config", "unknown"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := runCLI(t, "", tt.args...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("run() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+			if !strings.Contains(out, tt.want) {
+				t.Errorf("run() out = %q, want = %q", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestCLI_Crypt(t *testing.T) {
+	file := writeConfig(t, `{"activeKeyId": "1", "rootKeys": {"1": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}}`)
+	dir := t.TempDir()
+	encrypted := filepath.Join(dir, "secret.enc")
+
+	if _, err := runCLI(t, "keep it secret, keep it safe", "encrypt", "-config", file, "-out", encrypted); err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+	out, err := runCLI(t, "", "decrypt", "-config", file, "-in", encrypted)
+	if err != nil {
+		t.Fatalf("decrypt failed: %v", err)
+	}
+	if out != "keep it secret, keep it safe" {
+		t.Errorf("decrypt got = %q", out)
+	}
+
+	decrypted := filepath.Join(dir, "secret.txt")
+	if _, err := runCLI(t, "not an envelope", "decrypt", "-config", file, "-out", decrypted); err == nil {
+		t.Fatal("decrypt of invalid input succeeded")
+	}
+	if _, err := os.Stat(decrypted); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("decrypt left the output file behind: %v", err)
+	}
+}
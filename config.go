@@ -1,50 +1,302 @@
 package praetorian
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/json"
 	"os"
 )
 
-type config struct {
-	ActiveKeyID string
-	RootKeys    map[string][]byte
+// Config holds the keys of a keystore. It is usually parsed from JSON with
+// NewConfig or ParseConfig, but can be built in code and checked with
+// Validate before it is passed to NewKeystore.
+type Config struct {
+	ActiveKeyID        string
+	RootKeys           map[string][]byte
+	ECDHKeys           map[string]ECDHKeyConfig
+	ActiveSigningKeyID string
+	SigningKeys        map[string]SigningKeyConfig
+	ActiveMACKeyID     string
+	MACKeys            map[string][]byte
+	UnsealThreshold    int
+	RateLimits         RateLimits
+	AnomalyDetection   AnomalyDetection
+	StateFile          string
+}
+
+// ECDHKeyConfig is a root key which wraps data keys for a public key.
+type ECDHKeyConfig struct {
+	Curve      string
+	PrivateKey []byte
+}
+
+// SigningKeyConfig is a key used to sign messages.
+type SigningKeyConfig struct {
+	Algorithm  string
+	PrivateKey []byte
+}
+
+// represents the JSON structure set in the environment.
+type envConfig struct {
+	ActiveKeyID        string                   `json:"activeKeyId"`
+	RootKeys           map[string]string        `json:"rootKeys"`
+	ECDHKeys           map[string]envECDHKey    `json:"ecdhKeys,omitempty"`
+	ActiveSigningKeyID string                   `json:"activeSigningKeyId,omitempty"`
+	SigningKeys        map[string]envSigningKey `json:"signingKeys,omitempty"`
+	ActiveMACKeyID     string                   `json:"activeMacKeyId,omitempty"`
+	MACKeys            map[string]string        `json:"macKeys,omitempty"`
+	UnsealThreshold    int                      `json:"unsealThreshold,omitempty"`
+	RateLimits         *RateLimits              `json:"rateLimits,omitempty"`
+	AnomalyDetection   *AnomalyDetection        `json:"anomalyDetection,omitempty"`
+	StateFile          string                   `json:"stateFile,omitempty"`
+}
+
+type envECDHKey struct {
+	Curve      string `json:"curve"`
+	PrivateKey string `json:"privateKey"`
+}
+
+type envSigningKey struct {
+	Algorithm  string `json:"algorithm"`
+	PrivateKey string `json:"privateKey"`
 }
 
 // NewConfig returns a key configuration from the environment.
-func NewConfig() (*config, error) {
+func NewConfig() (*Config, error) {
 	val := os.Getenv(EnvKey)
 	if val == "" {
 		return nil, ErrEnvConfigEmpty
 	}
+	return ParseConfig([]byte(val))
+}
 
-	// represents the JSON structure set in the environment.
-	var env struct {
-		ActiveKeyID string            `json:"activeKeyId"`
-		RootKeys    map[string]string `json:"rootKeys"`
+// ParseConfig returns a key configuration from its JSON representation.
+func ParseConfig(data []byte) (*Config, error) {
+	var env envConfig
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, ErrEnvConfigInvalid
 	}
 
-	if err := json.Unmarshal([]byte(val), &env); err != nil {
-		return nil, ErrEnvConfigInvalid
+	c := &Config{
+		ActiveKeyID:        env.ActiveKeyID,
+		RootKeys:           make(map[string][]byte),
+		ECDHKeys:           make(map[string]ECDHKeyConfig),
+		ActiveSigningKeyID: env.ActiveSigningKeyID,
+		SigningKeys:        make(map[string]SigningKeyConfig),
+		ActiveMACKeyID:     env.ActiveMACKeyID,
+		MACKeys:            make(map[string][]byte),
+		UnsealThreshold:    env.UnsealThreshold,
+		StateFile:          env.StateFile,
+	}
+	if env.RateLimits != nil {
+		c.RateLimits = *env.RateLimits
+	}
+	if env.AnomalyDetection != nil {
+		c.AnomalyDetection = *env.AnomalyDetection
 	}
 
-	c := &config{
-		ActiveKeyID: env.ActiveKeyID,
-		RootKeys:    make(map[string][]byte),
+	var err error
+	for i, m := range env.RootKeys {
+		if c.RootKeys[i], err = base64.StdEncoding.DecodeString(m); err != nil {
+			return nil, ErrInvalidRootKey
+		}
+	}
+	for i, m := range env.ECDHKeys {
+		k, err := base64.StdEncoding.DecodeString(m.PrivateKey)
+		if err != nil {
+			return nil, ErrInvalidRootKey
+		}
+		c.ECDHKeys[i] = ECDHKeyConfig{Curve: m.Curve, PrivateKey: k}
+	}
+	for i, m := range env.SigningKeys {
+		k, err := base64.StdEncoding.DecodeString(m.PrivateKey)
+		if err != nil {
+			return nil, ErrInvalidRootKey
+		}
+		c.SigningKeys[i] = SigningKeyConfig{Algorithm: m.Algorithm, PrivateKey: k}
+	}
+	for i, m := range env.MACKeys {
+		if c.MACKeys[i], err = base64.StdEncoding.DecodeString(m); err != nil {
+			return nil, ErrInvalidRootKey
+		}
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate checks that the active keys exist, that every key has a valid
+// length and algorithm and that no identifier is used twice.
+func (c *Config) Validate() error {
+	if err := c.RateLimits.validate(); err != nil {
+		return err
+	}
+	if err := c.AnomalyDetection.validate(); err != nil {
+		return err
 	}
 
-	if _, ok := env.RootKeys[env.ActiveKeyID]; ok {
-		for i, m := range env.RootKeys {
-			k, err := base64.StdEncoding.DecodeString(m)
-			if err != nil {
-				return nil, ErrInvalidRootKey
+	_, isRoot := c.RootKeys[c.ActiveKeyID]
+	_, isECDH := c.ECDHKeys[c.ActiveKeyID]
+	if !isRoot && !isECDH {
+		return ErrActiveRootKeyNotFound
+	}
+
+	// sealed root keys are encrypted under the master key.
+	keyLength := RootKeyLength
+	if c.Sealed() {
+		keyLength = sealedRootKeyLength
+	}
+
+	seen := make(map[string]bool)
+	for id, k := range c.RootKeys {
+		if len(k) != keyLength {
+			return ErrInvalidRootKeyLength
+		}
+		seen[id] = true
+	}
+
+	for id, ek := range c.ECDHKeys {
+		if seen[id] {
+			return ErrRootKeyExists
+		}
+		seen[id] = true
+		if len(ek.PrivateKey) != keyLength {
+			return ErrInvalidRootKeyLength
+		}
+		if !c.Sealed() {
+			if _, err := ek.privateKey(); err != nil {
+				return err
 			}
-			if len(k) != RootKeyLength {
-				return nil, ErrInvalidRootKeyLength
+		} else if _, err := ecdhCurve(ek.Curve); err != nil {
+			return err
+		}
+	}
+
+	if _, ok := c.SigningKeys[c.ActiveSigningKeyID]; !ok && len(c.SigningKeys) > 0 {
+		return ErrActiveSigningKeyNotFound
+	}
+	for id, sk := range c.SigningKeys {
+		if seen[id] {
+			return ErrRootKeyExists
+		}
+		seen[id] = true
+		if len(sk.PrivateKey) != keyLength {
+			return ErrInvalidRootKeyLength
+		}
+		if !c.Sealed() {
+			if _, err := sk.signingKey(id); err != nil {
+				return err
+			}
+		} else if !validSigningAlgorithm(sk.Algorithm) {
+			return ErrUnsupportedAlgorithm
+		}
+	}
+
+	if _, ok := c.MACKeys[c.ActiveMACKeyID]; !ok && len(c.MACKeys) > 0 {
+		return ErrActiveMACKeyNotFound
+	}
+	for id, k := range c.MACKeys {
+		if seen[id] {
+			return ErrRootKeyExists
+		}
+		seen[id] = true
+		if len(k) != keyLength {
+			return ErrInvalidRootKeyLength
+		}
+	}
+	return nil
+}
+
+// Sealed reports whether the root keys are encrypted under a master key.
+func (c *Config) Sealed() bool {
+	return c.UnsealThreshold > 0
+}
+
+// Import adds a root key which does not already exist in the config.
+func (c *Config) Import(id string, value []byte) error {
+	if c.Sealed() {
+		return ErrKeystoreSealed
+	}
+	if c.hasKey(id) || id == ActiveKeyID {
+		return ErrRootKeyExists
+	}
+	c.RootKeys[id] = bytes.Clone(value)
+	return nil
+}
+
+// SetActiveKey makes the key with the given identifier the active key of its
+// kind, which is a root key, a signing key or a MAC key.
+func (c *Config) SetActiveKey(id string) error {
+	_, isRoot := c.RootKeys[id]
+	_, isECDH := c.ECDHKeys[id]
+	_, isSigning := c.SigningKeys[id]
+	_, isMAC := c.MACKeys[id]
+	switch {
+	case isRoot || isECDH:
+		c.ActiveKeyID = id
+	case isSigning:
+		c.ActiveSigningKeyID = id
+	case isMAC:
+		c.ActiveMACKeyID = id
+	default:
+		return ErrActiveRootKeyNotFound
+	}
+	return nil
+}
+
+// hasKey reports whether any kind of key uses the given identifier.
+func (c *Config) hasKey(id string) bool {
+	_, isRoot := c.RootKeys[id]
+	_, isECDH := c.ECDHKeys[id]
+	_, isSigning := c.SigningKeys[id]
+	_, isMAC := c.MACKeys[id]
+	return isRoot || isECDH || isSigning || isMAC
+}
+
+// MarshalJSON encodes the config in the format read from the environment.
+func (c *Config) MarshalJSON() ([]byte, error) {
+	env := envConfig{
+		ActiveKeyID:        c.ActiveKeyID,
+		RootKeys:           make(map[string]string, len(c.RootKeys)),
+		ActiveSigningKeyID: c.ActiveSigningKeyID,
+		ActiveMACKeyID:     c.ActiveMACKeyID,
+		UnsealThreshold:    c.UnsealThreshold,
+		StateFile:          c.StateFile,
+	}
+	if c.RateLimits.Enabled() {
+		env.RateLimits = &c.RateLimits
+	}
+	if c.AnomalyDetection.Enabled() {
+		env.AnomalyDetection = &c.AnomalyDetection
+	}
+	for id, k := range c.RootKeys {
+		env.RootKeys[id] = base64.StdEncoding.EncodeToString(k)
+	}
+	if len(c.ECDHKeys) > 0 {
+		env.ECDHKeys = make(map[string]envECDHKey, len(c.ECDHKeys))
+		for id, k := range c.ECDHKeys {
+			env.ECDHKeys[id] = envECDHKey{
+				Curve:      k.Curve,
+				PrivateKey: base64.StdEncoding.EncodeToString(k.PrivateKey),
 			}
-			c.RootKeys[i] = k
 		}
-		return c, nil
 	}
-	return nil, ErrActiveRootKeyNotFound
+	if len(c.SigningKeys) > 0 {
+		env.SigningKeys = make(map[string]envSigningKey, len(c.SigningKeys))
+		for id, k := range c.SigningKeys {
+			env.SigningKeys[id] = envSigningKey{
+				Algorithm:  k.Algorithm,
+				PrivateKey: base64.StdEncoding.EncodeToString(k.PrivateKey),
+			}
+		}
+	}
+	if len(c.MACKeys) > 0 {
+		env.MACKeys = make(map[string]string, len(c.MACKeys))
+		for id, k := range c.MACKeys {
+			env.MACKeys[id] = base64.StdEncoding.EncodeToString(k)
+		}
+	}
+	return json.Marshal(env)
 }
@@ -38,6 +38,66 @@ func TestNewConfig(t *testing.T) {
 			config:  `{"activeKeyId": "2", "rootKeys": {"1": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}}`,
 			wantErr: praetorian.ErrActiveRootKeyNotFound,
 		},
+		{
+			name:    "sealed root key length",
+			config:  `{"activeKeyId": "1", "rootKeys": {"1": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}, "unsealThreshold": 2}`,
+			wantErr: praetorian.ErrInvalidRootKeyLength,
+		},
+		{
+			name:    "unsupported ECDH curve",
+			config:  `{"activeKeyId": "1", "rootKeys": {"1": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}, "ecdhKeys": {"2": {"curve": "P-521", "privateKey": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}}}`,
+			wantErr: praetorian.ErrUnsupportedCurve,
+		},
+		{
+			name:    "duplicate key identifier",
+			config:  `{"activeKeyId": "1", "rootKeys": {"1": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}, "ecdhKeys": {"1": {"curve": "X25519", "privateKey": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}}}`,
+			wantErr: praetorian.ErrRootKeyExists,
+		},
+		{
+			name:    "valid ECDH config",
+			config:  testECDHConfig,
+			wantErr: nil,
+		},
+		{
+			name:    "active signing key not found",
+			config:  `{"activeKeyId": "1", "rootKeys": {"1": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}, "activeSigningKeyId": "9", "signingKeys": {"4": {"algorithm": "EdDSA", "privateKey": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}}}`,
+			wantErr: praetorian.ErrActiveSigningKeyNotFound,
+		},
+		{
+			name:    "unsupported signing algorithm",
+			config:  `{"activeKeyId": "1", "rootKeys": {"1": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}, "activeSigningKeyId": "4", "signingKeys": {"4": {"algorithm": "RS256", "privateKey": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}}}`,
+			wantErr: praetorian.ErrUnsupportedAlgorithm,
+		},
+		{
+			name:    "valid signing config",
+			config:  testSigningConfig,
+			wantErr: nil,
+		},
+		{
+			name:    "active MAC key not found",
+			config:  `{"activeKeyId": "1", "rootKeys": {"1": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}, "macKeys": {"6": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}}`,
+			wantErr: praetorian.ErrActiveMACKeyNotFound,
+		},
+		{
+			name:    "valid MAC config",
+			config:  testMACConfig,
+			wantErr: nil,
+		},
+		{
+			name:    "invalid rate limit",
+			config:  `{"activeKeyId": "1", "rootKeys": {"1": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}, "rateLimits": {"operations": {"/unwrap": {"rate": 0}}}}`,
+			wantErr: praetorian.ErrInvalidRateLimit,
+		},
+		{
+			name:    "valid rate limits",
+			config:  `{"activeKeyId": "1", "rootKeys": {"1": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}, "rateLimits": {"maxInFlight": 64, "default": {"rate": 50}, "operations": {"/unwrap": {"rate": 5, "burst": 10}}}}`,
+			wantErr: nil,
+		},
+		{
+			name:    "invalid anomaly factor",
+			config:  `{"activeKeyId": "1", "rootKeys": {"1": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}, "anomalyDetection": {"factor": 0.5}}`,
+			wantErr: praetorian.ErrInvalidAnomalyDetection,
+		},
 		{
 			name:    "valid config",
 			config:  `{"activeKeyId": "1", "rootKeys": {"1": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}}`,
@@ -55,3 +115,47 @@ func TestNewConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestConfig_Validate(t *testing.T) {
+	key := make([]byte, praetorian.RootKeyLength)
+
+	tests := []struct {
+		name    string
+		config  *praetorian.Config
+		wantErr error
+	}{
+		{
+			name:    "active key not found",
+			config:  &praetorian.Config{ActiveKeyID: "2", RootKeys: map[string][]byte{"1": key}},
+			wantErr: praetorian.ErrActiveRootKeyNotFound,
+		},
+		{
+			name:    "invalid root key length",
+			config:  &praetorian.Config{ActiveKeyID: "1", RootKeys: map[string][]byte{"1": key[:16]}},
+			wantErr: praetorian.ErrInvalidRootKeyLength,
+		},
+		{
+			name: "duplicate key identifier",
+			config: &praetorian.Config{
+				ActiveKeyID:    "1",
+				RootKeys:       map[string][]byte{"1": key},
+				ActiveMACKeyID: "1",
+				MACKeys:        map[string][]byte{"1": key},
+			},
+			wantErr: praetorian.ErrRootKeyExists,
+		},
+		{
+			name:    "valid config",
+			config:  &praetorian.Config{ActiveKeyID: "1", RootKeys: map[string][]byte{"1": key}},
+			wantErr: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package praetorian
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIVersion prefixes the path of every versioned endpoint.
+const APIVersion = "/v1"
+
+// LegacyDeprecation marks the unversioned aliases of the /v1 endpoints, which
+// were deprecated when the versioned paths were introduced.
+var LegacyDeprecation = Deprecation{
+	Since: time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC),
+}
+
+// Deprecation describes an endpoint which clients should stop using. Since is
+// sent in the Deprecation header, Sunset, when set, in the Sunset header and
+// Successor in a Link header. Wildcards in Successor such as {id} are replaced
+// with the values from the request path.
+type Deprecation struct {
+	Since     time.Time
+	Sunset    time.Time
+	Successor string
+}
+
+// DeprecationStats counts the requests made to a deprecated endpoint, in
+// total and by caller, so the clients which still use it can be found.
+type DeprecationStats struct {
+	Pattern     string            `json:"pattern"`
+	Since       time.Time         `json:"since"`
+	Sunset      *time.Time        `json:"sunset,omitempty"`
+	Requests    uint64            `json:"requests"`
+	LastRequest time.Time         `json:"lastRequest"`
+	Callers     map[string]uint64 `json:"callers"`
+}
+
+// Deprecations records the use of deprecated endpoints.
+type Deprecations struct {
+	mu    sync.Mutex
+	usage map[string]*DeprecationStats
+	now   func() time.Time
+}
+
+// NewDeprecations returns an empty record of deprecated endpoint usage.
+func NewDeprecations() *Deprecations {
+	return &Deprecations{
+		usage: make(map[string]*DeprecationStats),
+		now:   time.Now,
+	}
+}
+
+// Deprecate marks the responses of next, served for the route pattern, with
+// the deprecation headers and counts each request.
+func (d *Deprecations) Deprecate(pattern string, dep Deprecation, next http.Handler) http.Handler {
+	d.mu.Lock()
+	d.usage[pattern] = &DeprecationStats{
+		Pattern: pattern,
+		Since:   dep.Since,
+		Callers: make(map[string]uint64),
+	}
+	if !dep.Sunset.IsZero() {
+		d.usage[pattern].Sunset = &dep.Sunset
+	}
+	d.mu.Unlock()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.record(pattern, caller(r))
+
+		w.Header().Set("Deprecation", "@"+strconv.FormatInt(dep.Since.Unix(), 10))
+		if !dep.Sunset.IsZero() {
+			w.Header().Set("Sunset", dep.Sunset.UTC().Format(http.TimeFormat))
+		}
+		if dep.Successor != "" {
+			w.Header().Set("Link", "<"+successor(r, dep.Successor)+`>; rel="successor-version"`)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Stats returns the usage of every deprecated endpoint, ordered by pattern.
+func (d *Deprecations) Stats() []DeprecationStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	stats := make([]DeprecationStats, 0, len(d.usage))
+	for _, u := range d.usage {
+		s := *u
+		s.Callers = make(map[string]uint64, len(u.Callers))
+		for c, n := range u.Callers {
+			s.Callers[c] = n
+		}
+		stats = append(stats, s)
+	}
+	slices.SortFunc(stats, func(a, b DeprecationStats) int {
+		return strings.Compare(a.Pattern, b.Pattern)
+	})
+	return stats
+}
+
+func (d *Deprecations) record(pattern, caller string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	u := d.usage[pattern]
+	u.Requests++
+	u.LastRequest = d.now().UTC()
+	u.Callers[caller]++
+}
+
+// successor fills the wildcards of the successor path from the request.
+func successor(r *http.Request, path string) string {
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			name := strings.TrimSuffix(strings.Trim(seg, "{}"), "...")
+			segments[i] = url.PathEscape(r.PathValue(name))
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// versioned returns the path of the versioned endpoint which serves path, so
+// that legacy aliases share the limits of their successors.
+func versioned(path string) string {
+	if path == APIVersion || strings.HasPrefix(path, APIVersion+"/") {
+		return path
+	}
+	return APIVersion + path
+}
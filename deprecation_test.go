@@ -0,0 +1,119 @@
+package praetorian_test
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/karlbateman/praetorian"
+)
+
+func TestLegacyRoutes(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	sunset := time.Date(2027, time.June, 1, 0, 0, 0, 0, time.UTC)
+	h := praetorian.NewServer(newTestKeystore(t, testConfig),
+		praetorian.WithLegacySunset(sunset),
+		praetorian.WithEndpoints(praetorian.EndpointWrap, praetorian.EndpointUnwrap, praetorian.EndpointPublicKey, praetorian.EndpointAdmin),
+		praetorian.WithAdminAuth(praetorian.AdminAuth{Token: "admin"}),
+	).Handler
+	wantDeprecation := "@" + strconv.FormatInt(praetorian.LegacyDeprecation.Since.Unix(), 10)
+
+	tests := []struct {
+		name            string
+		method          string
+		path            string
+		body            string
+		wantStatus      int
+		wantDeprecation string
+		wantSunset      string
+		wantLink        string
+	}{
+		{
+			name:       "versioned route",
+			method:     http.MethodPost,
+			path:       "/v1/wrap",
+			body:       `{"value": "keep it secret, keep it safe"}`,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:            "legacy route",
+			method:          http.MethodPost,
+			path:            "/wrap",
+			body:            `{"value": "keep it secret, keep it safe"}`,
+			wantStatus:      http.StatusCreated,
+			wantDeprecation: wantDeprecation,
+			wantSunset:      "Tue, 01 Jun 2027 00:00:00 GMT",
+			wantLink:        `</v1/wrap>; rel="successor-version"`,
+		},
+		{
+			name:            "legacy route with a wildcard",
+			method:          http.MethodGet,
+			path:            "/publickey/1",
+			wantStatus:      http.StatusNotFound,
+			wantDeprecation: wantDeprecation,
+			wantSunset:      "Tue, 01 Jun 2027 00:00:00 GMT",
+			wantLink:        `</v1/publickey/1>; rel="successor-version"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("ServeHTTP() status = %d, wantStatus = %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Deprecation"); got != tt.wantDeprecation {
+				t.Errorf("ServeHTTP() Deprecation = %q, want = %q", got, tt.wantDeprecation)
+			}
+			if got := rec.Header().Get("Sunset"); got != tt.wantSunset {
+				t.Errorf("ServeHTTP() Sunset = %q, want = %q", got, tt.wantSunset)
+			}
+			if got := rec.Header().Get("Link"); got != tt.wantLink {
+				t.Errorf("ServeHTTP() Link = %q, want = %q", got, tt.wantLink)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/admin/deprecations", nil)
+	req.Header.Set("Authorization", "Bearer admin")
+	h.ServeHTTP(rec, req)
+	var stats []praetorian.DeprecationStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatalf("GET /v1/admin/deprecations failed to parse response: %v", err)
+	}
+	got := make(map[string]praetorian.DeprecationStats)
+	for _, s := range stats {
+		got[s.Pattern] = s
+	}
+	if s := got["POST /wrap"]; s.Requests != 1 || s.Callers["192.0.2.1"] != 1 {
+		t.Errorf("DeprecationStats[POST /wrap] = %+v, want 1 request from 192.0.2.1", s)
+	}
+	if s := got["POST /unwrap"]; s.Requests != 0 || s.Sunset == nil || !s.Sunset.Equal(sunset) {
+		t.Errorf("DeprecationStats[POST /unwrap] = %+v, want no requests and the sunset", s)
+	}
+}
+
+func TestWithoutLegacyRoutes(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	h := praetorian.NewServer(&MockKeystore{}, praetorian.WithoutLegacyRoutes()).Handler
+	for path, want := range map[string]int{"/wrap": http.StatusNotFound, "/v1/wrap": http.StatusCreated} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"value": "keep it secret, keep it safe"}`)))
+		if rec.Code != want {
+			t.Errorf("POST %s status = %d, wantStatus = %d", path, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package praetorian
+
+import (
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+)
+
+const (
+	CurveP256   = "P-256"
+	CurveX25519 = "X25519"
+)
+
+// info string used to derive the envelope key from the ECDH shared secret.
+const ecdhWrapInfo = "praetorian ecdh wrap"
+
+// PublicRootKey is a root key whose public half can be handed to clients so
+// they can wrap data encryption keys without calling Praetorian.
+type PublicRootKey interface {
+	RootKey
+	Curve() string
+	PublicKey() *ecdh.PublicKey
+}
+
+type ecdhKey struct {
+	id    string
+	curve string
+	priv  *ecdh.PrivateKey
+}
+
+// ID is a getter which returns the keys unique identifier.
+func (k *ecdhKey) ID() string {
+	return k.id
+}
+
+// Curve returns the name of the elliptic curve used by the key.
+func (k *ecdhKey) Curve() string {
+	return k.curve
+}
+
+// PublicKey returns the public half of the key.
+func (k *ecdhKey) PublicKey() *ecdh.PublicKey {
+	return k.priv.PublicKey()
+}
+
+// Encrypt the given data to the public half of the key.
+func (k *ecdhKey) Encrypt(d []byte) ([]byte, error) {
+	return EncapsulateWrap(k.PublicKey(), d)
+}
+
+// Decrypt data which was encapsulated to the public half of the key.
+func (k *ecdhKey) Decrypt(d []byte) ([]byte, error) {
+	pub := k.PublicKey()
+	n := len(pub.Bytes())
+	if len(d) < n {
+		return nil, ErrGCMOpen
+	}
+	eph, err := pub.Curve().NewPublicKey(d[:n])
+	if err != nil {
+		return nil, ErrGCMOpen
+	}
+	shared, err := k.priv.ECDH(eph)
+	if err != nil {
+		return nil, ErrGCMOpen
+	}
+	defer clear(shared)
+
+	wk, err := ecdhWrapKey(shared, d[:n], pub.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return aeadOpen(wk, d[n:], nil)
+}
+
+// EncapsulateWrap encrypts data to an ECDH public key. An ephemeral key pair
+// is generated on the same curve and the AES-256-GCM key is derived from the
+// shared secret with HKDF-SHA256, salted with the ephemeral and recipient
+// public keys. The result is the ephemeral public key followed by the nonce
+// and ciphertext.
+func EncapsulateWrap(pub *ecdh.PublicKey, d []byte) ([]byte, error) {
+	eph, err := pub.Curve().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := eph.ECDH(pub)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(shared)
+
+	enc := eph.PublicKey().Bytes()
+	wk, err := ecdhWrapKey(shared, enc, pub.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	ci, err := aeadSeal(wk, d, nil)
+	if err != nil {
+		return nil, err
+	}
+	return append(enc, ci...), nil
+}
+
+func ecdhWrapKey(shared, enc, recipient []byte) ([]byte, error) {
+	salt := append(append([]byte{}, enc...), recipient...)
+	return hkdf.Key(sha256.New, shared, salt, ecdhWrapInfo, RootKeyLength)
+}
+
+func ecdhCurve(name string) (ecdh.Curve, error) {
+	switch name {
+	case CurveP256:
+		return ecdh.P256(), nil
+	case CurveX25519:
+		return ecdh.X25519(), nil
+	}
+	return nil, ErrUnsupportedCurve
+}
+
+func (c ECDHKeyConfig) privateKey() (*ecdh.PrivateKey, error) {
+	curve, err := ecdhCurve(c.Curve)
+	if err != nil {
+		return nil, err
+	}
+	priv, err := curve.NewPrivateKey(c.PrivateKey)
+	if err != nil {
+		return nil, ErrInvalidRootKey
+	}
+	return priv, nil
+}
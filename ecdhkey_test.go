@@ -0,0 +1,89 @@
+package praetorian_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+const (
+	testECDHConfig = `{"activeKeyId": "1", "rootKeys": {"1": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}, "ecdhKeys": {"2": {"curve": "X25519", "privateKey": "BPK//lj6hlpjuA5gPZo19OIjUDgnIQAAAAAAAAAAAAA="}, "3": {"curve": "P-256", "privateKey": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}}}`
+)
+
+func TestECDHKey_EncapsulateWrap(t *testing.T) {
+	tests := []struct {
+		name      string
+		id        string
+		wantCurve string
+	}{
+		{
+			name:      "x25519",
+			id:        "2",
+			wantCurve: praetorian.CurveX25519,
+		},
+		{
+			name:      "p256",
+			id:        "3",
+			wantCurve: praetorian.CurveP256,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks := newTestKeystore(t, testECDHConfig)
+			k, err := ks.Find(tt.id)
+			if err != nil {
+				t.Fatalf("Keystore.Find() failed to return key: %v", err)
+			}
+			pk, ok := k.(praetorian.PublicRootKey)
+			if !ok {
+				t.Fatalf("Keystore.Find() returned %T, want PublicRootKey", k)
+			}
+			if pk.Curve() != tt.wantCurve {
+				t.Errorf("PublicRootKey.Curve() = %q, wantCurve = %q", pk.Curve(), tt.wantCurve)
+			}
+
+			enc, err := praetorian.EncapsulateWrap(pk.PublicKey(), []byte("a secret never to be told"))
+			if err != nil {
+				t.Fatalf("EncapsulateWrap() failed to encrypt data: %v", err)
+			}
+
+			got, err := k.Decrypt(enc)
+			if err != nil {
+				t.Fatalf("Key.Decrypt() failed to decrypt data: %v", err)
+			}
+			if string(got) != "a secret never to be told" {
+				t.Errorf("Key.Decrypt() got = %q", got)
+			}
+
+			enc[len(enc)-1] ^= 1
+			if _, err := k.Decrypt(enc); !errors.Is(err, praetorian.ErrGCMOpen) {
+				t.Errorf("Key.Decrypt() error = %v, wantErr = %v", err, praetorian.ErrGCMOpen)
+			}
+			if _, err := k.Decrypt(enc[:8]); !errors.Is(err, praetorian.ErrGCMOpen) {
+				t.Errorf("Key.Decrypt() error = %v, wantErr = %v", err, praetorian.ErrGCMOpen)
+			}
+		})
+	}
+}
+
+func TestECDHKey_ActiveWrap(t *testing.T) {
+	ks := newTestKeystore(t, `{"activeKeyId": "2", "rootKeys": {}, "ecdhKeys": {"2": {"curve": "X25519", "privateKey": "BPK//lj6hlpjuA5gPZo19OIjUDgnIQAAAAAAAAAAAAA="}}}`)
+	k, err := ks.Find(praetorian.ActiveKeyID)
+	if err != nil {
+		t.Fatalf("Keystore.Find() failed to return active key: %v", err)
+	}
+
+	enc, err := k.Encrypt([]byte("a secret never to be told"))
+	if err != nil {
+		t.Fatalf("Key.Encrypt() failed to encrypt data: %v", err)
+	}
+	got, err := k.Decrypt(enc)
+	if err != nil {
+		t.Fatalf("Key.Decrypt() failed to decrypt data: %v", err)
+	}
+	if string(got) != "a secret never to be told" {
+		t.Errorf("Key.Decrypt() got = %q", got)
+	}
+}
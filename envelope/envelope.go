@@ -0,0 +1,223 @@
+// Package envelope implements client-side envelope encryption, sealing data
+// under a fresh AES-256-GCM data encryption key which is wrapped by Praetorian.
+package envelope
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+
+	"github.com/karlbateman/praetorian"
+)
+
+const (
+	// Version is the serialization format written by Marshal.
+	Version = 1
+
+	// DataKeyLength is the size of each AES-256 data encryption key.
+	DataKeyLength = 32
+)
+
+var (
+	ErrInvalidEnvelope = errors.New("unable to parse envelope")
+	ErrOpen            = errors.New("unable to open envelope")
+)
+
+// Wrapper wraps and unwraps raw data encryption keys. It is satisfied by the
+// HTTP client and by KeyFinderWrapper for in-process use.
+type Wrapper interface {
+	WrapKey(ctx context.Context, dek []byte) (*praetorian.WrapResponse, error)
+	UnwrapKey(ctx context.Context, w *praetorian.WrapResponse) ([]byte, error)
+}
+
+// Envelope holds encrypted data alongside its wrapped data encryption key.
+type Envelope struct {
+	KeyID      string
+	WrappedKey []byte
+	Ciphertext []byte
+}
+
+// Sealer encrypts and decrypts envelopes using the given Wrapper.
+type Sealer struct {
+	wrapper Wrapper
+}
+
+// New returns a Sealer which wraps data encryption keys with w.
+func New(w Wrapper) *Sealer {
+	return &Sealer{wrapper: w}
+}
+
+// Seal encrypts the plaintext under a new data encryption key, authenticating
+// aad which must be supplied again to Open.
+func (s *Sealer) Seal(ctx context.Context, plaintext, aad []byte) (*Envelope, error) {
+	dek := make([]byte, DataKeyLength)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+	defer clear(dek)
+
+	gcm, err := newGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := s.wrapper.WrapKey(ctx, dek)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(w.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Envelope{
+		KeyID:      w.ID,
+		WrappedKey: wrapped,
+		Ciphertext: gcm.Seal(nil, nil, plaintext, aad),
+	}, nil
+}
+
+// Open unwraps the data encryption key and decrypts the envelope.
+func (s *Sealer) Open(ctx context.Context, e *Envelope, aad []byte) ([]byte, error) {
+	dek, err := s.wrapper.UnwrapKey(ctx, &praetorian.WrapResponse{
+		ID:    e.KeyID,
+		Token: base64.StdEncoding.EncodeToString(e.WrappedKey),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer clear(dek)
+
+	gcm, err := newGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, nil, e.Ciphertext, aad)
+	if err != nil {
+		return nil, ErrOpen
+	}
+	return plaintext, nil
+}
+
+// Marshal encodes the envelope as the version byte, the length prefixed key
+// identifier and wrapped key, followed by the nonce and ciphertext.
+func (e *Envelope) Marshal() []byte {
+	b := make([]byte, 0, 1+2*binary.MaxVarintLen64+len(e.KeyID)+len(e.WrappedKey)+len(e.Ciphertext))
+	b = append(b, Version)
+	b = binary.AppendUvarint(b, uint64(len(e.KeyID)))
+	b = append(b, e.KeyID...)
+	b = binary.AppendUvarint(b, uint64(len(e.WrappedKey)))
+	b = append(b, e.WrappedKey...)
+	return append(b, e.Ciphertext...)
+}
+
+// Parse decodes an envelope produced by Marshal.
+func Parse(b []byte) (*Envelope, error) {
+	if len(b) == 0 || b[0] != Version {
+		return nil, ErrInvalidEnvelope
+	}
+	b = b[1:]
+
+	id, b, err := readField(b)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, b, err := readField(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Envelope{
+		KeyID:      string(id),
+		WrappedKey: wrapped,
+		Ciphertext: b,
+	}, nil
+}
+
+func readField(b []byte) ([]byte, []byte, error) {
+	n, size := binary.Uvarint(b)
+	if size <= 0 || n > uint64(len(b)-size) {
+		return nil, nil, ErrInvalidEnvelope
+	}
+	b = b[size:]
+	return append([]byte(nil), b[:n]...), b[n:], nil
+}
+
+func newBlock(dek []byte) (cipher.Block, error) {
+	block, err := aes.NewCipher(dek)
+	if err != nil {
+		return nil, praetorian.ErrNewCipherBlock
+	}
+	return block, nil
+}
+
+func newGCM(dek []byte) (cipher.AEAD, error) {
+	block, err := newBlock(dek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCMWithRandomNonce(block)
+	if err != nil {
+		return nil, praetorian.ErrNewGCMWithRandomNonce
+	}
+	return gcm, nil
+}
+
+type keyFinderWrapper struct {
+	keys      praetorian.KeyFinder
+	activeKey string
+}
+
+// KeyFinderWrapper wraps data encryption keys in-process using the active
+// root key of keys. The wrapped keys can also be unwrapped by the server.
+func KeyFinderWrapper(keys praetorian.KeyFinder) Wrapper {
+	return &keyFinderWrapper{keys: keys, activeKey: praetorian.ActiveKeyID}
+}
+
+func (w *keyFinderWrapper) WrapKey(_ context.Context, dek []byte) (*praetorian.WrapResponse, error) {
+	k, err := w.keys.Find(w.activeKey)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(&praetorian.DataKey{Key: dek})
+	if err != nil {
+		return nil, err
+	}
+	defer clear(b)
+
+	enc, err := k.Encrypt(b)
+	if err != nil {
+		return nil, err
+	}
+	return &praetorian.WrapResponse{
+		ID:    k.ID(),
+		Token: base64.StdEncoding.EncodeToString(enc),
+	}, nil
+}
+
+func (w *keyFinderWrapper) UnwrapKey(_ context.Context, wr *praetorian.WrapResponse) ([]byte, error) {
+	k, err := w.keys.Find(wr.ID)
+	if err != nil {
+		return nil, err
+	}
+	token, err := base64.StdEncoding.DecodeString(wr.Token)
+	if err != nil {
+		return nil, err
+	}
+	dec, err := k.Decrypt(token)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(dec)
+
+	var dk praetorian.DataKey
+	if err := json.Unmarshal(dec, &dk); err != nil {
+		return nil, err
+	}
+	return dk.Key, nil
+}
@@ -0,0 +1,160 @@
+package envelope_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+	"github.com/karlbateman/praetorian/client"
+	"github.com/karlbateman/praetorian/envelope"
+)
+
+const (
+	testConfig = `{"activeKeyId": "1", "rootKeys": {"1": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}}`
+)
+
+func newTestKeystore(t *testing.T) praetorian.KeyFinder {
+	t.Helper()
+	t.Setenv(praetorian.EnvKey, testConfig)
+	cfg, err := praetorian.NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig() failed to create config: %v", err)
+	}
+	ks, err := praetorian.NewKeystore(cfg)
+	if err != nil {
+		t.Fatalf("NewKeystore() failed to create keystore: %v", err)
+	}
+	return ks
+}
+
+func TestSealer_SealOpen(t *testing.T) {
+	ks := newTestKeystore(t)
+	srv := httptest.NewServer(praetorian.NewServer(ks).Handler)
+	defer srv.Close()
+
+	local := envelope.KeyFinderWrapper(ks)
+	remote := client.New(srv.URL)
+
+	tests := []struct {
+		name string
+		seal envelope.Wrapper
+		open envelope.Wrapper
+	}{
+		{
+			name: "in-process",
+			seal: local,
+			open: local,
+		},
+		{
+			name: "http client",
+			seal: remote,
+			open: remote,
+		},
+		{
+			name: "sealed in-process and opened remotely",
+			seal: local,
+			open: remote,
+		},
+		{
+			name: "sealed remotely and opened in-process",
+			seal: remote,
+			open: local,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			plaintext := []byte("a secret never to be told")
+			aad := []byte("users/42")
+
+			e, err := envelope.New(tt.seal).Seal(ctx, plaintext, aad)
+			if err != nil {
+				t.Fatalf("Sealer.Seal() failed to seal data: %v", err)
+			}
+
+			parsed, err := envelope.Parse(e.Marshal())
+			if err != nil {
+				t.Fatalf("Parse() failed to parse envelope: %v", err)
+			}
+
+			got, err := envelope.New(tt.open).Open(ctx, parsed, aad)
+			if err != nil {
+				t.Fatalf("Sealer.Open() failed to open envelope: %v", err)
+			}
+			if string(got) != string(plaintext) {
+				t.Errorf("Sealer.Open() got = %q, want = %q", got, plaintext)
+			}
+
+			if _, err := envelope.New(tt.open).Open(ctx, parsed, []byte("users/43")); !errors.Is(err, envelope.ErrOpen) {
+				t.Errorf("Sealer.Open() error = %v, wantErr = %v", err, envelope.ErrOpen)
+			}
+		})
+	}
+}
+
+func TestSealer_OpenTampered(t *testing.T) {
+	ks := newTestKeystore(t)
+	s := envelope.New(envelope.KeyFinderWrapper(ks))
+	ctx := context.Background()
+
+	e, err := s.Seal(ctx, []byte("a secret never to be told"), nil)
+	if err != nil {
+		t.Fatalf("Sealer.Seal() failed to seal data: %v", err)
+	}
+
+	e.Ciphertext[len(e.Ciphertext)-1] ^= 1
+	if _, err := s.Open(ctx, e, nil); !errors.Is(err, envelope.ErrOpen) {
+		t.Errorf("Sealer.Open() error = %v, wantErr = %v", err, envelope.ErrOpen)
+	}
+
+	e.WrappedKey[len(e.WrappedKey)-1] ^= 1
+	if _, err := s.Open(ctx, e, nil); !errors.Is(err, praetorian.ErrGCMOpen) {
+		t.Errorf("Sealer.Open() error = %v, wantErr = %v", err, praetorian.ErrGCMOpen)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{
+			name:    "empty",
+			data:    nil,
+			wantErr: envelope.ErrInvalidEnvelope,
+		},
+		{
+			name:    "unknown version",
+			data:    []byte{2, 1, '1', 0},
+			wantErr: envelope.ErrInvalidEnvelope,
+		},
+		{
+			name:    "truncated key identifier",
+			data:    []byte{1, 5, '1'},
+			wantErr: envelope.ErrInvalidEnvelope,
+		},
+		{
+			name:    "missing wrapped key",
+			data:    []byte{1, 1, '1'},
+			wantErr: envelope.ErrInvalidEnvelope,
+		},
+		{
+			name:    "valid",
+			data:    (&envelope.Envelope{KeyID: "1", WrappedKey: []byte("key"), Ciphertext: []byte("data")}).Marshal(),
+			wantErr: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := envelope.Parse(tt.data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Parse() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
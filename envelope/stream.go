@@ -0,0 +1,291 @@
+package envelope
+
+import (
+	"bufio"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/karlbateman/praetorian"
+)
+
+const (
+	// ChunkSize is the amount of plaintext sealed in each stream chunk.
+	ChunkSize = 64 << 10
+
+	// the nonce is a random prefix, a big endian chunk counter and a flag
+	// which marks the final chunk.
+	noncePrefixSize = 7
+	nonceSize       = noncePrefixSize + 4 + 1
+	tagSize         = 16
+
+	maxKeyIDLength      = 1 << 10
+	maxWrappedKeyLength = 1 << 16
+)
+
+// streamMagic identifies the streaming format and its version.
+var streamMagic = []byte("PRS\x01")
+
+var (
+	ErrInvalidStream = errors.New("unable to parse stream header")
+	ErrStreamTooLong = errors.New("stream exceeds the maximum number of chunks")
+)
+
+// NewWriter returns a writer which encrypts everything written to it into w
+// under a new data encryption key. The header, containing the wrapped key, is
+// written immediately. Close must be called to write the final chunk.
+func (s *Sealer) NewWriter(ctx context.Context, w io.Writer) (io.WriteCloser, error) {
+	dek := make([]byte, DataKeyLength)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+	defer clear(dek)
+
+	wr, err := s.wrapper.WrapKey(ctx, dek)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(wr.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := make([]byte, noncePrefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+
+	header := append([]byte(nil), streamMagic...)
+	header = binary.AppendUvarint(header, uint64(len(wr.ID)))
+	header = append(header, wr.ID...)
+	header = binary.AppendUvarint(header, uint64(len(wrapped)))
+	header = append(header, wrapped...)
+	header = append(header, prefix...)
+
+	aead, err := newStreamAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &streamWriter{
+		w:      w,
+		aead:   aead,
+		header: header,
+		prefix: prefix,
+		buf:    make([]byte, 0, ChunkSize),
+	}, nil
+}
+
+// NewReader returns a reader which decrypts a stream produced by NewWriter.
+// Data is only returned once the chunk containing it has been authenticated,
+// and a stream which is truncated or reordered results in ErrOpen.
+func (s *Sealer) NewReader(ctx context.Context, r io.Reader) (io.Reader, error) {
+	br := bufio.NewReaderSize(r, ChunkSize+tagSize)
+
+	magic := make([]byte, len(streamMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != string(streamMagic) {
+		return nil, ErrInvalidStream
+	}
+	header := append([]byte(nil), magic...)
+
+	id, err := readStreamField(br, maxKeyIDLength, &header)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := readStreamField(br, maxWrappedKeyLength, &header)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, noncePrefixSize)
+	if _, err := io.ReadFull(br, prefix); err != nil {
+		return nil, ErrInvalidStream
+	}
+	header = append(header, prefix...)
+
+	dek, err := s.wrapper.UnwrapKey(ctx, &praetorian.WrapResponse{
+		ID:    string(id),
+		Token: base64.StdEncoding.EncodeToString(wrapped),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer clear(dek)
+
+	aead, err := newStreamAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	return &streamReader{
+		r:      br,
+		aead:   aead,
+		header: header,
+		prefix: prefix,
+		chunk:  make([]byte, ChunkSize+tagSize),
+	}, nil
+}
+
+type streamWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	prefix  []byte
+	buf     []byte
+	counter uint32
+	closed  bool
+	err     error
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	if sw.err != nil {
+		return 0, sw.err
+	}
+
+	n := 0
+	for len(p) > 0 {
+		// a full buffer is only sealed once more data arrives, as the last
+		// chunk must be marked final when the writer is closed.
+		if len(sw.buf) == ChunkSize {
+			if sw.err = sw.seal(false); sw.err != nil {
+				return n, sw.err
+			}
+		}
+		c := copy(sw.buf[len(sw.buf):ChunkSize], p)
+		sw.buf = sw.buf[:len(sw.buf)+c]
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+func (sw *streamWriter) Close() error {
+	if sw.closed {
+		return nil
+	}
+	sw.closed = true
+	if sw.err != nil {
+		return sw.err
+	}
+	if err := sw.seal(true); err != nil {
+		sw.err = err
+		return err
+	}
+	// further writes would follow the final chunk.
+	sw.err = io.ErrClosedPipe
+	return nil
+}
+
+func (sw *streamWriter) seal(final bool) error {
+	nonce, err := streamNonce(sw.prefix, sw.counter, final)
+	if err != nil {
+		return err
+	}
+	out := sw.aead.Seal(nil, nonce, sw.buf, sw.header)
+	clear(sw.buf)
+	sw.buf = sw.buf[:0]
+	sw.counter++
+
+	_, err = sw.w.Write(out)
+	return err
+}
+
+type streamReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	header  []byte
+	prefix  []byte
+	chunk   []byte
+	plain   []byte
+	counter uint32
+	done    bool
+	err     error
+}
+
+func (sr *streamReader) Read(p []byte) (int, error) {
+	for len(sr.plain) == 0 {
+		if sr.err != nil {
+			return 0, sr.err
+		}
+		if sr.done {
+			return 0, io.EOF
+		}
+		sr.err = sr.open()
+	}
+	n := copy(p, sr.plain)
+	sr.plain = sr.plain[n:]
+	return n, nil
+}
+
+func (sr *streamReader) open() error {
+	n, err := io.ReadFull(sr.r, sr.chunk)
+	final := false
+	switch {
+	case err == io.ErrUnexpectedEOF || err == io.EOF:
+		final = true
+	case err != nil:
+		return err
+	default:
+		if _, err := sr.r.Peek(1); err == io.EOF {
+			final = true
+		}
+	}
+
+	nonce, err := streamNonce(sr.prefix, sr.counter, final)
+	if err != nil {
+		return err
+	}
+	plain, err := sr.aead.Open(sr.chunk[:0], nonce, sr.chunk[:n], sr.header)
+	if err != nil {
+		return ErrOpen
+	}
+	sr.counter++
+	sr.plain = plain
+	sr.done = final
+	return nil
+}
+
+func streamNonce(prefix []byte, counter uint32, final bool) ([]byte, error) {
+	if counter == ^uint32(0) {
+		return nil, ErrStreamTooLong
+	}
+	nonce := make([]byte, nonceSize)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[noncePrefixSize:], counter)
+	if final {
+		nonce[nonceSize-1] = 1
+	}
+	return nonce, nil
+}
+
+func readStreamField(r *bufio.Reader, limit uint64, header *[]byte) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil || n > limit {
+		return nil, ErrInvalidStream
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, ErrInvalidStream
+	}
+	*header = binary.AppendUvarint(*header, n)
+	*header = append(*header, b...)
+	return b, nil
+}
+
+func newStreamAEAD(dek []byte) (cipher.AEAD, error) {
+	block, err := newBlock(dek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, praetorian.ErrNewGCMWithRandomNonce
+	}
+	return gcm, nil
+}
@@ -0,0 +1,140 @@
+package envelope_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/karlbateman/praetorian/envelope"
+)
+
+func TestSealer_Stream(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+	}{
+		{
+			name: "empty",
+			size: 0,
+		},
+		{
+			name: "partial chunk",
+			size: 100,
+		},
+		{
+			name: "exact chunk",
+			size: envelope.ChunkSize,
+		},
+		{
+			name: "multiple chunks",
+			size: 3*envelope.ChunkSize + 17,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := envelope.New(envelope.KeyFinderWrapper(newTestKeystore(t)))
+			ctx := context.Background()
+
+			plaintext := make([]byte, tt.size)
+			if _, err := rand.Read(plaintext); err != nil {
+				t.Fatalf("rand.Read() failed: %v", err)
+			}
+
+			var buf bytes.Buffer
+			w, err := s.NewWriter(ctx, &buf)
+			if err != nil {
+				t.Fatalf("Sealer.NewWriter() failed to create writer: %v", err)
+			}
+			// small writes exercise the chunk buffering.
+			if _, err := io.CopyBuffer(w, bytes.NewReader(plaintext), make([]byte, 1000)); err != nil {
+				t.Fatalf("io.Copy() failed to encrypt stream: %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("streamWriter.Close() failed: %v", err)
+			}
+
+			r, err := s.NewReader(ctx, &buf)
+			if err != nil {
+				t.Fatalf("Sealer.NewReader() failed to create reader: %v", err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("io.ReadAll() failed to decrypt stream: %v", err)
+			}
+			if !bytes.Equal(got, plaintext) {
+				t.Errorf("Sealer.NewReader() got %d bytes, want %d bytes", len(got), len(plaintext))
+			}
+		})
+	}
+}
+
+func TestSealer_StreamTampered(t *testing.T) {
+	s := envelope.New(envelope.KeyFinderWrapper(newTestKeystore(t)))
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	w, err := s.NewWriter(ctx, &buf)
+	if err != nil {
+		t.Fatalf("Sealer.NewWriter() failed to create writer: %v", err)
+	}
+	if _, err := w.Write(make([]byte, 2*envelope.ChunkSize+10)); err != nil {
+		t.Fatalf("streamWriter.Write() failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("streamWriter.Close() failed: %v", err)
+	}
+	stream := buf.Bytes()
+	chunk := envelope.ChunkSize + 16
+	header := len(stream) - 2*chunk - 26
+
+	swapped := append([]byte(nil), stream[:header]...)
+	swapped = append(swapped, stream[header+chunk:header+2*chunk]...)
+	swapped = append(swapped, stream[header:header+chunk]...)
+	swapped = append(swapped, stream[header+2*chunk:]...)
+
+	flipped := append([]byte(nil), stream...)
+	flipped[len(flipped)-1] ^= 1
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{
+			name:    "truncated at chunk boundary",
+			data:    stream[:header+2*chunk],
+			wantErr: envelope.ErrOpen,
+		},
+		{
+			name:    "reordered chunks",
+			data:    swapped,
+			wantErr: envelope.ErrOpen,
+		},
+		{
+			name:    "modified final chunk",
+			data:    flipped,
+			wantErr: envelope.ErrOpen,
+		},
+		{
+			name:    "invalid header",
+			data:    []byte("not a stream"),
+			wantErr: envelope.ErrInvalidStream,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := s.NewReader(ctx, bytes.NewReader(tt.data))
+			if err == nil {
+				_, err = io.ReadAll(r)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Sealer.NewReader() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
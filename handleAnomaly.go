@@ -0,0 +1,25 @@
+package praetorian
+
+import (
+	"net/http"
+)
+
+// HandleLockouts reports the unwrap anomaly counters and locked out callers.
+func HandleLockouts(d *AnomalyDetector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jsonResponse(w, http.StatusOK, d.Stats())
+	}
+}
+
+// HandleClearLockout allows a locked out caller to unwrap keys again.
+func HandleClearLockout(d *AnomalyDetector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !d.Clear(r.PathValue("caller")) {
+			jsonResponse(w, http.StatusNotFound, &ErrorResponse{
+				Message: "caller is not locked out",
+			})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
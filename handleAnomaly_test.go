@@ -0,0 +1,141 @@
+package praetorian_test
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+func TestHandleLockouts(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	d := praetorian.NewAnomalyDetector(praetorian.AnomalyDetection{MaxCallerUnwraps: 1, Lockout: true}, nil)
+	d.Observe("10.0.0.1", "1")
+	d.Observe("10.0.0.1", "1")
+
+	rec := httptest.NewRecorder()
+	praetorian.HandleLockouts(d).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/lockouts", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("HandleLockouts() status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var stats praetorian.AnomalyStats
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("HandleLockouts() failed to parse response: %v", err)
+	}
+	if stats.Unwraps != 2 || stats.Anomalies != 1 || len(stats.Lockouts) != 1 || stats.Lockouts[0].Caller != "10.0.0.1" {
+		t.Errorf("HandleLockouts() = %+v", stats)
+	}
+}
+
+func TestHandleClearLockout(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	d := praetorian.NewAnomalyDetector(praetorian.AnomalyDetection{MaxCallerUnwraps: 1, Lockout: true}, nil)
+	d.Observe("10.0.0.1", "1")
+	d.Observe("10.0.0.1", "1")
+
+	tests := []struct {
+		name       string
+		method     string
+		caller     string
+		wantStatus int
+	}{
+		{name: "locked caller", method: http.MethodDelete, caller: "10.0.0.1", wantStatus: http.StatusNoContent},
+		{name: "already cleared", method: http.MethodDelete, caller: "10.0.0.1", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/admin/lockouts/"+tt.caller, nil)
+			req.SetPathValue("caller", tt.caller)
+			rec := httptest.NewRecorder()
+			praetorian.HandleClearLockout(d).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("HandleClearLockout() status = %d, wantStatus = %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestLockoutRoutes_AdminAuth(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	h := praetorian.NewServer(newTestKeystore(t, testConfig),
+		praetorian.WithEndpoints(praetorian.EndpointWrap, praetorian.EndpointUnwrap, praetorian.EndpointAdmin),
+		praetorian.WithAdminAuth(praetorian.AdminAuth{Token: "s3cret"}),
+		praetorian.WithAnomalyDetection(praetorian.AnomalyDetection{MaxCallerUnwraps: 1, Lockout: true}, nil),
+	).Handler
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		auth       string
+		wantStatus int
+	}{
+		{name: "list without token", method: http.MethodGet, path: "/v1/admin/lockouts", wantStatus: http.StatusUnauthorized},
+		{name: "list with invalid token", method: http.MethodGet, path: "/v1/admin/lockouts", auth: "Bearer s3cre", wantStatus: http.StatusUnauthorized},
+		{name: "list with token", method: http.MethodGet, path: "/v1/admin/lockouts", auth: "Bearer s3cret", wantStatus: http.StatusOK},
+		{name: "clear without token", method: http.MethodDelete, path: "/v1/admin/lockouts/10.0.0.1", wantStatus: http.StatusUnauthorized},
+		{name: "clear with token", method: http.MethodDelete, path: "/v1/admin/lockouts/10.0.0.1", auth: "Bearer s3cret", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("%s %s status = %d, wantStatus = %d", tt.method, tt.path, rec.Code, tt.wantStatus)
+			}
+		})
+	}
+
+	// a locked out caller can still reach the health checks and clear its own
+	// lockout with the admin token.
+	serve := func(method, path, body, auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	wrapped := serve(http.MethodPost, "/v1/wrap", `{"key": "secret"}`, "").Body.String()
+
+	steps := []struct {
+		method     string
+		path       string
+		body       string
+		auth       string
+		wantStatus int
+	}{
+		{method: http.MethodPost, path: "/v1/unwrap", body: wrapped, wantStatus: http.StatusOK},
+		{method: http.MethodPost, path: "/v1/unwrap", body: wrapped, wantStatus: http.StatusForbidden},
+		{method: http.MethodGet, path: "/healthz", wantStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/readyz", wantStatus: http.StatusOK},
+		{method: http.MethodDelete, path: "/v1/admin/lockouts/192.0.2.1", wantStatus: http.StatusUnauthorized},
+		{method: http.MethodDelete, path: "/v1/admin/lockouts/192.0.2.1", auth: "Bearer s3cret", wantStatus: http.StatusNoContent},
+		{method: http.MethodPost, path: "/v1/unwrap", body: wrapped, wantStatus: http.StatusOK},
+	}
+	for _, st := range steps {
+		if rec := serve(st.method, st.path, st.body, st.auth); rec.Code != st.wantStatus {
+			t.Errorf("%s %s status = %d, wantStatus = %d", st.method, st.path, rec.Code, st.wantStatus)
+		}
+	}
+}
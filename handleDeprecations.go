@@ -0,0 +1,11 @@
+package praetorian
+
+import "net/http"
+
+// HandleDeprecations reports how often each deprecated endpoint is used and
+// by which callers.
+func HandleDeprecations(d *Deprecations) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jsonResponse(w, http.StatusOK, d.Stats())
+	}
+}
@@ -0,0 +1,37 @@
+package praetorian
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// HealthResponse reports whether the server is able to take requests, and
+// whether its keystore is still waiting to be unsealed.
+type HealthResponse struct {
+	Status string `json:"status"`
+	Sealed bool   `json:"sealed,omitempty"`
+}
+
+// HandleHealth reports that the process is running.
+func HandleHealth() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jsonResponse(w, http.StatusOK, &HealthResponse{Status: "ok"})
+	}
+}
+
+// HandleReady reports whether the server should be sent requests. It stops
+// being ready once draining is set. A sealed keystore is reported without
+// failing the check, so that the unseal endpoint stays reachable.
+func HandleReady(keys KeyFinder, draining *atomic.Bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if draining.Load() {
+			jsonResponse(w, http.StatusServiceUnavailable, &HealthResponse{Status: "draining"})
+			return
+		}
+		res := &HealthResponse{Status: "ready"}
+		if u, ok := keys.(Unsealer); ok {
+			res.Sealed = u.Sealed()
+		}
+		jsonResponse(w, http.StatusOK, res)
+	}
+}
@@ -0,0 +1,79 @@
+package praetorian_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+func TestHandleHealth(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	rec := httptest.NewRecorder()
+	praetorian.HandleHealth().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("HandleHealth() status = %d, wantStatus = %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestHandleReady(t *testing.T) {
+	sealed := &struct {
+		*MockKeystore
+		*MockUnsealer
+	}{&MockKeystore{}, &MockUnsealer{}}
+
+	tests := []struct {
+		name       string
+		keys       praetorian.KeyFinder
+		draining   bool
+		wantStatus int
+		wantBody   string
+		wantSealed bool
+	}{
+		{
+			name:       "ready",
+			keys:       &MockKeystore{},
+			wantStatus: http.StatusOK,
+			wantBody:   "ready",
+		},
+		{
+			name:       "draining",
+			keys:       &MockKeystore{},
+			draining:   true,
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   "draining",
+		},
+		{
+			name:       "sealed",
+			keys:       sealed,
+			wantStatus: http.StatusOK,
+			wantBody:   "ready",
+			wantSealed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var draining atomic.Bool
+			draining.Store(tt.draining)
+
+			req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+			rec := httptest.NewRecorder()
+			praetorian.HandleReady(tt.keys, &draining).ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("HandleReady() status = %d, wantStatus = %d", rec.Code, tt.wantStatus)
+			}
+
+			var res praetorian.HealthResponse
+			if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+				t.Fatalf("HandleReady() failed to parse response: %v", err)
+			}
+			if res.Status != tt.wantBody || res.Sealed != tt.wantSealed {
+				t.Errorf("HandleReady() = %+v, want status %q and sealed %t", res, tt.wantBody, tt.wantSealed)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package praetorian
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"net/http"
+)
+
+// JWK is the JSON Web Key representation of a public key.
+type JWK struct {
+	KeyID     string `json:"kid"`
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	Algorithm string `json:"alg,omitempty"`
+	Use       string `json:"use"`
+	X         string `json:"x"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKSet is a JSON Web Key Set.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// HandleJWK exports the public half of a signing key or ECDH root key as a
+// JSON Web Key Set.
+func HandleJWK(signers SignerFinder, keys KeyFinder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		var jwk *JWK
+		sk, err := signers.FindSigner(id)
+		if err == nil {
+			jwk = signingJWK(sk)
+		} else if errors.Is(err, ErrSigningKeyNotFound) {
+			var rk RootKey
+			rk, err = keys.Find(id)
+			if pk, ok := rk.(PublicRootKey); ok && err == nil {
+				jwk = ecdhJWK(pk)
+			} else if err == nil {
+				err = ErrNoPublicKey
+			}
+		}
+
+		if err != nil {
+			if errors.Is(err, ErrKeystoreSealed) {
+				jsonResponse(w, http.StatusServiceUnavailable, &ErrorResponse{
+					Message: err.Error(),
+				})
+				return
+			}
+			jsonResponse(w, http.StatusNotFound, &ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, &JWKSet{Keys: []JWK{*jwk}})
+	}
+}
+
+func signingJWK(k SigningKey) *JWK {
+	jwk := &JWK{KeyID: k.ID(), Algorithm: k.Algorithm(), Use: "sig"}
+	switch pub := k.Public().(type) {
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	case *ecdsa.PublicKey:
+		jwk.KeyType = "EC"
+		jwk.Curve = CurveP256
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, 32)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, 32)))
+	}
+	return jwk
+}
+
+func ecdhJWK(k PublicRootKey) *JWK {
+	jwk := &JWK{KeyID: k.ID(), Curve: k.Curve(), Use: "enc"}
+	pub := k.PublicKey().Bytes()
+	switch k.Curve() {
+	case CurveX25519:
+		jwk.KeyType = "OKP"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	case CurveP256:
+		jwk.KeyType = "EC"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub[1:33])
+		jwk.Y = base64.RawURLEncoding.EncodeToString(pub[33:])
+	}
+	return jwk
+}
@@ -0,0 +1,91 @@
+package praetorian_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+func TestHandleJWK(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     string
+		id         string
+		wantStatus int
+		wantJWK    praetorian.JWK
+	}{
+		{
+			name:       "ed25519 signing key",
+			config:     testSigningConfig,
+			id:         "4",
+			wantStatus: http.StatusOK,
+			wantJWK:    praetorian.JWK{KeyID: "4", KeyType: "OKP", Curve: "Ed25519", Algorithm: "EdDSA", Use: "sig"},
+		},
+		{
+			name:       "ecdsa signing key",
+			config:     testSigningConfig,
+			id:         "5",
+			wantStatus: http.StatusOK,
+			wantJWK:    praetorian.JWK{KeyID: "5", KeyType: "EC", Curve: "P-256", Algorithm: "ES256", Use: "sig"},
+		},
+		{
+			name:       "ecdh root key",
+			config:     testECDHConfig,
+			id:         "2",
+			wantStatus: http.StatusOK,
+			wantJWK:    praetorian.JWK{KeyID: "2", KeyType: "OKP", Curve: "X25519", Use: "enc"},
+		},
+		{
+			name:       "symmetric root key",
+			config:     testSigningConfig,
+			id:         "1",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "missing key",
+			config:     testSigningConfig,
+			id:         "missing",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks := newTestKeystore(t, tt.config)
+			handler := praetorian.HandleJWK(ks.(praetorian.SignerFinder), ks)
+
+			req := httptest.NewRequest(http.MethodGet, "/keys/"+tt.id+"/public", nil)
+			req.SetPathValue("id", tt.id)
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("HandleJWK() status = %d, wantStatus = %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var res praetorian.JWKSet
+			if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+				t.Fatalf("HandleJWK() failed to parse response: %v", err)
+			}
+			if len(res.Keys) != 1 {
+				t.Fatalf("HandleJWK() keys = %d, want = 1", len(res.Keys))
+			}
+
+			got := res.Keys[0]
+			if got.X == "" {
+				t.Errorf("HandleJWK() x coordinate is empty")
+			}
+			got.X, got.Y = "", ""
+			if got != tt.wantJWK {
+				t.Errorf("HandleJWK() got = %+v, want = %+v", got, tt.wantJWK)
+			}
+		})
+	}
+}
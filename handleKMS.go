@@ -0,0 +1,372 @@
+package praetorian
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Environment variables which enable the AWS KMS compatible listener.
+// Aliases are given as a comma separated list of alias/name=id pairs.
+const (
+	EnvKMSAddr    = "PRAETORIAN_KMS_ADDR"
+	EnvKMSAliases = "PRAETORIAN_KMS_ALIASES"
+)
+
+const (
+	kmsTargetPrefix     = "TrentService."
+	kmsContentType      = "application/x-amz-json-1.1"
+	kmsAlgorithm        = "SYMMETRIC_DEFAULT"
+	kmsMaxPlaintext     = 4096
+	kmsMaxDataKeyLength = 1024
+)
+
+// KMSEncryptRequest is the body of TrentService.Encrypt.
+type KMSEncryptRequest struct {
+	KeyID             string            `json:"KeyId"`
+	Plaintext         []byte            `json:"Plaintext"`
+	EncryptionContext map[string]string `json:"EncryptionContext,omitempty"`
+}
+
+// KMSEncryptResponse is returned by Encrypt and ReEncrypt.
+type KMSEncryptResponse struct {
+	KeyID                          string `json:"KeyId"`
+	CiphertextBlob                 []byte `json:"CiphertextBlob"`
+	EncryptionAlgorithm            string `json:"EncryptionAlgorithm,omitempty"`
+	SourceKeyID                    string `json:"SourceKeyId,omitempty"`
+	SourceEncryptionAlgorithm      string `json:"SourceEncryptionAlgorithm,omitempty"`
+	DestinationEncryptionAlgorithm string `json:"DestinationEncryptionAlgorithm,omitempty"`
+}
+
+// KMSDecryptRequest is the body of TrentService.Decrypt.
+type KMSDecryptRequest struct {
+	KeyID             string            `json:"KeyId,omitempty"`
+	CiphertextBlob    []byte            `json:"CiphertextBlob"`
+	EncryptionContext map[string]string `json:"EncryptionContext,omitempty"`
+}
+
+// KMSDecryptResponse is the response of TrentService.Decrypt.
+type KMSDecryptResponse struct {
+	KeyID               string `json:"KeyId"`
+	Plaintext           []byte `json:"Plaintext"`
+	EncryptionAlgorithm string `json:"EncryptionAlgorithm"`
+}
+
+// KMSGenerateDataKeyRequest is the body of TrentService.GenerateDataKey.
+type KMSGenerateDataKeyRequest struct {
+	KeyID             string            `json:"KeyId"`
+	KeySpec           string            `json:"KeySpec,omitempty"`
+	NumberOfBytes     int               `json:"NumberOfBytes,omitempty"`
+	EncryptionContext map[string]string `json:"EncryptionContext,omitempty"`
+}
+
+// KMSGenerateDataKeyResponse is the response of TrentService.GenerateDataKey.
+type KMSGenerateDataKeyResponse struct {
+	KeyID          string `json:"KeyId"`
+	Plaintext      []byte `json:"Plaintext"`
+	CiphertextBlob []byte `json:"CiphertextBlob"`
+}
+
+// KMSReEncryptRequest is the body of TrentService.ReEncrypt.
+type KMSReEncryptRequest struct {
+	CiphertextBlob               []byte            `json:"CiphertextBlob"`
+	SourceKeyID                  string            `json:"SourceKeyId,omitempty"`
+	SourceEncryptionContext      map[string]string `json:"SourceEncryptionContext,omitempty"`
+	DestinationKeyID             string            `json:"DestinationKeyId"`
+	DestinationEncryptionContext map[string]string `json:"DestinationEncryptionContext,omitempty"`
+}
+
+// KMSError is the body of an error response, identified by its type.
+type KMSError struct {
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+}
+
+func (e *KMSError) Error() string {
+	return e.Message
+}
+
+// KMSAliasesFromEnv returns the alias to root key ID mapping from the
+// environment.
+func KMSAliasesFromEnv() (map[string]string, error) {
+	aliases := make(map[string]string)
+	val := os.Getenv(EnvKMSAliases)
+	if val == "" {
+		return aliases, nil
+	}
+	for _, pair := range strings.Split(val, ",") {
+		alias, id, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || !strings.HasPrefix(alias, "alias/") || id == "" {
+			return nil, fmt.Errorf("invalid KMS alias %q", pair)
+		}
+		aliases[alias] = id
+	}
+	return aliases, nil
+}
+
+// HandleKMS serves the subset of the AWS KMS JSON protocol used for envelope
+// encryption. Ciphertext blobs carry the root key ID, and the encryption
+// context is bound to the ciphertext by encrypting its digest alongside the
+// plaintext. Request signatures are not verified.
+func HandleKMS(keys KeyFinder, aliases map[string]string) http.HandlerFunc {
+	k := &kms{keys: keys, aliases: aliases}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			kmsResponse(w, http.StatusMethodNotAllowed, &KMSError{"UnsupportedOperationException", "method not allowed"})
+			return
+		}
+
+		maxBytes := maxBodyBytes(r)
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBytes))
+		var (
+			res any
+			err error
+		)
+		switch target := r.Header.Get("X-Amz-Target"); target {
+		case kmsTargetPrefix + "Encrypt":
+			var req KMSEncryptRequest
+			if err = kmsDecode(dec, &req); err == nil {
+				res, err = k.encrypt(r, &req)
+			}
+		case kmsTargetPrefix + "Decrypt":
+			var req KMSDecryptRequest
+			if err = kmsDecode(dec, &req); err == nil {
+				res, err = k.decrypt(r, &req)
+			}
+		case kmsTargetPrefix + "GenerateDataKey":
+			var req KMSGenerateDataKeyRequest
+			if err = kmsDecode(dec, &req); err == nil {
+				res, err = k.generateDataKey(r, &req)
+			}
+		case kmsTargetPrefix + "ReEncrypt":
+			var req KMSReEncryptRequest
+			if err = kmsDecode(dec, &req); err == nil {
+				res, err = k.reEncrypt(r, &req)
+			}
+		default:
+			err = &KMSError{"UnknownOperationException", fmt.Sprintf("unsupported operation %q", target)}
+		}
+
+		if err != nil {
+			var ke *KMSError
+			if !errors.As(err, &ke) {
+				ke = &KMSError{"KMSInternalException", err.Error()}
+			}
+			kmsResponse(w, kmsStatus(ke.Type), ke)
+			return
+		}
+		kmsResponse(w, http.StatusOK, res)
+	}
+}
+
+type kms struct {
+	keys    KeyFinder
+	aliases map[string]string
+}
+
+func (k *kms) encrypt(r *http.Request, req *KMSEncryptRequest) (*KMSEncryptResponse, error) {
+	if len(req.Plaintext) == 0 || len(req.Plaintext) > kmsMaxPlaintext {
+		return nil, &KMSError{"ValidationException", "plaintext must be between 1 and 4096 bytes"}
+	}
+	key, err := k.find(r.Context(), req.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := k.usable(key, true); err != nil {
+		return nil, err
+	}
+	blob, err := kmsSeal(key, req.Plaintext, req.EncryptionContext)
+	if err != nil {
+		return nil, err
+	}
+	return &KMSEncryptResponse{KeyID: key.ID(), CiphertextBlob: blob, EncryptionAlgorithm: kmsAlgorithm}, nil
+}
+
+func (k *kms) decrypt(r *http.Request, req *KMSDecryptRequest) (*KMSDecryptResponse, error) {
+	key, pt, err := k.open(r, req.CiphertextBlob, req.KeyID, req.EncryptionContext)
+	if err != nil {
+		return nil, err
+	}
+	return &KMSDecryptResponse{KeyID: key.ID(), Plaintext: pt, EncryptionAlgorithm: kmsAlgorithm}, nil
+}
+
+func (k *kms) generateDataKey(r *http.Request, req *KMSGenerateDataKeyRequest) (*KMSGenerateDataKeyResponse, error) {
+	n := req.NumberOfBytes
+	switch {
+	case req.KeySpec != "" && n != 0:
+		return nil, &KMSError{"ValidationException", "only one of KeySpec and NumberOfBytes may be given"}
+	case req.KeySpec == "AES_256":
+		n = 32
+	case req.KeySpec == "AES_128":
+		n = 16
+	case req.KeySpec != "":
+		return nil, &KMSError{"ValidationException", fmt.Sprintf("unsupported KeySpec %q", req.KeySpec)}
+	case n < 1 || n > kmsMaxDataKeyLength:
+		return nil, &KMSError{"ValidationException", "NumberOfBytes must be between 1 and 1024"}
+	}
+
+	key, err := k.find(r.Context(), req.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := k.usable(key, true); err != nil {
+		return nil, err
+	}
+	dek := make([]byte, n)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+	blob, err := kmsSeal(key, dek, req.EncryptionContext)
+	if err != nil {
+		return nil, err
+	}
+	return &KMSGenerateDataKeyResponse{KeyID: key.ID(), Plaintext: dek, CiphertextBlob: blob}, nil
+}
+
+func (k *kms) reEncrypt(r *http.Request, req *KMSReEncryptRequest) (*KMSEncryptResponse, error) {
+	src, pt, err := k.open(r, req.CiphertextBlob, req.SourceKeyID, req.SourceEncryptionContext)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(pt)
+
+	dst, err := k.find(r.Context(), req.DestinationKeyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := k.usable(dst, true); err != nil {
+		return nil, err
+	}
+	blob, err := kmsSeal(dst, pt, req.DestinationEncryptionContext)
+	if err != nil {
+		return nil, err
+	}
+	return &KMSEncryptResponse{
+		KeyID:                          dst.ID(),
+		CiphertextBlob:                 blob,
+		SourceKeyID:                    src.ID(),
+		SourceEncryptionAlgorithm:      kmsAlgorithm,
+		DestinationEncryptionAlgorithm: kmsAlgorithm,
+	}, nil
+}
+
+// open decrypts a ciphertext blob, checking it was encrypted under the
+// expected key when one is given.
+func (k *kms) open(r *http.Request, blob []byte, keyID string, ctx map[string]string) (RootKey, []byte, error) {
+	id, err := keyBlobID(blob)
+	if err != nil {
+		return nil, nil, &KMSError{"InvalidCiphertextException", err.Error()}
+	}
+	key, err := k.find(r.Context(), id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if keyID != "" {
+		want, err := k.find(r.Context(), keyID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if want.ID() != key.ID() {
+			return nil, nil, &KMSError{"IncorrectKeyException", "ciphertext was not encrypted under the given key"}
+		}
+	}
+	if err := k.usable(key, false); err != nil {
+		return nil, nil, err
+	}
+	if err := observeUnwrap(r, key.ID()); err != nil {
+		return nil, nil, &KMSError{"AccessDeniedException", err.Error()}
+	}
+
+	pt, err := openKeyBlob(key, blob, kmsContextDigest(ctx))
+	if errors.Is(err, ErrGCMOpen) {
+		return nil, nil, &KMSError{"InvalidCiphertextException", "data authentication failed"}
+	}
+	return key, pt, err
+}
+
+// find resolves a key ID, key ARN, alias name or alias ARN to a root key.
+func (k *kms) find(ctx context.Context, keyID string) (RootKey, error) {
+	if keyID == "" {
+		return nil, &KMSError{"ValidationException", "KeyId is required"}
+	}
+	id := keyID
+	if strings.HasPrefix(id, "arn:") {
+		parts := strings.SplitN(id, ":", 6)
+		if len(parts) != 6 {
+			return nil, &KMSError{"NotFoundException", fmt.Sprintf("invalid key ARN %q", keyID)}
+		}
+		id = parts[5]
+	}
+	if strings.HasPrefix(id, "alias/") {
+		aliased, ok := k.aliases[id]
+		if !ok {
+			return nil, &KMSError{"NotFoundException", fmt.Sprintf("alias %q not found", id)}
+		}
+		id = aliased
+	}
+	id = strings.TrimPrefix(id, "key/")
+
+	key, err := findKey(ctx, k.keys, id)
+	switch {
+	case unavailable(err):
+		return nil, &KMSError{"DependencyTimeoutException", err.Error()}
+	case err != nil:
+		return nil, &KMSError{"NotFoundException", fmt.Sprintf("key %q not found", keyID)}
+	}
+	return key, nil
+}
+
+// usable refuses keys whose lifecycle state does not allow the operation.
+func (k *kms) usable(key RootKey, encrypt bool) error {
+	if err := checkKeyState(k.keys, key, encrypt); err != nil {
+		return &KMSError{"KMSInvalidStateException", err.Error()}
+	}
+	return nil
+}
+
+// kmsSeal encrypts the plaintext bound to the encryption context.
+func kmsSeal(key RootKey, pt []byte, ctx map[string]string) ([]byte, error) {
+	return sealKeyBlob(key, kmsContextDigest(ctx), pt)
+}
+
+// kmsContextDigest hashes the encryption context with its keys sorted.
+func kmsContextDigest(ctx map[string]string) []byte {
+	if ctx == nil {
+		ctx = map[string]string{}
+	}
+	b, _ := json.Marshal(ctx)
+	sum := sha256.Sum256(b)
+	return sum[:]
+}
+
+func kmsDecode(dec *json.Decoder, v any) error {
+	if err := dec.Decode(v); err != nil {
+		return &KMSError{"SerializationException", "invalid JSON"}
+	}
+	return nil
+}
+
+func kmsStatus(typ string) int {
+	switch typ {
+	case "KMSInternalException":
+		return http.StatusInternalServerError
+	case "DependencyTimeoutException":
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
+
+func kmsResponse(w http.ResponseWriter, status int, data any) {
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(data)
+	w.Header().Set("Content-Type", kmsContentType)
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
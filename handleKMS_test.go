@@ -0,0 +1,139 @@
+package praetorian_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+func kmsRequest(t *testing.T, handler http.Handler, op string, body any, res any) int {
+	t.Helper()
+	b, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+op)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if err := json.Unmarshal(rec.Body.Bytes(), res); err != nil {
+		t.Fatalf("HandleKMS() failed to parse response: %v", err)
+	}
+	return rec.Code
+}
+
+func TestHandleKMS(t *testing.T) {
+	handler := praetorian.HandleKMS(newTestKeystore(t, testConfig), map[string]string{"alias/app": "1"})
+	ctx := map[string]string{"table": "users"}
+
+	var gen praetorian.KMSGenerateDataKeyResponse
+	if code := kmsRequest(t, handler, "GenerateDataKey", &praetorian.KMSGenerateDataKeyRequest{
+		KeyID:             "arn:aws:kms:eu-west-2:111122223333:alias/app",
+		KeySpec:           "AES_256",
+		EncryptionContext: ctx,
+	}, &gen); code != http.StatusOK {
+		t.Fatalf("GenerateDataKey status = %d, want %d", code, http.StatusOK)
+	}
+	if gen.KeyID != "1" || len(gen.Plaintext) != 32 {
+		t.Errorf("GenerateDataKey key = %q, plaintext length = %d", gen.KeyID, len(gen.Plaintext))
+	}
+
+	var re praetorian.KMSEncryptResponse
+	if code := kmsRequest(t, handler, "ReEncrypt", &praetorian.KMSReEncryptRequest{
+		CiphertextBlob:          gen.CiphertextBlob,
+		SourceEncryptionContext: ctx,
+		DestinationKeyID:        "key/1",
+	}, &re); code != http.StatusOK {
+		t.Fatalf("ReEncrypt status = %d, want %d", code, http.StatusOK)
+	}
+	if bytes.Equal(re.CiphertextBlob, gen.CiphertextBlob) {
+		t.Error("ReEncrypt returned the original ciphertext")
+	}
+
+	var dec praetorian.KMSDecryptResponse
+	if code := kmsRequest(t, handler, "Decrypt", &praetorian.KMSDecryptRequest{
+		CiphertextBlob: re.CiphertextBlob,
+	}, &dec); code != http.StatusOK {
+		t.Fatalf("Decrypt status = %d, want %d", code, http.StatusOK)
+	}
+	if !bytes.Equal(dec.Plaintext, gen.Plaintext) {
+		t.Errorf("Decrypt plaintext = %x, want %x", dec.Plaintext, gen.Plaintext)
+	}
+}
+
+func TestHandleKMS_Errors(t *testing.T) {
+	keys := newTestKeystore(t, testConfig)
+	handler := praetorian.HandleKMS(keys, map[string]string{"alias/app": "1"})
+
+	var enc praetorian.KMSEncryptResponse
+	kmsRequest(t, handler, "Encrypt", &praetorian.KMSEncryptRequest{
+		KeyID:             "alias/app",
+		Plaintext:         []byte("secret"),
+		EncryptionContext: map[string]string{"table": "users"},
+	}, &enc)
+
+	tests := []struct {
+		name       string
+		keys       praetorian.KeyFinder
+		op         string
+		body       any
+		wantStatus int
+		wantType   string
+	}{
+		{
+			name:       "wrong encryption context",
+			op:         "Decrypt",
+			body:       &praetorian.KMSDecryptRequest{CiphertextBlob: enc.CiphertextBlob},
+			wantStatus: http.StatusBadRequest,
+			wantType:   "InvalidCiphertextException",
+		},
+		{
+			name:       "unknown alias",
+			op:         "Encrypt",
+			body:       &praetorian.KMSEncryptRequest{KeyID: "alias/missing", Plaintext: []byte("secret")},
+			wantStatus: http.StatusBadRequest,
+			wantType:   "NotFoundException",
+		},
+		{
+			name:       "invalid key spec",
+			op:         "GenerateDataKey",
+			body:       &praetorian.KMSGenerateDataKeyRequest{KeyID: "1", KeySpec: "RSA_2048"},
+			wantStatus: http.StatusBadRequest,
+			wantType:   "ValidationException",
+		},
+		{
+			name:       "sealed keystore",
+			keys:       &MockKeystore{},
+			op:         "Encrypt",
+			body:       &praetorian.KMSEncryptRequest{KeyID: "sealed", Plaintext: []byte("secret")},
+			wantStatus: http.StatusServiceUnavailable,
+			wantType:   "DependencyTimeoutException",
+		},
+		{
+			name:       "unknown operation",
+			op:         "CreateKey",
+			body:       struct{}{},
+			wantStatus: http.StatusBadRequest,
+			wantType:   "UnknownOperationException",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := handler
+			if tt.keys != nil {
+				h = praetorian.HandleKMS(tt.keys, nil)
+			}
+			var res praetorian.KMSError
+			if code := kmsRequest(t, h, tt.op, tt.body, &res); code != tt.wantStatus {
+				t.Errorf("HandleKMS() status = %d, wantStatus = %d", code, tt.wantStatus)
+			}
+			if res.Type != tt.wantType {
+				t.Errorf("HandleKMS() type = %q, wantType = %q", res.Type, tt.wantType)
+			}
+		})
+	}
+}
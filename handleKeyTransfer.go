@@ -0,0 +1,133 @@
+package praetorian
+
+import (
+	"crypto/ecdh"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type ExportKeyRequest struct {
+	ID        string `json:"id"`
+	PublicKey string `json:"publicKey"`
+}
+
+type ImportKeyResponse struct {
+	ID string `json:"id"`
+}
+
+type TransferKeyResponse struct {
+	PublicKey string `json:"publicKey"`
+}
+
+func HandleExportKey(keys KeyFinder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var b ExportKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+			jsonResponse(w, http.StatusBadRequest, &ErrorResponse{
+				Message: "invalid JSON",
+			})
+			return
+		}
+
+		pub, err := ParsePublicKeyPEM([]byte(b.PublicKey))
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, &ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		key, err := keys.Find(b.ID)
+		if err != nil {
+			if errors.Is(err, ErrKeystoreSealed) {
+				jsonResponse(w, http.StatusServiceUnavailable, &ErrorResponse{
+					Message: err.Error(),
+				})
+				return
+			}
+			jsonResponse(w, http.StatusNotFound, &ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		e, err := ExportKey(key, pub)
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrUnsupportedTransferKey):
+				jsonResponse(w, http.StatusBadRequest, &ErrorResponse{
+					Message: err.Error(),
+				})
+			case errors.Is(err, ErrKeyNotExportable):
+				jsonResponse(w, http.StatusForbidden, &ErrorResponse{
+					Message: err.Error(),
+				})
+			default:
+				jsonResponse(w, http.StatusInternalServerError, &ErrorResponse{
+					Message: err.Error(),
+				})
+			}
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, e)
+	}
+}
+
+// HandleImportPublicKey publishes the transfer public key which exporters
+// must encrypt to.
+func HandleImportPublicKey(priv *ecdh.PrivateKey) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pub, err := MarshalPublicKeyPEM(priv.PublicKey())
+		if err != nil {
+			jsonResponse(w, http.StatusInternalServerError, &ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+		jsonResponse(w, http.StatusOK, &TransferKeyResponse{
+			PublicKey: string(pub),
+		})
+	}
+}
+
+// HandleImportKey imports key exports encrypted to the transfer public key
+// into the keystore.
+func HandleImportKey(imp KeyImporter, priv *ecdh.PrivateKey) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var b KeyExport
+		if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+			jsonResponse(w, http.StatusBadRequest, &ErrorResponse{
+				Message: "invalid JSON",
+			})
+			return
+		}
+
+		if err := ImportKey(imp, &b, priv); err != nil {
+			switch {
+			case errors.Is(err, ErrKeystoreSealed):
+				jsonResponse(w, http.StatusServiceUnavailable, &ErrorResponse{
+					Message: err.Error(),
+				})
+			case errors.Is(err, ErrRootKeyExists):
+				jsonResponse(w, http.StatusConflict, &ErrorResponse{
+					Message: err.Error(),
+				})
+			case errors.Is(err, ErrInvalidKeyExport),
+				errors.Is(err, ErrInvalidRootKeyLength),
+				errors.Is(err, ErrUnsupportedTransferKey):
+				jsonResponse(w, http.StatusUnprocessableEntity, &ErrorResponse{
+					Message: err.Error(),
+				})
+			default:
+				jsonResponse(w, http.StatusInternalServerError, &ErrorResponse{
+					Message: err.Error(),
+				})
+			}
+			return
+		}
+
+		jsonResponse(w, http.StatusCreated, &ImportKeyResponse{ID: b.ID})
+	}
+}
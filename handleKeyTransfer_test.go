@@ -0,0 +1,149 @@
+package praetorian_test
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+func TestHandleKeyTransfer(t *testing.T) {
+	priv, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdh.GenerateKey() failed: %v", err)
+	}
+	dst := newTestKeystore(t, `{"activeKeyId": "2", "rootKeys": {"2": "BPK//lj6hlpjuA5gPZo19OIjUDgnIQAAAAAAAAAAAAA="}}`)
+	importer := praetorian.HandleImportKey(dst.(praetorian.KeyImporter), priv)
+
+	rec := httptest.NewRecorder()
+	praetorian.HandleImportPublicKey(priv).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/keys/import", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("HandleImportPublicKey() status = %d, wantStatus = %d", rec.Code, http.StatusOK)
+	}
+	var tk praetorian.TransferKeyResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &tk); err != nil {
+		t.Fatalf("HandleImportPublicKey() failed to parse response: %v", err)
+	}
+
+	src := newTestKeystore(t, testConfig)
+	exporter := praetorian.HandleExportKey(src)
+	body, err := json.Marshal(&praetorian.ExportKeyRequest{ID: "1", PublicKey: tk.PublicKey})
+	if err != nil {
+		t.Fatalf("json.Marshal() failed: %v", err)
+	}
+
+	rec = httptest.NewRecorder()
+	exporter.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/keys/export", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("HandleExportKey() status = %d, wantStatus = %d", rec.Code, http.StatusOK)
+	}
+
+	exported := rec.Body.Bytes()
+	rec = httptest.NewRecorder()
+	importer.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/keys/import", bytes.NewReader(exported)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("HandleImportKey() status = %d, wantStatus = %d", rec.Code, http.StatusCreated)
+	}
+
+	if _, err := dst.Find("1"); err != nil {
+		t.Errorf("Keystore.Find() failed to return imported key: %v", err)
+	}
+
+	rec = httptest.NewRecorder()
+	importer.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/keys/import", bytes.NewReader(exported)))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("HandleImportKey() status = %d, wantStatus = %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestHandleExportKey_Errors(t *testing.T) {
+	priv, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdh.GenerateKey() failed: %v", err)
+	}
+	pub, err := praetorian.MarshalPublicKeyPEM(priv.PublicKey())
+	if err != nil {
+		t.Fatalf("MarshalPublicKeyPEM() failed: %v", err)
+	}
+	withKey := func(id string) io.Reader {
+		b, _ := json.Marshal(&praetorian.ExportKeyRequest{ID: id, PublicKey: string(pub)})
+		return bytes.NewReader(b)
+	}
+
+	tests := []struct {
+		name        string
+		body        io.Reader
+		method      string
+		wantStatus  int
+		wantMessage string
+	}{
+		{
+			name:        "invalid JSON body",
+			body:        strings.NewReader("{invalid}"),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "invalid JSON",
+		},
+		{
+			name:        "invalid public key",
+			body:        strings.NewReader(`{"id": "1", "publicKey": "invalid"}`),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "unsupported transfer key",
+		},
+		{
+			name:        "missing root key",
+			body:        withKey("missing"),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusNotFound,
+			wantMessage: "root key not found",
+		},
+		{
+			name:        "keystore sealed",
+			body:        withKey("sealed"),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusServiceUnavailable,
+			wantMessage: "keystore is sealed",
+		},
+		{
+			name:        "key not exportable",
+			body:        withKey("1"),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusForbidden,
+			wantMessage: "root key cannot be exported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks := &MockKeystore{}
+			handler := praetorian.HandleExportKey(ks)
+
+			req := httptest.NewRequest(tt.method, "/admin/keys/export", tt.body)
+			req.Header.Set("Content-Type", "application/json")
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("HandleExportKey() status = %d, wantStatus = %d", rec.Code, tt.wantStatus)
+			}
+
+			var res praetorian.ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+				t.Fatalf("HandleExportKey() failed to parse response: %v", err)
+			}
+
+			if res.Message != tt.wantMessage {
+				t.Errorf("HandleExportKey() got = %q, wantMessage = %q", res.Message, tt.wantMessage)
+			}
+		})
+	}
+}
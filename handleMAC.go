@@ -0,0 +1,114 @@
+package praetorian
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type MACRequest struct {
+	Data string `json:"data"`
+}
+
+type MACResponse struct {
+	ID  string `json:"id"`
+	MAC string `json:"mac"`
+}
+
+type MACVerifyRequest struct {
+	ID   string `json:"id"`
+	Data string `json:"data"`
+	MAC  string `json:"mac"`
+}
+
+func HandleMAC(activeKey string, macs MACFinder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		maxBytes := maxBodyBytes(r)
+		var b MACRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBytes)).Decode(&b); err != nil {
+			jsonResponse(w, http.StatusBadRequest, &ErrorResponse{
+				Message: "invalid JSON",
+			})
+			return
+		}
+
+		data, err := base64.StdEncoding.DecodeString(b.Data)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, &ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		key, err := macs.FindMAC(activeKey)
+		if err != nil {
+			if errors.Is(err, ErrKeystoreSealed) {
+				jsonResponse(w, http.StatusServiceUnavailable, &ErrorResponse{
+					Message: err.Error(),
+				})
+				return
+			}
+			jsonResponse(w, http.StatusNotFound, &ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, &MACResponse{
+			ID:  key.ID(),
+			MAC: base64.StdEncoding.EncodeToString(key.MAC(data)),
+		})
+	}
+}
+
+func HandleMACVerify(macs MACFinder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		maxBytes := maxBodyBytes(r)
+		var b MACVerifyRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBytes)).Decode(&b); err != nil {
+			jsonResponse(w, http.StatusBadRequest, &ErrorResponse{
+				Message: "invalid JSON",
+			})
+			return
+		}
+
+		data, err := base64.StdEncoding.DecodeString(b.Data)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, &ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+		mac, err := base64.StdEncoding.DecodeString(b.MAC)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, &ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		key, err := macs.FindMAC(b.ID)
+		if err != nil {
+			if errors.Is(err, ErrKeystoreSealed) {
+				jsonResponse(w, http.StatusServiceUnavailable, &ErrorResponse{
+					Message: err.Error(),
+				})
+				return
+			}
+			jsonResponse(w, http.StatusNotFound, &ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		if err := key.Verify(data, mac); err != nil {
+			jsonResponse(w, http.StatusUnprocessableEntity, &ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, &VerifyResponse{Valid: true})
+	}
+}
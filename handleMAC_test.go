@@ -0,0 +1,170 @@
+package praetorian_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+func TestHandleMAC(t *testing.T) {
+	macs := newTestKeystore(t, testMACConfig).(praetorian.MACFinder)
+	mac := praetorian.HandleMAC(praetorian.ActiveKeyID, macs)
+	verify := praetorian.HandleMACVerify(macs)
+
+	req := httptest.NewRequest(http.MethodPost, "/mac", strings.NewReader(`{"data": "YWxpY2VAZXhhbXBsZS5jb20="}`))
+	rec := httptest.NewRecorder()
+	mac.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("HandleMAC() status = %d, wantStatus = %d", rec.Code, http.StatusOK)
+	}
+
+	var res praetorian.MACResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("HandleMAC() failed to parse response: %v", err)
+	}
+
+	want := praetorian.MACResponse{ID: "7", MAC: "AAFFKkw9o8o/wiOZ8KQuRSL50dbQiOV/gscHVYtUrfI="}
+	if res != want {
+		t.Errorf("HandleMAC() got = %+v, want = %+v", res, want)
+	}
+
+	body, err := json.Marshal(&praetorian.MACVerifyRequest{ID: res.ID, Data: "YWxpY2VAZXhhbXBsZS5jb20=", MAC: res.MAC})
+	if err != nil {
+		t.Fatalf("json.Marshal() failed: %v", err)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/mac/verify", bytes.NewReader(body))
+	rec = httptest.NewRecorder()
+	verify.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("HandleMACVerify() status = %d, wantStatus = %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestHandleMAC_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		activeKey   string
+		body        io.Reader
+		method      string
+		wantStatus  int
+		wantMessage string
+	}{
+		{
+			name:        "invalid JSON body",
+			activeKey:   praetorian.ActiveKeyID,
+			body:        strings.NewReader("{invalid}"),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "invalid JSON",
+		},
+		{
+			name:        "active MAC key not found",
+			activeKey:   "missing",
+			body:        strings.NewReader(`{"data": ""}`),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusNotFound,
+			wantMessage: "MAC key not found",
+		},
+		{
+			name:        "keystore sealed",
+			activeKey:   "sealed",
+			body:        strings.NewReader(`{"data": ""}`),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusServiceUnavailable,
+			wantMessage: "keystore is sealed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks := &MockKeystore{}
+			handler := praetorian.HandleMAC(tt.activeKey, ks)
+
+			req := httptest.NewRequest(tt.method, "/mac", tt.body)
+			req.Header.Set("Content-Type", "application/json")
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("HandleMAC() status = %d, wantStatus = %d", rec.Code, tt.wantStatus)
+			}
+
+			var res praetorian.ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+				t.Fatalf("HandleMAC() failed to parse response: %v", err)
+			}
+
+			if res.Message != tt.wantMessage {
+				t.Errorf("HandleMAC() got = %q, wantMessage = %q", res.Message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestHandleMACVerify_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        io.Reader
+		method      string
+		wantStatus  int
+		wantMessage string
+	}{
+		{
+			name:        "invalid MAC",
+			body:        strings.NewReader(`{"id": "6", "data": "", "mac": "aW52YWxpZA=="}`),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantMessage: "MAC verification failed",
+		},
+		{
+			name:        "invalid JSON body",
+			body:        strings.NewReader("{invalid}"),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "invalid JSON",
+		},
+		{
+			name:        "missing MAC key",
+			body:        strings.NewReader(`{"id": "missing", "data": "", "mac": ""}`),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusNotFound,
+			wantMessage: "MAC key not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks := &MockKeystore{}
+			handler := praetorian.HandleMACVerify(ks)
+
+			req := httptest.NewRequest(tt.method, "/mac/verify", tt.body)
+			req.Header.Set("Content-Type", "application/json")
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("HandleMACVerify() status = %d, wantStatus = %d", rec.Code, tt.wantStatus)
+			}
+
+			var res praetorian.ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+				t.Fatalf("HandleMACVerify() failed to parse response: %v", err)
+			}
+
+			if res.Message != tt.wantMessage {
+				t.Errorf("HandleMACVerify() got = %q, wantMessage = %q", res.Message, tt.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package praetorian
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+)
+
+type PublicKeyResponse struct {
+	ID        string `json:"id"`
+	Curve     string `json:"curve"`
+	PublicKey string `json:"publicKey"`
+}
+
+func HandlePublicKey(keys KeyFinder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, err := keys.Find(r.PathValue("id"))
+		if err != nil {
+			if errors.Is(err, ErrKeystoreSealed) {
+				jsonResponse(w, http.StatusServiceUnavailable, &ErrorResponse{
+					Message: err.Error(),
+				})
+				return
+			}
+			jsonResponse(w, http.StatusNotFound, &ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		pk, ok := key.(PublicRootKey)
+		if !ok {
+			jsonResponse(w, http.StatusNotFound, &ErrorResponse{
+				Message: ErrNoPublicKey.Error(),
+			})
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, &PublicKeyResponse{
+			ID:        pk.ID(),
+			Curve:     pk.Curve(),
+			PublicKey: base64.StdEncoding.EncodeToString(pk.PublicKey().Bytes()),
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package praetorian_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+func TestHandlePublicKey(t *testing.T) {
+	ks := newTestKeystore(t, testECDHConfig)
+	handler := praetorian.HandlePublicKey(ks)
+
+	req := httptest.NewRequest(http.MethodGet, "/publickey/2", nil)
+	req.SetPathValue("id", "2")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("HandlePublicKey() status = %d, wantStatus = %d", rec.Code, http.StatusOK)
+	}
+
+	var res praetorian.PublicKeyResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("HandlePublicKey() failed to parse response: %v", err)
+	}
+
+	if res.ID != "2" || res.Curve != praetorian.CurveX25519 {
+		t.Errorf("HandlePublicKey() got = %+v", res)
+	}
+	if b, err := base64.StdEncoding.DecodeString(res.PublicKey); err != nil || len(b) != 32 {
+		t.Errorf("HandlePublicKey() publicKey = %q, want 32 byte X25519 key", res.PublicKey)
+	}
+}
+
+func TestHandlePublicKey_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		id          string
+		method      string
+		wantStatus  int
+		wantMessage string
+	}{
+		{
+			name:        "symmetric root key",
+			id:          "1",
+			method:      http.MethodGet,
+			wantStatus:  http.StatusNotFound,
+			wantMessage: "root key has no public key",
+		},
+		{
+			name:        "missing root key",
+			id:          "missing",
+			method:      http.MethodGet,
+			wantStatus:  http.StatusNotFound,
+			wantMessage: "root key not found",
+		},
+		{
+			name:        "keystore sealed",
+			id:          "sealed",
+			method:      http.MethodGet,
+			wantStatus:  http.StatusServiceUnavailable,
+			wantMessage: "keystore is sealed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks := &MockKeystore{}
+			handler := praetorian.HandlePublicKey(ks)
+
+			req := httptest.NewRequest(tt.method, "/publickey/"+tt.id, nil)
+			req.SetPathValue("id", tt.id)
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("HandlePublicKey() status = %d, wantStatus = %d", rec.Code, tt.wantStatus)
+			}
+
+			var res praetorian.ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+				t.Fatalf("HandlePublicKey() failed to parse response: %v", err)
+			}
+
+			if res.Message != tt.wantMessage {
+				t.Errorf("HandlePublicKey() got = %q, wantMessage = %q", res.Message, tt.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package praetorian
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+type SignRequest struct {
+	Payload string `json:"payload"`
+}
+
+type SignResponse struct {
+	ID        string `json:"id"`
+	Algorithm string `json:"algorithm"`
+	Signature string `json:"signature"`
+}
+
+type VerifyRequest struct {
+	ID        string `json:"id"`
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+}
+
+type VerifyResponse struct {
+	Valid bool `json:"valid"`
+}
+
+func HandleSign(activeKey string, signers SignerFinder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		maxBytes := maxBodyBytes(r)
+		b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, &ErrorResponse{
+				Message: "failed to read request body",
+			})
+			return
+		}
+		defer r.Body.Close()
+
+		var req SignRequest
+		if err := json.Unmarshal(b, &req); err != nil {
+			jsonResponse(w, http.StatusBadRequest, &ErrorResponse{
+				Message: "invalid JSON",
+			})
+			return
+		}
+
+		payload, err := base64.StdEncoding.DecodeString(req.Payload)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, &ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		key, err := signers.FindSigner(activeKey)
+		if err != nil {
+			if errors.Is(err, ErrKeystoreSealed) {
+				jsonResponse(w, http.StatusServiceUnavailable, &ErrorResponse{
+					Message: err.Error(),
+				})
+				return
+			}
+			jsonResponse(w, http.StatusNotFound, &ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		sig, err := key.Sign(payload)
+		if err != nil {
+			jsonResponse(w, http.StatusInternalServerError, &ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, &SignResponse{
+			ID:        key.ID(),
+			Algorithm: key.Algorithm(),
+			Signature: base64.StdEncoding.EncodeToString(sig),
+		})
+	}
+}
+
+func HandleVerify(signers SignerFinder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		maxBytes := maxBodyBytes(r)
+		var b VerifyRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBytes)).Decode(&b); err != nil {
+			jsonResponse(w, http.StatusBadRequest, &ErrorResponse{
+				Message: "invalid JSON",
+			})
+			return
+		}
+
+		payload, err := base64.StdEncoding.DecodeString(b.Payload)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, &ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+		sig, err := base64.StdEncoding.DecodeString(b.Signature)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, &ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		key, err := signers.FindSigner(b.ID)
+		if err != nil {
+			if errors.Is(err, ErrKeystoreSealed) {
+				jsonResponse(w, http.StatusServiceUnavailable, &ErrorResponse{
+					Message: err.Error(),
+				})
+				return
+			}
+			jsonResponse(w, http.StatusNotFound, &ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		if err := key.Verify(payload, sig); err != nil {
+			jsonResponse(w, http.StatusUnprocessableEntity, &ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, &VerifyResponse{Valid: true})
+	}
+}
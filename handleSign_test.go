@@ -0,0 +1,195 @@
+package praetorian_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+func TestHandleSign(t *testing.T) {
+	signers := newTestKeystore(t, testSigningConfig).(praetorian.SignerFinder)
+	sign := praetorian.HandleSign(praetorian.ActiveKeyID, signers)
+	verify := praetorian.HandleVerify(signers)
+
+	req := httptest.NewRequest(http.MethodPost, "/sign", strings.NewReader(`{"payload": "aGVsbG8gd29ybGQ="}`))
+	rec := httptest.NewRecorder()
+	sign.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("HandleSign() status = %d, wantStatus = %d", rec.Code, http.StatusOK)
+	}
+
+	var res praetorian.SignResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("HandleSign() failed to parse response: %v", err)
+	}
+	if res.ID != "4" || res.Algorithm != praetorian.SigningEdDSA {
+		t.Errorf("HandleSign() got = %+v", res)
+	}
+
+	body, err := json.Marshal(&praetorian.VerifyRequest{
+		ID:        res.ID,
+		Payload:   "aGVsbG8gd29ybGQ=",
+		Signature: res.Signature,
+	})
+	if err != nil {
+		t.Fatalf("json.Marshal() failed: %v", err)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/verify", bytes.NewReader(body))
+	rec = httptest.NewRecorder()
+	verify.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("HandleVerify() status = %d, wantStatus = %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestHandleSign_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		activeKey   string
+		body        io.Reader
+		method      string
+		wantStatus  int
+		wantMessage string
+	}{
+		{
+			name:        "signing failure",
+			activeKey:   praetorian.ActiveKeyID,
+			body:        strings.NewReader(`{"payload": "ZXJyb3I="}`),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusInternalServerError,
+			wantMessage: "signing failed",
+		},
+		{
+			name:        "invalid JSON body",
+			activeKey:   praetorian.ActiveKeyID,
+			body:        strings.NewReader("{invalid}"),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "invalid JSON",
+		},
+		{
+			name:        "body too large",
+			activeKey:   praetorian.ActiveKeyID,
+			body:        bytes.NewReader(make([]byte, 2<<20)),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "failed to read request body",
+		},
+		{
+			name:        "active signing key not found",
+			activeKey:   "missing",
+			body:        strings.NewReader(`{"payload": ""}`),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusNotFound,
+			wantMessage: "signing key not found",
+		},
+		{
+			name:        "keystore sealed",
+			activeKey:   "sealed",
+			body:        strings.NewReader(`{"payload": ""}`),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusServiceUnavailable,
+			wantMessage: "keystore is sealed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks := &MockKeystore{}
+			handler := praetorian.HandleSign(tt.activeKey, ks)
+
+			req := httptest.NewRequest(tt.method, "/sign", tt.body)
+			req.Header.Set("Content-Type", "application/json")
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("HandleSign() status = %d, wantStatus = %d", rec.Code, tt.wantStatus)
+			}
+
+			var res praetorian.ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+				t.Fatalf("HandleSign() failed to parse response: %v", err)
+			}
+
+			if res.Message != tt.wantMessage {
+				t.Errorf("HandleSign() got = %q, wantMessage = %q", res.Message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestHandleVerify_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        io.Reader
+		method      string
+		wantStatus  int
+		wantMessage string
+	}{
+		{
+			name:        "invalid signature",
+			body:        strings.NewReader(`{"id": "4", "payload": "", "signature": "aW52YWxpZA=="}`),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantMessage: "signature verification failed",
+		},
+		{
+			name:        "invalid JSON body",
+			body:        strings.NewReader("{invalid}"),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "invalid JSON",
+		},
+		{
+			name:        "missing signing key",
+			body:        strings.NewReader(`{"id": "missing", "payload": "", "signature": ""}`),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusNotFound,
+			wantMessage: "signing key not found",
+		},
+		{
+			name:        "keystore sealed",
+			body:        strings.NewReader(`{"id": "sealed", "payload": "", "signature": ""}`),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusServiceUnavailable,
+			wantMessage: "keystore is sealed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks := &MockKeystore{}
+			handler := praetorian.HandleVerify(ks)
+
+			req := httptest.NewRequest(tt.method, "/verify", tt.body)
+			req.Header.Set("Content-Type", "application/json")
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("HandleVerify() status = %d, wantStatus = %d", rec.Code, tt.wantStatus)
+			}
+
+			var res praetorian.ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+				t.Fatalf("HandleVerify() failed to parse response: %v", err)
+			}
+
+			if res.Message != tt.wantMessage {
+				t.Errorf("HandleVerify() got = %q, wantMessage = %q", res.Message, tt.wantMessage)
+			}
+		})
+	}
+}
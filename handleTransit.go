@@ -0,0 +1,275 @@
+package praetorian
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// TransitPrefix is prepended to every transit ciphertext, followed by the key
+// version and a colon, as in "vault:v2:". The key version is the id of the
+// root key which encrypted it.
+const TransitPrefix = "vault:v"
+
+// TransitRequest is the body of the transit encrypt, decrypt, rewrap and
+// datakey endpoints. Batches are given in BatchInput.
+type TransitRequest struct {
+	Plaintext  string           `json:"plaintext,omitempty"`
+	Ciphertext string           `json:"ciphertext,omitempty"`
+	Context    string           `json:"context,omitempty"`
+	Bits       int              `json:"bits,omitempty"`
+	BatchInput []TransitRequest `json:"batch_input,omitempty"`
+}
+
+// TransitResult is a single result, which is also used for each item of a
+// batch.
+type TransitResult struct {
+	Plaintext    string          `json:"plaintext,omitempty"`
+	Ciphertext   string          `json:"ciphertext,omitempty"`
+	KeyVersion   int             `json:"key_version,omitempty"`
+	BatchResults []TransitResult `json:"batch_results,omitempty"`
+	Error        string          `json:"error,omitempty"`
+}
+
+// TransitResponse wraps results in the same way as Vault.
+type TransitResponse struct {
+	Data *TransitResult `json:"data"`
+}
+
+// TransitErrorResponse is returned when a request fails.
+type TransitErrorResponse struct {
+	Errors []string `json:"errors"`
+}
+
+// transitError is a failure caused by the request.
+type transitError string
+
+func (e transitError) Error() string {
+	return string(e)
+}
+
+// HandleTransit serves the encrypt, decrypt, rewrap and datakey endpoints of
+// the Vault transit engine. Every key name encrypts under the active root key
+// and is bound to its ciphertexts, so a ciphertext can only be decrypted with
+// the name and context it was encrypted with. Rewrap moves ciphertexts to the
+// current active root key. Key versions are the ids of the root keys, so
+// transit requires ids which are positive integers.
+func HandleTransit(activeKey string, keys KeyFinder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// data keys are served from /v1/transit/datakey/{type}/{name}.
+		name, opName := r.PathValue("name"), r.PathValue("op")
+		if r.PathValue("type") != "" {
+			opName = "datakey"
+		}
+		var op func(*TransitRequest) (*TransitResult, error)
+		switch opName {
+		case "encrypt":
+			op = func(req *TransitRequest) (*TransitResult, error) {
+				pt, err := base64.StdEncoding.DecodeString(req.Plaintext)
+				if err != nil {
+					return nil, transitError("invalid base64 plaintext")
+				}
+				return transitEncrypt(r, keys, activeKey, name, req, pt)
+			}
+		case "decrypt":
+			op = func(req *TransitRequest) (*TransitResult, error) {
+				pt, err := transitDecrypt(r, keys, name, req)
+				if err != nil {
+					return nil, err
+				}
+				return &TransitResult{Plaintext: base64.StdEncoding.EncodeToString(pt)}, nil
+			}
+		case "rewrap":
+			op = func(req *TransitRequest) (*TransitResult, error) {
+				pt, err := transitDecrypt(r, keys, name, req)
+				if err != nil {
+					return nil, err
+				}
+				defer clear(pt)
+				return transitEncrypt(r, keys, activeKey, name, req, pt)
+			}
+		case "datakey":
+			typ := r.PathValue("type")
+			if typ != "plaintext" && typ != "wrapped" {
+				jsonResponse(w, http.StatusBadRequest, &TransitErrorResponse{
+					Errors: []string{"invalid data key type " + typ},
+				})
+				return
+			}
+			op = func(req *TransitRequest) (*TransitResult, error) {
+				if req.Bits == 0 {
+					req.Bits = 256
+				}
+				if req.Bits != 128 && req.Bits != 256 && req.Bits != 512 {
+					return nil, transitError("bits must be 128, 256 or 512")
+				}
+				dek := make([]byte, req.Bits/8)
+				if _, err := rand.Read(dek); err != nil {
+					return nil, err
+				}
+				res, err := transitEncrypt(r, keys, activeKey, name, req, dek)
+				if err != nil {
+					return nil, err
+				}
+				if typ == "plaintext" {
+					res.Plaintext = base64.StdEncoding.EncodeToString(dek)
+				}
+				return res, nil
+			}
+		default:
+			jsonResponse(w, http.StatusNotFound, &TransitErrorResponse{
+				Errors: []string{"unsupported path"},
+			})
+			return
+		}
+
+		maxBytes := maxBodyBytes(r)
+		var req TransitRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBytes)).Decode(&req); err != nil {
+			jsonResponse(w, http.StatusBadRequest, &TransitErrorResponse{
+				Errors: []string{"invalid JSON"},
+			})
+			return
+		}
+
+		if len(req.BatchInput) == 0 {
+			res, err := op(&req)
+			if err != nil {
+				jsonResponse(w, transitStatus(err), &TransitErrorResponse{
+					Errors: []string{err.Error()},
+				})
+				return
+			}
+			jsonResponse(w, http.StatusOK, &TransitResponse{Data: res})
+			return
+		}
+
+		// failures of individual batch items are reported in their results.
+		results := make([]TransitResult, len(req.BatchInput))
+		for i := range req.BatchInput {
+			item := &req.BatchInput[i]
+			if item.Bits == 0 {
+				item.Bits = req.Bits
+			}
+			res, err := op(item)
+			if err != nil {
+				if transitStatus(err) == http.StatusServiceUnavailable {
+					jsonResponse(w, http.StatusServiceUnavailable, &TransitErrorResponse{
+						Errors: []string{err.Error()},
+					})
+					return
+				}
+				results[i] = TransitResult{Error: err.Error()}
+				continue
+			}
+			results[i] = *res
+		}
+		jsonResponse(w, http.StatusOK, &TransitResponse{Data: &TransitResult{BatchResults: results}})
+	}
+}
+
+func transitEncrypt(r *http.Request, keys KeyFinder, activeKey, name string, req *TransitRequest, pt []byte) (*TransitResult, error) {
+	digest, err := transitDigest(name, req.Context)
+	if err != nil {
+		return nil, err
+	}
+	key, err := findKey(r.Context(), keys, activeKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkKeyState(keys, key, true); err != nil {
+		return nil, err
+	}
+	version, err := transitVersion(key.ID())
+	if err != nil {
+		return nil, err
+	}
+	blob, err := sealKeyBlob(key, digest, pt)
+	if err != nil {
+		return nil, err
+	}
+	return &TransitResult{
+		Ciphertext: TransitPrefix + strconv.Itoa(version) + ":" + base64.StdEncoding.EncodeToString(blob),
+		KeyVersion: version,
+	}, nil
+}
+
+func transitDecrypt(r *http.Request, keys KeyFinder, name string, req *TransitRequest) ([]byte, error) {
+	digest, err := transitDigest(name, req.Context)
+	if err != nil {
+		return nil, err
+	}
+	enc, ok := strings.CutPrefix(req.Ciphertext, TransitPrefix)
+	if !ok {
+		return nil, transitError("invalid ciphertext: no prefix")
+	}
+	v, enc, ok := strings.Cut(enc, ":")
+	if !ok {
+		return nil, transitError("invalid ciphertext: no key version")
+	}
+	blob, err := base64.StdEncoding.DecodeString(enc)
+	if err != nil {
+		return nil, transitError("invalid ciphertext: could not decode")
+	}
+	id, err := keyBlobID(blob)
+	if err != nil {
+		return nil, transitError(err.Error())
+	}
+	// the version is checked against the key which sealed the ciphertext, so
+	// that it cannot be changed to misreport the key in use.
+	if _, err := transitVersion(v); err != nil || v != id {
+		return nil, transitError("invalid ciphertext: key version does not match")
+	}
+	key, err := findKey(r.Context(), keys, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkKeyState(keys, key, false); err != nil {
+		return nil, err
+	}
+	if err := observeUnwrap(r, key.ID()); err != nil {
+		return nil, err
+	}
+	return openKeyBlob(key, blob, digest)
+}
+
+// transitVersion returns the key version of the root key.
+func transitVersion(id string) (int, error) {
+	version, err := strconv.Atoi(id)
+	if err != nil || version <= 0 || strconv.Itoa(version) != id {
+		return 0, ErrTransitKeyVersion
+	}
+	return version, nil
+}
+
+// transitDigest hashes the key name and the base64 encoded context.
+func transitDigest(name, context string) ([]byte, error) {
+	ctx, err := base64.StdEncoding.DecodeString(context)
+	if err != nil {
+		return nil, transitError("invalid base64 context")
+	}
+	h := sha256.New()
+	h.Write(binary.BigEndian.AppendUint32(nil, uint32(len(name))))
+	h.Write([]byte(name))
+	h.Write(ctx)
+	return h.Sum(nil), nil
+}
+
+func transitStatus(err error) int {
+	var te transitError
+	switch {
+	case unavailable(err):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrCallerLockedOut), errors.Is(err, ErrRootKeyInactive):
+		return http.StatusForbidden
+	case errors.As(err, &te), errors.Is(err, ErrRootKeyNotFound), errors.Is(err, ErrGCMOpen):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,181 @@
+package praetorian_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+func transitRequest(t *testing.T, activeKey string, keys praetorian.KeyFinder, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	handler := praetorian.HandleTransit(activeKey, keys)
+	req := httptest.NewRequest(http.MethodPost, "/v1/transit/"+path, strings.NewReader(body))
+	parts := strings.Split(path, "/")
+	if parts[0] == "datakey" {
+		req.SetPathValue("type", parts[1])
+	} else {
+		req.SetPathValue("op", parts[0])
+	}
+	req.SetPathValue("name", parts[len(parts)-1])
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func transitResult(t *testing.T, rec *httptest.ResponseRecorder) *praetorian.TransitResult {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("HandleTransit() status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var res praetorian.TransitResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("HandleTransit() failed to parse response: %v", err)
+	}
+	return res.Data
+}
+
+func TestHandleTransit(t *testing.T) {
+	keys := newTestKeystore(t, testConfig)
+	pt := base64.StdEncoding.EncodeToString([]byte("keep it secret, keep it safe"))
+
+	enc := transitResult(t, transitRequest(t, praetorian.ActiveKeyID, keys, "encrypt/app", `{"plaintext": "`+pt+`"}`))
+	if !strings.HasPrefix(enc.Ciphertext, praetorian.TransitPrefix) {
+		t.Fatalf("encrypt ciphertext = %q, want prefix %q", enc.Ciphertext, praetorian.TransitPrefix)
+	}
+
+	rewrapped := transitResult(t, transitRequest(t, praetorian.ActiveKeyID, keys, "rewrap/app", `{"ciphertext": "`+enc.Ciphertext+`"}`))
+	if rewrapped.Ciphertext == enc.Ciphertext {
+		t.Error("rewrap returned the original ciphertext")
+	}
+
+	dec := transitResult(t, transitRequest(t, praetorian.ActiveKeyID, keys, "decrypt/app", `{"ciphertext": "`+rewrapped.Ciphertext+`"}`))
+	if dec.Plaintext != pt {
+		t.Errorf("decrypt plaintext = %q, want %q", dec.Plaintext, pt)
+	}
+
+	// ciphertexts are bound to the key name.
+	rec := transitRequest(t, praetorian.ActiveKeyID, keys, "decrypt/reports", `{"ciphertext": "`+enc.Ciphertext+`"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("decrypt with another name status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandleTransit_KeyVersion(t *testing.T) {
+	keys := newTestKeystore(t, `{"activeKeyId": "2", "rootKeys": {"1": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU=", "2": "mWXgfqsJ0pjpeC+nAG8ElbaUlWbAG3LTeLcR14jdqwQ="}}`)
+	pt := base64.StdEncoding.EncodeToString([]byte("keep it secret, keep it safe"))
+
+	enc := transitResult(t, transitRequest(t, praetorian.ActiveKeyID, keys, "encrypt/app", `{"plaintext": "`+pt+`"}`))
+	if !strings.HasPrefix(enc.Ciphertext, "vault:v2:") || enc.KeyVersion != 2 {
+		t.Fatalf("encrypt ciphertext = %q, key version = %d, want version 2", enc.Ciphertext, enc.KeyVersion)
+	}
+
+	v1 := transitResult(t, transitRequest(t, "1", keys, "encrypt/app", `{"plaintext": "`+pt+`"}`))
+	if !strings.HasPrefix(v1.Ciphertext, "vault:v1:") || v1.KeyVersion != 1 {
+		t.Fatalf("encrypt ciphertext = %q, key version = %d, want version 1", v1.Ciphertext, v1.KeyVersion)
+	}
+	rewrapped := transitResult(t, transitRequest(t, praetorian.ActiveKeyID, keys, "rewrap/app", `{"ciphertext": "`+v1.Ciphertext+`"}`))
+	if rewrapped.KeyVersion != 2 {
+		t.Errorf("rewrap key version = %d, want 2", rewrapped.KeyVersion)
+	}
+
+	tampered := strings.Replace(enc.Ciphertext, "vault:v2:", "vault:v1:", 1)
+	if rec := transitRequest(t, praetorian.ActiveKeyID, keys, "decrypt/app", `{"ciphertext": "`+tampered+`"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("decrypt with another key version status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	named := newTestKeystore(t, `{"activeKeyId": "primary", "rootKeys": {"primary": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}}`)
+	if rec := transitRequest(t, praetorian.ActiveKeyID, named, "encrypt/app", `{"plaintext": "`+pt+`"}`); rec.Code != http.StatusInternalServerError {
+		t.Errorf("encrypt with a key id which is not a version status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+}
+
+func TestHandleTransit_DataKey(t *testing.T) {
+	keys := newTestKeystore(t, testConfig)
+
+	tests := []struct {
+		name          string
+		path          string
+		body          string
+		wantLength    int
+		wantPlaintext bool
+	}{
+		{name: "plaintext", path: "datakey/plaintext/app", body: `{}`, wantLength: 32, wantPlaintext: true},
+		{name: "wrapped", path: "datakey/wrapped/app", body: `{"bits": 128}`, wantLength: 16},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := transitResult(t, transitRequest(t, praetorian.ActiveKeyID, keys, tt.path, tt.body))
+			if (res.Plaintext != "") != tt.wantPlaintext {
+				t.Fatalf("datakey plaintext = %q, wantPlaintext = %v", res.Plaintext, tt.wantPlaintext)
+			}
+
+			dec := transitResult(t, transitRequest(t, praetorian.ActiveKeyID, keys, "decrypt/app", `{"ciphertext": "`+res.Ciphertext+`"}`))
+			dek, _ := base64.StdEncoding.DecodeString(dec.Plaintext)
+			if len(dek) != tt.wantLength {
+				t.Errorf("datakey length = %d, want %d", len(dek), tt.wantLength)
+			}
+			if tt.wantPlaintext && dec.Plaintext != res.Plaintext {
+				t.Errorf("decrypt plaintext = %q, want %q", dec.Plaintext, res.Plaintext)
+			}
+		})
+	}
+}
+
+func TestHandleTransit_Batch(t *testing.T) {
+	keys := newTestKeystore(t, testConfig)
+	ctx := base64.StdEncoding.EncodeToString([]byte("tenant-1"))
+	pt := base64.StdEncoding.EncodeToString([]byte("secret"))
+
+	enc := transitResult(t, transitRequest(t, praetorian.ActiveKeyID, keys, "encrypt/app",
+		`{"batch_input": [{"plaintext": "`+pt+`", "context": "`+ctx+`"}, {"plaintext": "!"}]}`))
+	if len(enc.BatchResults) != 2 || enc.BatchResults[1].Error == "" {
+		t.Fatalf("encrypt batch results = %+v, want an error for the second item", enc.BatchResults)
+	}
+
+	body, _ := json.Marshal(&praetorian.TransitRequest{BatchInput: []praetorian.TransitRequest{
+		{Ciphertext: enc.BatchResults[0].Ciphertext, Context: ctx},
+		{Ciphertext: enc.BatchResults[0].Ciphertext},
+	}})
+	dec := transitResult(t, transitRequest(t, praetorian.ActiveKeyID, keys, "decrypt/app", string(body)))
+	if dec.BatchResults[0].Plaintext != pt {
+		t.Errorf("decrypt plaintext = %q, want %q", dec.BatchResults[0].Plaintext, pt)
+	}
+	if dec.BatchResults[1].Error == "" {
+		t.Error("decrypt without the context succeeded")
+	}
+}
+
+func TestHandleTransit_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{name: "sealed keystore", path: "encrypt/app", body: `{"plaintext": ""}`, wantStatus: http.StatusServiceUnavailable},
+		{name: "missing prefix", path: "decrypt/app", body: `{"ciphertext": "AAAA"}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid bits", path: "datakey/plaintext/app", body: `{"bits": 64}`, wantStatus: http.StatusBadRequest},
+		{name: "unknown operation", path: "sign/app", body: `{}`, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := transitRequest(t, "sealed", &MockKeystore{}, tt.path, tt.body)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("HandleTransit() status = %d, wantStatus = %d", rec.Code, tt.wantStatus)
+			}
+			var res praetorian.TransitErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil || len(res.Errors) == 0 {
+				t.Errorf("HandleTransit() errors = %v, err = %v", res.Errors, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package praetorian
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type UnsealRequest struct {
+	Share string `json:"share"`
+}
+
+func HandleUnseal(u Unsealer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var b UnsealRequest
+		if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+			jsonResponse(w, http.StatusBadRequest, &ErrorResponse{
+				Message: "invalid JSON",
+			})
+			return
+		}
+
+		share, err := base64.StdEncoding.DecodeString(b.Share)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, &ErrorResponse{
+				Message: ErrInvalidShare.Error(),
+			})
+			return
+		}
+
+		status, err := u.Unseal(share)
+		if err != nil {
+			if errors.Is(err, ErrInvalidShare) {
+				jsonResponse(w, http.StatusBadRequest, &ErrorResponse{
+					Message: err.Error(),
+				})
+				return
+			}
+			if errors.Is(err, ErrUnsealFailed) {
+				jsonResponse(w, http.StatusUnprocessableEntity, &ErrorResponse{
+					Message: err.Error(),
+				})
+				return
+			}
+			jsonResponse(w, http.StatusInternalServerError, &ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, status)
+	}
+}
@@ -0,0 +1,109 @@
+package praetorian_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+func TestHandleUnseal(t *testing.T) {
+	u := &MockUnsealer{}
+	handler := praetorian.HandleUnseal(u)
+
+	req := httptest.NewRequest(http.MethodPost, "/unseal", strings.NewReader(`{"share": "c2hhcmU="}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("HandleUnseal() status = %d, wantStatus = %d", rec.Code, http.StatusOK)
+	}
+
+	var res praetorian.UnsealStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("HandleUnseal() failed to parse response: %v", err)
+	}
+
+	want := praetorian.UnsealStatus{Sealed: true, Threshold: 3, Progress: 1}
+	if res != want {
+		t.Errorf("HandleUnseal() got = %+v, want = %+v", res, want)
+	}
+}
+
+func TestHandleUnseal_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        io.Reader
+		method      string
+		wantStatus  int
+		wantMessage string
+	}{
+		{
+			name:        "invalid JSON body",
+			body:        strings.NewReader("{invalid}"),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "invalid JSON",
+		},
+		{
+			name:        "invalid base64 share",
+			body:        strings.NewReader(`{"share": "!!"}`),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "unable to decode unseal share",
+		},
+		{
+			name:        "invalid share",
+			body:        strings.NewReader(`{"share": "aW52YWxpZA=="}`),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "unable to decode unseal share",
+		},
+		{
+			name:        "unseal failed",
+			body:        strings.NewReader(`{"share": "ZmFpbGVk"}`),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantMessage: "unable to unseal root keys",
+		},
+		{
+			name:        "unseal error",
+			body:        strings.NewReader(`{"share": "ZXJyb3I="}`),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusInternalServerError,
+			wantMessage: "unseal error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &MockUnsealer{}
+			handler := praetorian.HandleUnseal(u)
+
+			req := httptest.NewRequest(tt.method, "/unseal", tt.body)
+			req.Header.Set("Content-Type", "application/json")
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("HandleUnseal() status = %d, wantStatus = %d", rec.Code, tt.wantStatus)
+			}
+
+			var res praetorian.ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+				t.Fatalf("HandleUnseal() failed to parse response: %v", err)
+			}
+
+			if res.Message != tt.wantMessage {
+				t.Errorf("HandleUnseal() got = %q, wantMessage = %q", res.Message, tt.wantMessage)
+			}
+		})
+	}
+}
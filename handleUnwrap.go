@@ -9,54 +9,67 @@ import (
 
 func HandleUnwrap(keys KeyFinder) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodPost:
-			var b WrapResponse
-			if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
-				jsonResponse(w, http.StatusBadRequest, &ErrorResponse{
-					Message: "invalid JSON",
-				})
-				return
-			}
+		var b WrapResponse
+		if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+			jsonResponse(w, http.StatusBadRequest, &ErrorResponse{
+				Message: "invalid JSON",
+			})
+			return
+		}
 
-			key, err := keys.Find(b.ID)
-			if err != nil {
-				jsonResponse(w, http.StatusNotFound, &ErrorResponse{
+		key, err := findKey(r.Context(), keys, b.ID)
+		if err != nil {
+			if unavailable(err) {
+				jsonResponse(w, http.StatusServiceUnavailable, &ErrorResponse{
 					Message: err.Error(),
 				})
 				return
 			}
+			jsonResponse(w, http.StatusNotFound, &ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
 
-			token, err := base64.StdEncoding.DecodeString(b.Token)
-			if err != nil {
-				jsonResponse(w, http.StatusBadRequest, &ErrorResponse{
-					Message: err.Error(),
-				})
-				return
-			}
+		if err := checkKeyState(keys, key, false); err != nil {
+			jsonResponse(w, http.StatusForbidden, &ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
 
-			dec, err := key.Decrypt(token)
-			if err != nil {
-				if errors.Is(err, ErrGCMOpen) {
-					jsonResponse(w, http.StatusUnprocessableEntity, &ErrorResponse{
-						Message: "data authentication failed",
-					})
-					return
-				}
-				jsonResponse(w, http.StatusInternalServerError, &ErrorResponse{
-					Message: err.Error(),
+		if err := observeUnwrap(r, key.ID()); err != nil {
+			jsonResponse(w, http.StatusForbidden, &ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		token, err := base64.StdEncoding.DecodeString(b.Token)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, &ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		dec, err := key.Decrypt(token)
+		if err != nil {
+			if errors.Is(err, ErrGCMOpen) {
+				jsonResponse(w, http.StatusUnprocessableEntity, &ErrorResponse{
+					Message: "data authentication failed",
 				})
 				return
 			}
+			jsonResponse(w, http.StatusInternalServerError, &ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
 
-			if err := json.NewEncoder(w).Encode(json.RawMessage(dec)); err != nil {
-				jsonResponse(w, http.StatusInternalServerError, &ErrorResponse{
-					Message: err.Error(),
-				})
-			}
-		default:
-			jsonResponse(w, http.StatusNotFound, &ErrorResponse{
-				Message: "Not Found",
+		if err := json.NewEncoder(w).Encode(json.RawMessage(dec)); err != nil {
+			jsonResponse(w, http.StatusInternalServerError, &ErrorResponse{
+				Message: err.Error(),
 			})
 		}
 	}
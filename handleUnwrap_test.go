@@ -79,13 +79,6 @@ func TestHandleUnwrap_Errors(t *testing.T) {
 			wantStatus:  http.StatusBadRequest,
 			wantMessage: "invalid JSON",
 		},
-		{
-			name:        "unsupported HTTP method",
-			body:        strings.NewReader("{}"),
-			method:      http.MethodGet,
-			wantStatus:  http.StatusNotFound,
-			wantMessage: "Not Found",
-		},
 		{
 			name:        "empty body",
 			body:        http.NoBody,
@@ -100,6 +93,13 @@ func TestHandleUnwrap_Errors(t *testing.T) {
 			wantStatus:  http.StatusNotFound,
 			wantMessage: "root key not found",
 		},
+		{
+			name:        "keystore sealed",
+			body:        strings.NewReader(`{"id": "sealed", "token": "ZW5jcnlwdGVkIG1lc3NhZ2U="}`),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusServiceUnavailable,
+			wantMessage: "keystore is sealed",
+		},
 		{
 			name:        "invalid encrypted data",
 			body:        strings.NewReader(`{"id": "1", "token": "b3Blbgo="}`),
@@ -12,52 +12,64 @@ type WrapResponse struct {
 	Token string `json:"token"`
 }
 
+// DataKey is the request body used to wrap a raw data encryption key, with
+// the key base64 encoded in JSON.
+type DataKey struct {
+	Key []byte `json:"key"`
+}
+
 func HandleWrap(activeKey string, keys KeyFinder) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodPost:
-			maxBytes := int64(1 << 20) // 1MB limit
-			b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
-			if err != nil {
-				jsonResponse(w, http.StatusBadRequest, &ErrorResponse{
-					Message: "failed to read request body",
-				})
-				return
-			}
-			defer r.Body.Close()
-
-			if !json.Valid(b) {
-				jsonResponse(w, http.StatusBadRequest, &ErrorResponse{
-					Message: "invalid JSON",
-				})
-				return
-			}
+		maxBytes := maxBodyBytes(r)
+		b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, &ErrorResponse{
+				Message: "failed to read request body",
+			})
+			return
+		}
+		defer r.Body.Close()
 
-			key, err := keys.Find(activeKey)
-			if err != nil {
-				jsonResponse(w, http.StatusNotFound, &ErrorResponse{
-					Message: err.Error(),
-				})
-				return
-			}
+		if !json.Valid(b) {
+			jsonResponse(w, http.StatusBadRequest, &ErrorResponse{
+				Message: "invalid JSON",
+			})
+			return
+		}
 
-			enc, err := key.Encrypt(b)
-			if err != nil {
-				jsonResponse(w, http.StatusInternalServerError, &ErrorResponse{
+		key, err := findKey(r.Context(), keys, activeKey)
+		if err != nil {
+			if unavailable(err) {
+				jsonResponse(w, http.StatusServiceUnavailable, &ErrorResponse{
 					Message: err.Error(),
 				})
 				return
 			}
+			jsonResponse(w, http.StatusNotFound, &ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
 
-			token := base64.StdEncoding.EncodeToString(enc)
-			jsonResponse(w, http.StatusCreated, &WrapResponse{
-				ID:    key.ID(),
-				Token: token,
+		if err := checkKeyState(keys, key, true); err != nil {
+			jsonResponse(w, http.StatusForbidden, &ErrorResponse{
+				Message: err.Error(),
 			})
-		default:
-			jsonResponse(w, http.StatusNotFound, &ErrorResponse{
-				Message: "Not Found",
+			return
+		}
+
+		enc, err := key.Encrypt(b)
+		if err != nil {
+			jsonResponse(w, http.StatusInternalServerError, &ErrorResponse{
+				Message: err.Error(),
 			})
+			return
 		}
+
+		token := base64.StdEncoding.EncodeToString(enc)
+		jsonResponse(w, http.StatusCreated, &WrapResponse{
+			ID:    key.ID(),
+			Token: token,
+		})
 	}
 }
@@ -87,14 +87,6 @@ func TestHandleWrap_Errors(t *testing.T) {
 			wantStatus:  http.StatusBadRequest,
 			wantMessage: "invalid JSON",
 		},
-		{
-			name:        "unsupported HTTP method",
-			activeKey:   praetorian.ActiveKeyID,
-			body:        strings.NewReader("{}"),
-			method:      http.MethodGet,
-			wantStatus:  http.StatusNotFound,
-			wantMessage: "Not Found",
-		},
 		{
 			name:        "empty body",
 			activeKey:   praetorian.ActiveKeyID,
@@ -135,6 +127,14 @@ func TestHandleWrap_Errors(t *testing.T) {
 			wantStatus:  http.StatusNotFound,
 			wantMessage: "root key not found",
 		},
+		{
+			name:        "keystore sealed",
+			activeKey:   "sealed",
+			body:        strings.NewReader(`{}`),
+			method:      http.MethodPost,
+			wantStatus:  http.StatusServiceUnavailable,
+			wantMessage: "keystore is sealed",
+		},
 	}
 
 	for _, tt := range tests {
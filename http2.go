@@ -0,0 +1,87 @@
+package praetorian
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// Environment variables which configure TLS and HTTP/2 on the HTTP listener.
+const (
+	EnvTLSCert         = "PRAETORIAN_TLS_CERT"
+	EnvTLSKey          = "PRAETORIAN_TLS_KEY"
+	EnvH2C             = "PRAETORIAN_H2C"
+	EnvHTTP2MaxStreams = "PRAETORIAN_HTTP2_MAX_STREAMS"
+)
+
+// HTTP2Options configure HTTP/2, which lets a caller multiplex many requests
+// over a single connection. HTTP/2 is always offered over TLS. Cleartext
+// accepts HTTP/2 without TLS (h2c) from clients which use it with prior
+// knowledge, alongside HTTP/1.1. MaxConcurrentStreams limits the requests in
+// flight on each connection, with zero using the Go default.
+type HTTP2Options struct {
+	Cleartext            bool
+	MaxConcurrentStreams int
+}
+
+// WithTLS serves HTTPS on the HTTP listener using the certificates of cfg.
+func WithTLS(cfg *tls.Config) ServerOption {
+	return func(s *Server) {
+		s.tlsConfig = cfg
+	}
+}
+
+// WithHTTP2 configures HTTP/2 on every HTTP listener.
+func WithHTTP2(o HTTP2Options) ServerOption {
+	return func(s *Server) {
+		s.http2 = o
+	}
+}
+
+// TLSConfigFromEnv loads the certificate and key named in the environment,
+// returning nil when no certificate is configured.
+func TLSConfigFromEnv() (*tls.Config, error) {
+	certFile := os.Getenv(EnvTLSCert)
+	if certFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, os.Getenv(EnvTLSKey))
+	if err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// HTTP2OptionsFromEnv reads the HTTP/2 settings from the environment.
+func HTTP2OptionsFromEnv() (HTTP2Options, error) {
+	var o HTTP2Options
+	if val := os.Getenv(EnvH2C); val != "" {
+		h2c, err := strconv.ParseBool(val)
+		if err != nil {
+			return o, fmt.Errorf("%s: %w", EnvH2C, err)
+		}
+		o.Cleartext = h2c
+	}
+	if val := os.Getenv(EnvHTTP2MaxStreams); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 0 {
+			return o, fmt.Errorf("%s: invalid stream count %q", EnvHTTP2MaxStreams, val)
+		}
+		o.MaxConcurrentStreams = n
+	}
+	return o, nil
+}
+
+// protocols returns the protocols accepted by the HTTP listeners.
+func (o HTTP2Options) protocols() *http.Protocols {
+	var p http.Protocols
+	p.SetHTTP1(true)
+	p.SetHTTP2(true)
+	p.SetUnencryptedHTTP2(o.Cleartext)
+	return &p
+}
@@ -0,0 +1,125 @@
+package praetorian_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+func TestServer_HTTP2(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	ca, caKey := newTestCertificate(t, nil, nil, "praetorian ca")
+	cert, _ := newTestCertificate(t, ca, caKey, "localhost")
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+
+	var h2c http.Protocols
+	h2c.SetUnencryptedHTTP2(true)
+
+	tests := []struct {
+		name      string
+		opts      []praetorian.ServerOption
+		scheme    string
+		transport *http.Transport
+		wantProto int
+		wantErr   bool
+	}{
+		{
+			name:      "h2c",
+			opts:      []praetorian.ServerOption{praetorian.WithHTTP2(praetorian.HTTP2Options{Cleartext: true})},
+			scheme:    "http",
+			transport: &http.Transport{Protocols: &h2c},
+			wantProto: 2,
+		},
+		{
+			name:      "h2c disabled",
+			scheme:    "http",
+			transport: &http.Transport{Protocols: &h2c},
+			wantErr:   true,
+		},
+		{
+			name:      "HTTP/1.1 with h2c enabled",
+			opts:      []praetorian.ServerOption{praetorian.WithHTTP2(praetorian.HTTP2Options{Cleartext: true})},
+			scheme:    "http",
+			transport: &http.Transport{},
+			wantProto: 1,
+		},
+		{
+			name:      "HTTP/2 over TLS",
+			opts:      []praetorian.ServerOption{praetorian.WithTLS(&tls.Config{Certificates: []tls.Certificate{*cert}})},
+			scheme:    "https",
+			transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}, ForceAttemptHTTP2: true},
+			wantProto: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv := praetorian.NewServer(&MockKeystore{}, tt.opts...)
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() {
+				done <- srv.Serve(ctx, ln)
+			}()
+			defer func() {
+				cancel()
+				<-done
+			}()
+
+			client := &http.Client{Transport: tt.transport}
+			defer tt.transport.CloseIdleConnections()
+			_, port, _ := net.SplitHostPort(ln.Addr().String())
+			res, err := client.Get(tt.scheme + "://localhost:" + port + "/healthz")
+			if tt.wantErr {
+				if err == nil {
+					res.Body.Close()
+					t.Fatal("GET /healthz succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GET /healthz error = %v", err)
+			}
+			res.Body.Close()
+			if res.ProtoMajor != tt.wantProto {
+				t.Errorf("GET /healthz protocol = %s, want HTTP/%d", res.Proto, tt.wantProto)
+			}
+		})
+	}
+}
+
+func TestHTTP2OptionsFromEnv(t *testing.T) {
+	t.Setenv(praetorian.EnvH2C, "true")
+	t.Setenv(praetorian.EnvHTTP2MaxStreams, "500")
+
+	o, err := praetorian.HTTP2OptionsFromEnv()
+	if err != nil {
+		t.Fatalf("HTTP2OptionsFromEnv() error = %v", err)
+	}
+	want := praetorian.HTTP2Options{Cleartext: true, MaxConcurrentStreams: 500}
+	if o != want {
+		t.Errorf("HTTP2OptionsFromEnv() = %+v, want = %+v", o, want)
+	}
+
+	srv := praetorian.NewServer(&MockKeystore{}, praetorian.WithHTTP2(o))
+	if got := srv.HTTP2.MaxConcurrentStreams; got != 500 {
+		t.Errorf("Server.HTTP2.MaxConcurrentStreams = %d, want = %d", got, 500)
+	}
+	if !srv.Protocols.UnencryptedHTTP2() {
+		t.Error("Server.Protocols does not include unencrypted HTTP/2")
+	}
+}
@@ -0,0 +1,52 @@
+package praetorian
+
+import (
+	"crypto/subtle"
+)
+
+// keyBlobV1 is the version of ciphertexts produced by sealKeyBlob.
+const keyBlobV1 = 0x01
+
+// sealKeyBlob encrypts the digest of the data the ciphertext is bound to
+// followed by the plaintext, prefixing the result with the version and the
+// root key ID so that it can be decrypted without naming the key.
+func sealKeyBlob(key RootKey, digest, pt []byte) ([]byte, error) {
+	if len(key.ID()) > 255 {
+		return nil, ErrInvalidKeyBlob
+	}
+	payload := append(append([]byte(nil), digest...), pt...)
+	defer clear(payload)
+
+	enc, err := key.Encrypt(payload)
+	if err != nil {
+		return nil, err
+	}
+	blob := append([]byte{keyBlobV1, byte(len(key.ID()))}, key.ID()...)
+	return append(blob, enc...), nil
+}
+
+// keyBlobID returns the root key ID of a ciphertext from sealKeyBlob.
+func keyBlobID(blob []byte) (string, error) {
+	if len(blob) < 2 || blob[0] != keyBlobV1 || len(blob) < 2+int(blob[1]) {
+		return "", ErrInvalidKeyBlob
+	}
+	return string(blob[2 : 2+int(blob[1])]), nil
+}
+
+// openKeyBlob decrypts a ciphertext from sealKeyBlob, returning ErrGCMOpen
+// when it is not bound to the digest.
+func openKeyBlob(key RootKey, blob, digest []byte) ([]byte, error) {
+	id, err := keyBlobID(blob)
+	if err != nil {
+		return nil, err
+	}
+	dec, err := key.Decrypt(blob[2+len(id):])
+	if err != nil {
+		return nil, err
+	}
+	if len(dec) < len(digest) || subtle.ConstantTimeCompare(dec[:len(digest)], digest) != 1 {
+		clear(dec)
+		return nil, ErrGCMOpen
+	}
+	return dec[len(digest):], nil
+}
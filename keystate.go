@@ -0,0 +1,211 @@
+package praetorian
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// KeyState is the lifecycle state of a root key, numbered as in KMIP.
+type KeyState uint32
+
+const (
+	KeyStatePreActive   KeyState = 1
+	KeyStateActive      KeyState = 2
+	KeyStateDeactivated KeyState = 3
+	KeyStateCompromised KeyState = 4
+	KeyStateDestroyed   KeyState = 5
+)
+
+// KeyStater is implemented by keystores which record the lifecycle state of
+// their root keys. Keys without a recorded state are active.
+type KeyStater interface {
+	KeyState(id string) KeyState
+	SetKeyState(id string, state KeyState) error
+}
+
+// KeyPersister is implemented by keystores which can report whether the keys
+// they import and their states are kept across restarts.
+type KeyPersister interface {
+	Persistent() bool
+}
+
+// checkKeyState returns ErrRootKeyInactive unless the state of the key allows
+// it to be used. Only active keys encrypt, while deactivated keys can still
+// decrypt the data they protect. Keys which are pre-active, compromised or
+// destroyed cannot be used at all.
+func checkKeyState(keys KeyFinder, key RootKey, encrypt bool) error {
+	ks, ok := keys.(KeyStater)
+	if !ok {
+		return nil
+	}
+	switch ks.KeyState(key.ID()) {
+	case KeyStateActive:
+		return nil
+	case KeyStateDeactivated:
+		if !encrypt {
+			return nil
+		}
+	}
+	return ErrRootKeyInactive
+}
+
+// KeyState returns the lifecycle state of the root key.
+func (ks *keystore) KeyState(id string) KeyState {
+	ks.stateMu.Lock()
+	defer ks.stateMu.Unlock()
+	if state, ok := ks.states[id]; ok {
+		return state
+	}
+	return KeyStateActive
+}
+
+// Persistent reports whether a state file is configured, without which
+// imported keys and key states are only kept in memory.
+func (ks *keystore) Persistent() bool {
+	return ks.stateFile != ""
+}
+
+// SetKeyState records the lifecycle state of the root key, writing it to the
+// state file when one is configured.
+func (ks *keystore) SetKeyState(id string, state KeyState) error {
+	if ks.Sealed() {
+		return ErrKeystoreSealed
+	}
+	ks.stateMu.Lock()
+	defer ks.stateMu.Unlock()
+	prev, ok := ks.states[id]
+	ks.states[id] = state
+	if err := ks.saveState(); err != nil {
+		if ok {
+			ks.states[id] = prev
+		} else {
+			delete(ks.states, id)
+		}
+		return err
+	}
+	return nil
+}
+
+// keyState is the content of the state file, which keeps the root keys
+// imported while the server is running and the lifecycle state of every key
+// across restarts. Each key is stored as a key blob sealed under the active
+// root key at the time it was written. Destroyed keys keep their state so
+// that keys from the config stay destroyed.
+type keyState struct {
+	Keys   map[string][]byte   `json:"keys,omitempty"`
+	States map[string]KeyState `json:"states,omitempty"`
+}
+
+// stateDigest binds the sealed key material to its identifier.
+func stateDigest(id string) []byte {
+	sum := sha256.Sum256([]byte(id))
+	return sum[:]
+}
+
+// loadState adds the keys from the state file to the root keys of the config
+// which sealed them, removing destroyed keys, and returns the imported keys
+// and key states to record in the keystore.
+func (ks *keystore) loadState(keys map[string]RootKey) (map[string]struct{}, map[string]KeyState, error) {
+	imported := make(map[string]struct{})
+	states := make(map[string]KeyState)
+	if ks.stateFile == "" {
+		return imported, states, nil
+	}
+	b, err := os.ReadFile(ks.stateFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return imported, states, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var st keyState
+	if err := json.Unmarshal(b, &st); err != nil {
+		return nil, nil, ErrInvalidKeyState
+	}
+
+	for id, blob := range st.Keys {
+		kid, err := keyBlobID(blob)
+		if err != nil {
+			return nil, nil, ErrInvalidKeyState
+		}
+		sealer, ok := keys[kid]
+		if !ok {
+			return nil, nil, ErrInvalidKeyState
+		}
+		value, err := openKeyBlob(sealer, blob, stateDigest(id))
+		if err != nil {
+			return nil, nil, ErrInvalidKeyState
+		}
+		if _, ok := keys[id]; ok {
+			return nil, nil, ErrRootKeyExists
+		}
+		keys[id] = &key{id, value}
+		imported[id] = struct{}{}
+	}
+	for id, state := range st.States {
+		states[id] = state
+		// the active key is refused by its state rather than removed.
+		if state == KeyStateDestroyed && id != ks.activeID {
+			delete(keys, id)
+		}
+	}
+	return imported, states, nil
+}
+
+// saveState writes the imported keys and key states to the state file,
+// replacing it atomically so that a failed write leaves the previous state in
+// place. It must be called with stateMu held.
+func (ks *keystore) saveState() error {
+	if ks.stateFile == "" {
+		return nil
+	}
+	sealer, ok := ks.Load(ActiveKeyID)
+	if !ok {
+		return ErrKeystoreSealed
+	}
+	st := keyState{Keys: make(map[string][]byte, len(ks.imported))}
+	for id := range ks.imported {
+		k, ok := ks.Load(id)
+		if !ok {
+			continue
+		}
+		blob, err := sealKeyBlob(sealer.(RootKey), stateDigest(id), k.(*key).value)
+		if err != nil {
+			return err
+		}
+		st.Keys[id] = blob
+	}
+	// states are only kept for keys which exist, or were destroyed.
+	st.States = make(map[string]KeyState, len(ks.states))
+	for id, state := range ks.states {
+		if _, ok := ks.Load(id); ok || state == KeyStateDestroyed {
+			st.States[id] = state
+		}
+	}
+	b, err := json.Marshal(&st)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(ks.stateFile), ".praetorian-state-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), ks.stateFile)
+}
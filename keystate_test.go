@@ -0,0 +1,93 @@
+package praetorian_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+func TestKeyState(t *testing.T) {
+	cfg, err := praetorian.ParseConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("ParseConfig() failed: %v", err)
+	}
+	cfg.StateFile = filepath.Join(t.TempDir(), "state.json")
+	ks, err := praetorian.NewKeystore(cfg)
+	if err != nil {
+		t.Fatalf("NewKeystore() failed: %v", err)
+	}
+
+	call := func(h http.Handler, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	wrap := praetorian.HandleWrap(praetorian.ActiveKeyID, ks)
+	unwrap := praetorian.HandleUnwrap(ks)
+
+	rec := call(wrap, `{"secret": "abc123"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("HandleWrap() status = %d, wantStatus = %d", rec.Code, http.StatusCreated)
+	}
+	wrapped := rec.Body.String()
+
+	tests := []struct {
+		name             string
+		state            praetorian.KeyState
+		wantWrapStatus   int
+		wantUnwrapStatus int
+	}{
+		{
+			name:             "active",
+			state:            praetorian.KeyStateActive,
+			wantWrapStatus:   http.StatusCreated,
+			wantUnwrapStatus: http.StatusOK,
+		},
+		{
+			name:             "pre-active",
+			state:            praetorian.KeyStatePreActive,
+			wantWrapStatus:   http.StatusForbidden,
+			wantUnwrapStatus: http.StatusForbidden,
+		},
+		{
+			name:             "deactivated",
+			state:            praetorian.KeyStateDeactivated,
+			wantWrapStatus:   http.StatusForbidden,
+			wantUnwrapStatus: http.StatusOK,
+		},
+		{
+			name:             "compromised",
+			state:            praetorian.KeyStateCompromised,
+			wantWrapStatus:   http.StatusForbidden,
+			wantUnwrapStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ks.(praetorian.KeyStater).SetKeyState("1", tt.state); err != nil {
+				t.Fatalf("SetKeyState() failed: %v", err)
+			}
+			if rec := call(wrap, `{"secret": "abc123"}`); rec.Code != tt.wantWrapStatus {
+				t.Errorf("HandleWrap() status = %d, wantStatus = %d", rec.Code, tt.wantWrapStatus)
+			}
+			if rec := call(unwrap, wrapped); rec.Code != tt.wantUnwrapStatus {
+				t.Errorf("HandleUnwrap() status = %d, wantStatus = %d", rec.Code, tt.wantUnwrapStatus)
+			}
+
+			restarted, err := praetorian.NewKeystore(cfg)
+			if err != nil {
+				t.Fatalf("NewKeystore() failed to load the state file: %v", err)
+			}
+			if got := restarted.(praetorian.KeyStater).KeyState("1"); got != tt.state {
+				t.Errorf("KeyState() after a restart = %d, want %d", got, tt.state)
+			}
+		})
+	}
+}
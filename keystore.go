@@ -1,6 +1,8 @@
 package praetorian
 
 import (
+	"bytes"
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"sync"
@@ -8,16 +10,40 @@ import (
 
 type keystore struct {
 	sync.Map
+	signers sync.Map
+	macs    sync.Map
+
+	mu             sync.Mutex
+	activeID       string
+	activeSignerID string
+	activeMACID    string
+	sealed         *Config
+	shares         [][]byte
+	threshold      int
+
+	stateMu   sync.Mutex
+	stateFile string
+	imported  map[string]struct{}
+	states    map[string]KeyState
 }
 
 // NewKeyset initializes a new Keyring from the provided config and returns it.
-func NewKeystore(cfg *config) (KeyFinder, error) {
-	ks := &keystore{}
-	for id, val := range cfg.RootKeys {
-		if id == cfg.ActiveKeyID {
-			ks.Store("active", &key{id, val})
-		}
-		ks.Store(id, &key{id, val})
+func NewKeystore(cfg *Config) (KeyFinder, error) {
+	ks := &keystore{
+		activeID:       cfg.ActiveKeyID,
+		activeSignerID: cfg.ActiveSigningKeyID,
+		activeMACID:    cfg.ActiveMACKeyID,
+		stateFile:      cfg.StateFile,
+		imported:       make(map[string]struct{}),
+		states:         make(map[string]KeyState),
+	}
+	if cfg.Sealed() {
+		ks.sealed = cfg
+		ks.threshold = cfg.UnsealThreshold
+		return ks, nil
+	}
+	if err := ks.load(cfg); err != nil {
+		return nil, err
 	}
 	return ks, nil
 }
@@ -25,11 +51,150 @@ func NewKeystore(cfg *config) (KeyFinder, error) {
 // Find a root key with the given identifier.
 func (ks *keystore) Find(id string) (RootKey, error) {
 	if k, ok := ks.Load(id); ok {
-		return k.(*key), nil
+		return k.(RootKey), nil
+	}
+	if ks.Sealed() {
+		return nil, ErrKeystoreSealed
 	}
 	return nil, ErrRootKeyNotFound
 }
 
+// FindContext returns a root key unless the context is already done.
+func (ks *keystore) FindContext(ctx context.Context, id string) (RootKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return ks.Find(id)
+}
+
+// FindSigner returns a signing key with the given identifier.
+func (ks *keystore) FindSigner(id string) (SigningKey, error) {
+	if k, ok := ks.signers.Load(id); ok {
+		return k.(SigningKey), nil
+	}
+	if ks.Sealed() {
+		return nil, ErrKeystoreSealed
+	}
+	return nil, ErrSigningKeyNotFound
+}
+
+// FindMAC returns a MAC key with the given identifier.
+func (ks *keystore) FindMAC(id string) (MACKey, error) {
+	if k, ok := ks.macs.Load(id); ok {
+		return k.(MACKey), nil
+	}
+	if ks.Sealed() {
+		return nil, ErrKeystoreSealed
+	}
+	return nil, ErrMACKeyNotFound
+}
+
+// Import adds a root key which does not already exist in the keystore. The
+// key is written to the state file when one is configured, and otherwise only
+// kept in memory until the process exits.
+func (ks *keystore) Import(id string, value []byte) error {
+	if id == ActiveKeyID {
+		return ErrRootKeyExists
+	}
+	if ks.Sealed() {
+		return ErrKeystoreSealed
+	}
+	if _, loaded := ks.LoadOrStore(id, &key{id, bytes.Clone(value)}); loaded {
+		return ErrRootKeyExists
+	}
+
+	ks.stateMu.Lock()
+	defer ks.stateMu.Unlock()
+	ks.imported[id] = struct{}{}
+	if err := ks.saveState(); err != nil {
+		delete(ks.imported, id)
+		ks.Delete(id)
+		return err
+	}
+	return nil
+}
+
+// Destroy removes a root key other than the active key from the keystore,
+// recording it in the state file when one is configured.
+func (ks *keystore) Destroy(id string) error {
+	if ks.Sealed() {
+		return ErrKeystoreSealed
+	}
+	if id == ActiveKeyID || id == ks.activeID {
+		return ErrActiveRootKey
+	}
+	if _, loaded := ks.LoadAndDelete(id); !loaded {
+		return ErrRootKeyNotFound
+	}
+
+	// keys from the config are kept destroyed by their state, while imported
+	// keys are simply forgotten.
+	ks.stateMu.Lock()
+	defer ks.stateMu.Unlock()
+	if _, ok := ks.imported[id]; ok {
+		delete(ks.imported, id)
+		delete(ks.states, id)
+	} else {
+		ks.states[id] = KeyStateDestroyed
+	}
+	return ks.saveState()
+}
+
+func (ks *keystore) load(cfg *Config) error {
+	keys := make(map[string]RootKey, len(cfg.RootKeys)+len(cfg.ECDHKeys))
+	for id, val := range cfg.RootKeys {
+		keys[id] = &key{id, val}
+	}
+	for id, val := range cfg.ECDHKeys {
+		priv, err := val.privateKey()
+		if err != nil {
+			return err
+		}
+		keys[id] = &ecdhKey{id, val.Curve, priv}
+	}
+
+	signers := make(map[string]SigningKey, len(cfg.SigningKeys))
+	for id, val := range cfg.SigningKeys {
+		sk, err := val.signingKey(id)
+		if err != nil {
+			return err
+		}
+		signers[id] = sk
+	}
+
+	// nothing is published until the state file has been read as well, so a
+	// failed load leaves the keystore as it was.
+	imported, states, err := ks.loadState(keys)
+	if err != nil {
+		return err
+	}
+
+	for id, k := range keys {
+		if id == ks.activeID {
+			ks.Store(ActiveKeyID, k)
+		}
+		ks.Store(id, k)
+	}
+	for id, sk := range signers {
+		if id == ks.activeSignerID {
+			ks.signers.Store(ActiveKeyID, sk)
+		}
+		ks.signers.Store(id, sk)
+	}
+	for id, val := range cfg.MACKeys {
+		mk := &macKey{id, val}
+		if id == ks.activeMACID {
+			ks.macs.Store(ActiveKeyID, mk)
+		}
+		ks.macs.Store(id, mk)
+	}
+
+	ks.stateMu.Lock()
+	defer ks.stateMu.Unlock()
+	ks.imported, ks.states = imported, states
+	return nil
+}
+
 type key struct {
 	id    string
 	value []byte
@@ -1,7 +1,11 @@
 package praetorian_test
 
 import (
+	"bytes"
+	"encoding/base64"
 	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/karlbateman/praetorian"
@@ -140,3 +144,58 @@ func TestKey_Decrypt(t *testing.T) {
 		})
 	}
 }
+
+func TestKeystore_StateFile(t *testing.T) {
+	cfg, err := praetorian.ParseConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("ParseConfig() failed: %v", err)
+	}
+	cfg.StateFile = filepath.Join(t.TempDir(), "state.json")
+	value := bytes.Repeat([]byte{7}, praetorian.RootKeyLength)
+
+	ks, err := praetorian.NewKeystore(cfg)
+	if err != nil {
+		t.Fatalf("NewKeystore() failed: %v", err)
+	}
+	if err := ks.(praetorian.KeyImporter).Import("2", value); err != nil {
+		t.Fatalf("Keystore.Import() failed: %v", err)
+	}
+	b, err := os.ReadFile(cfg.StateFile)
+	if err != nil {
+		t.Fatalf("Keystore.Import() did not write the state file: %v", err)
+	}
+	if bytes.Contains(b, []byte(base64.StdEncoding.EncodeToString(value))) {
+		t.Error("Keystore.Import() wrote the key material in plain text")
+	}
+
+	restarted, err := praetorian.NewKeystore(cfg)
+	if err != nil {
+		t.Fatalf("NewKeystore() failed to load the state file: %v", err)
+	}
+	orig, err := ks.Find("2")
+	if err != nil {
+		t.Fatalf("Keystore.Find() failed: %v", err)
+	}
+	enc, err := orig.Encrypt([]byte("keep it secret"))
+	if err != nil {
+		t.Fatalf("RootKey.Encrypt() failed: %v", err)
+	}
+	k, err := restarted.Find("2")
+	if err != nil {
+		t.Fatalf("Keystore.Find() failed to return the imported key after a restart: %v", err)
+	}
+	if _, err := k.Decrypt(enc); err != nil {
+		t.Errorf("RootKey.Decrypt() failed with the restored key: %v", err)
+	}
+
+	if err := restarted.(praetorian.KeyDestroyer).Destroy("2"); err != nil {
+		t.Fatalf("Keystore.Destroy() failed: %v", err)
+	}
+	restarted, err = praetorian.NewKeystore(cfg)
+	if err != nil {
+		t.Fatalf("NewKeystore() failed: %v", err)
+	}
+	if _, err := restarted.Find("2"); !errors.Is(err, praetorian.ErrRootKeyNotFound) {
+		t.Errorf("Keystore.Find() error = %v, wantErr = %v", err, praetorian.ErrRootKeyNotFound)
+	}
+}
@@ -0,0 +1,230 @@
+package praetorian
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+)
+
+const (
+	KeyExportRSA  = "RSA-OAEP-256"
+	KeyExportECDH = "ECDH-ES+A256GCM"
+)
+
+// info string used to derive the ECDH key encryption key.
+const keyExportInfo = "praetorian key export"
+
+// KeyExport is a root key encrypted under the public key of a recipient.
+type KeyExport struct {
+	ID              string `json:"id"`
+	Algorithm       string `json:"algorithm"`
+	EncapsulatedKey string `json:"encapsulatedKey,omitempty"`
+	Ciphertext      string `json:"ciphertext"`
+}
+
+// KeyImporter adds root keys received from another deployment.
+type KeyImporter interface {
+	Import(id string, value []byte) error
+}
+
+// ExportKey encrypts the root key material under the recipient public key
+// which must be an RSA key or a P-256 ECDSA or ECDH key.
+func ExportKey(k RootKey, recipient crypto.PublicKey) (*KeyExport, error) {
+	rk, ok := k.(*key)
+	if !ok {
+		return nil, ErrKeyNotExportable
+	}
+
+	switch pub := recipient.(type) {
+	case *rsa.PublicKey:
+		enc, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, rk.value, []byte(rk.id))
+		if err != nil {
+			return nil, err
+		}
+		return &KeyExport{
+			ID:         rk.id,
+			Algorithm:  KeyExportRSA,
+			Ciphertext: base64.StdEncoding.EncodeToString(enc),
+		}, nil
+	case *ecdsa.PublicKey:
+		ep, err := pub.ECDH()
+		if err != nil {
+			return nil, ErrUnsupportedTransferKey
+		}
+		return ExportKey(k, ep)
+	case *ecdh.PublicKey:
+		if pub.Curve() != ecdh.P256() {
+			return nil, ErrUnsupportedTransferKey
+		}
+		eph, err := ecdh.P256().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		kek, err := transferKEK(eph, pub)
+		if err != nil {
+			return nil, err
+		}
+		enc, err := aeadSeal(kek, rk.value, []byte(rk.id))
+		if err != nil {
+			return nil, err
+		}
+		return &KeyExport{
+			ID:              rk.id,
+			Algorithm:       KeyExportECDH,
+			EncapsulatedKey: base64.StdEncoding.EncodeToString(eph.PublicKey().Bytes()),
+			Ciphertext:      base64.StdEncoding.EncodeToString(enc),
+		}, nil
+	}
+	return nil, ErrUnsupportedTransferKey
+}
+
+// ImportKey decrypts the exported root key with the recipient private key and
+// adds it to the importer, so the key material is never returned to callers.
+func ImportKey(imp KeyImporter, e *KeyExport, recipient crypto.PrivateKey) error {
+	ci, err := base64.StdEncoding.DecodeString(e.Ciphertext)
+	if err != nil {
+		return ErrInvalidKeyExport
+	}
+
+	var value []byte
+	switch e.Algorithm {
+	case KeyExportRSA:
+		priv, ok := recipient.(*rsa.PrivateKey)
+		if !ok {
+			return ErrUnsupportedTransferKey
+		}
+		value, err = rsa.DecryptOAEP(sha256.New(), nil, priv, ci, []byte(e.ID))
+		if err != nil {
+			return ErrInvalidKeyExport
+		}
+	case KeyExportECDH:
+		priv, err := ecdhPrivateKey(recipient)
+		if err != nil {
+			return err
+		}
+		b, err := base64.StdEncoding.DecodeString(e.EncapsulatedKey)
+		if err != nil {
+			return ErrInvalidKeyExport
+		}
+		eph, err := ecdh.P256().NewPublicKey(b)
+		if err != nil {
+			return ErrInvalidKeyExport
+		}
+		kek, err := transferKEK(priv, eph)
+		if err != nil {
+			return err
+		}
+		value, err = aeadOpen(kek, ci, []byte(e.ID))
+		if err != nil {
+			return ErrInvalidKeyExport
+		}
+	default:
+		return ErrUnsupportedTransferKey
+	}
+	defer clear(value)
+
+	if len(value) != RootKeyLength {
+		return ErrInvalidRootKeyLength
+	}
+	return imp.Import(e.ID, value)
+}
+
+// ParsePublicKeyPEM decodes a PKIX public key from PEM.
+func ParsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrUnsupportedTransferKey
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, ErrUnsupportedTransferKey
+	}
+	return pub, nil
+}
+
+// ParsePrivateKeyPEM decodes a PKCS #8, PKCS #1 or SEC 1 private key from PEM.
+func ParsePrivateKeyPEM(data []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrUnsupportedTransferKey
+	}
+	if priv, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		return priv, nil
+	}
+	if priv, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return priv, nil
+	}
+	if priv, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return priv, nil
+	}
+	return nil, ErrUnsupportedTransferKey
+}
+
+// MarshalPublicKeyPEM encodes a public key as PKIX PEM.
+func MarshalPublicKeyPEM(pub crypto.PublicKey) ([]byte, error) {
+	b, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, ErrUnsupportedTransferKey
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: b}), nil
+}
+
+func ecdhPrivateKey(priv crypto.PrivateKey) (*ecdh.PrivateKey, error) {
+	switch k := priv.(type) {
+	case *ecdh.PrivateKey:
+		if k.Curve() == ecdh.P256() {
+			return k, nil
+		}
+	case *ecdsa.PrivateKey:
+		if ek, err := k.ECDH(); err == nil && ek.Curve() == ecdh.P256() {
+			return ek, nil
+		}
+	}
+	return nil, ErrUnsupportedTransferKey
+}
+
+func transferKEK(priv *ecdh.PrivateKey, pub *ecdh.PublicKey) ([]byte, error) {
+	shared, err := priv.ECDH(pub)
+	if err != nil {
+		return nil, ErrInvalidKeyExport
+	}
+	defer clear(shared)
+	return hkdf.Key(sha256.New, shared, nil, keyExportInfo, RootKeyLength)
+}
+
+func aeadSeal(kek, plaintext, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, ErrNewCipherBlock
+	}
+	gcm, err := cipher.NewGCMWithRandomNonce(block)
+	if err != nil {
+		return nil, ErrNewGCMWithRandomNonce
+	}
+	return gcm.Seal(nil, nil, plaintext, aad), nil
+}
+
+func aeadOpen(kek, ciphertext, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, ErrNewCipherBlock
+	}
+	gcm, err := cipher.NewGCMWithRandomNonce(block)
+	if err != nil {
+		return nil, ErrNewGCMWithRandomNonce
+	}
+	pt, err := gcm.Open(nil, nil, ciphertext, aad)
+	if err != nil {
+		return nil, ErrGCMOpen
+	}
+	return pt, nil
+}
@@ -0,0 +1,184 @@
+package praetorian_test
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+func newTestKeystore(t *testing.T, config string) praetorian.KeyFinder {
+	t.Helper()
+	t.Setenv(praetorian.EnvKey, config)
+	cfg, err := praetorian.NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig() failed to create config: %v", err)
+	}
+	ks, err := praetorian.NewKeystore(cfg)
+	if err != nil {
+		t.Fatalf("NewKeystore() failed to create keystore: %v", err)
+	}
+	return ks
+}
+
+func TestExportKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey() failed: %v", err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey() failed: %v", err)
+	}
+	ecdhKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdh.GenerateKey() failed: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		pub           crypto.PublicKey
+		priv          crypto.PrivateKey
+		wantAlgorithm string
+	}{
+		{
+			name:          "rsa recipient",
+			pub:           &rsaKey.PublicKey,
+			priv:          rsaKey,
+			wantAlgorithm: praetorian.KeyExportRSA,
+		},
+		{
+			name:          "ecdsa recipient",
+			pub:           &ecdsaKey.PublicKey,
+			priv:          ecdsaKey,
+			wantAlgorithm: praetorian.KeyExportECDH,
+		},
+		{
+			name:          "ecdh recipient",
+			pub:           ecdhKey.PublicKey(),
+			priv:          ecdhKey,
+			wantAlgorithm: praetorian.KeyExportECDH,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := newTestKeystore(t, testConfig)
+			k, err := src.Find("1")
+			if err != nil {
+				t.Fatalf("Keystore.Find() failed to return key: %v", err)
+			}
+			enc, err := k.Encrypt([]byte("a secret never to be told"))
+			if err != nil {
+				t.Fatalf("Key.Encrypt() failed to encrypt data: %v", err)
+			}
+
+			e, err := praetorian.ExportKey(k, tt.pub)
+			if err != nil {
+				t.Fatalf("ExportKey() failed to export key: %v", err)
+			}
+			if e.Algorithm != tt.wantAlgorithm {
+				t.Errorf("ExportKey() algorithm = %q, wantAlgorithm = %q", e.Algorithm, tt.wantAlgorithm)
+			}
+
+			dst := newTestKeystore(t, `{"activeKeyId": "2", "rootKeys": {"2": "BPK//lj6hlpjuA5gPZo19OIjUDgnIQAAAAAAAAAAAAA="}}`)
+			if err := praetorian.ImportKey(dst.(praetorian.KeyImporter), e, tt.priv); err != nil {
+				t.Fatalf("ImportKey() failed to import key: %v", err)
+			}
+
+			imported, err := dst.Find("1")
+			if err != nil {
+				t.Fatalf("Keystore.Find() failed to return imported key: %v", err)
+			}
+			got, err := imported.Decrypt(enc)
+			if err != nil {
+				t.Fatalf("Key.Decrypt() failed with imported key: %v", err)
+			}
+			if string(got) != "a secret never to be told" {
+				t.Errorf("Key.Decrypt() got = %q", got)
+			}
+		})
+	}
+}
+
+func TestImportKey_Errors(t *testing.T) {
+	recipient, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdh.GenerateKey() failed: %v", err)
+	}
+	other, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdh.GenerateKey() failed: %v", err)
+	}
+
+	ks := newTestKeystore(t, testConfig)
+	k, err := ks.Find("1")
+	if err != nil {
+		t.Fatalf("Keystore.Find() failed to return key: %v", err)
+	}
+	e, err := praetorian.ExportKey(k, recipient.PublicKey())
+	if err != nil {
+		t.Fatalf("ExportKey() failed to export key: %v", err)
+	}
+
+	tampered := *e
+	tampered.ID = "2"
+
+	tests := []struct {
+		name    string
+		export  *praetorian.KeyExport
+		priv    crypto.PrivateKey
+		wantErr error
+	}{
+		{
+			name:    "wrong recipient",
+			export:  e,
+			priv:    other,
+			wantErr: praetorian.ErrInvalidKeyExport,
+		},
+		{
+			name:    "tampered identifier",
+			export:  &tampered,
+			priv:    recipient,
+			wantErr: praetorian.ErrInvalidKeyExport,
+		},
+		{
+			name:    "unsupported algorithm",
+			export:  &praetorian.KeyExport{ID: "1", Algorithm: "none"},
+			priv:    recipient,
+			wantErr: praetorian.ErrUnsupportedTransferKey,
+		},
+		{
+			name:    "root key exists",
+			export:  e,
+			priv:    recipient,
+			wantErr: praetorian.ErrRootKeyExists,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := praetorian.ImportKey(ks.(praetorian.KeyImporter), tt.export, tt.priv)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ImportKey() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExportKey_NotExportable(t *testing.T) {
+	recipient, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdh.GenerateKey() failed: %v", err)
+	}
+	_, err = praetorian.ExportKey(&MockKey{}, recipient.PublicKey())
+	if !errors.Is(err, praetorian.ErrKeyNotExportable) {
+		t.Errorf("ExportKey() error = %v, wantErr = %v", err, praetorian.ErrKeyNotExportable)
+	}
+}
@@ -0,0 +1,742 @@
+package praetorian
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Environment variables which enable the KMIP listener. KMIP clients must
+// authenticate with a certificate issued by the client CA.
+const (
+	EnvKMIPAddr     = "PRAETORIAN_KMIP_ADDR"
+	EnvKMIPCert     = "PRAETORIAN_KMIP_CERT"
+	EnvKMIPKey      = "PRAETORIAN_KMIP_KEY"
+	EnvKMIPClientCA = "PRAETORIAN_KMIP_CLIENT_CA"
+)
+
+const (
+	kmipMaxMessage  = 1 << 20 // 1MB limit
+	kmipIdleTimeout = 2 * time.Minute
+)
+
+// KMIP tags.
+const (
+	kmipTagAttribute               = 0x420008
+	kmipTagAttributeName           = 0x42000A
+	kmipTagAttributeValue          = 0x42000B
+	kmipTagBatchCount              = 0x42000D
+	kmipTagBatchItem               = 0x42000F
+	kmipTagCryptographicAlgorithm  = 0x420028
+	kmipTagCryptographicLength     = 0x42002A
+	kmipTagCryptographicUsageMask  = 0x42002C
+	kmipTagObjectType              = 0x420057
+	kmipTagOperation               = 0x42005C
+	kmipTagProtocolVersion         = 0x420069
+	kmipTagProtocolVersionMajor    = 0x42006A
+	kmipTagProtocolVersionMinor    = 0x42006B
+	kmipTagRequestHeader           = 0x420077
+	kmipTagRequestMessage          = 0x420078
+	kmipTagRequestPayload          = 0x420079
+	kmipTagResponseHeader          = 0x42007A
+	kmipTagResponseMessage         = 0x42007B
+	kmipTagResponsePayload         = 0x42007C
+	kmipTagResultMessage           = 0x42007D
+	kmipTagResultReason            = 0x42007E
+	kmipTagResultStatus            = 0x42007F
+	kmipTagRevocationReason        = 0x420081
+	kmipTagRevocationReasonCode    = 0x420082
+	kmipTagState                   = 0x42008D
+	kmipTagTemplateAttribute       = 0x420091
+	kmipTagTimeStamp               = 0x420092
+	kmipTagUniqueBatchItemID       = 0x420093
+	kmipTagUniqueIdentifier        = 0x420094
+	kmipTagData                    = 0x4200C2
+	kmipAttrUniqueIdentifier       = "Unique Identifier"
+	kmipAttrObjectType             = "Object Type"
+	kmipAttrCryptographicAlgorithm = "Cryptographic Algorithm"
+	kmipAttrCryptographicLength    = "Cryptographic Length"
+	kmipAttrCryptographicUsageMask = "Cryptographic Usage Mask"
+	kmipAttrState                  = "State"
+)
+
+// KMIP operations.
+const (
+	kmipOpCreate        = 0x01
+	kmipOpGetAttributes = 0x0B
+	kmipOpActivate      = 0x12
+	kmipOpRevoke        = 0x13
+	kmipOpDestroy       = 0x14
+	kmipOpEncrypt       = 0x1F
+	kmipOpDecrypt       = 0x20
+)
+
+// KMIP result reasons.
+const (
+	kmipReasonItemNotFound          = 0x01
+	kmipReasonInvalidMessage        = 0x04
+	kmipReasonOperationNotSupported = 0x05
+	kmipReasonMissingData           = 0x06
+	kmipReasonInvalidField          = 0x07
+	kmipReasonFeatureNotSupported   = 0x08
+	kmipReasonCryptographicFailure  = 0x0A
+	kmipReasonIllegalOperation      = 0x0B
+	kmipReasonPermissionDenied      = 0x0C
+	kmipReasonGeneralFailure        = 0x100
+)
+
+// KMIP enumeration values.
+const (
+	kmipStatusSuccess         = 0x00
+	kmipStatusOperationFailed = 0x01
+	kmipObjectSymmetricKey    = 0x02
+	kmipAlgorithmAES          = 0x03
+	kmipUsageEncrypt          = 0x04
+	kmipUsageDecrypt          = 0x08
+	kmipRevokeKeyCompromise   = 0x02
+)
+
+// kmipOperations names the operations for rate limiting, which limits them
+// as the paths "/kmip/<name>".
+var kmipOperations = map[uint32]string{
+	kmipOpCreate:        "create",
+	kmipOpGetAttributes: "get-attributes",
+	kmipOpActivate:      "activate",
+	kmipOpRevoke:        "revoke",
+	kmipOpDestroy:       "destroy",
+	kmipOpEncrypt:       "encrypt",
+	kmipOpDecrypt:       "decrypt",
+}
+
+// KeyDestroyer is implemented by keystores which can remove root keys.
+type KeyDestroyer interface {
+	Destroy(id string) error
+}
+
+// kmipError is a failed batch item.
+type kmipError struct {
+	reason  uint32
+	message string
+}
+
+func (e *kmipError) Error() string {
+	return e.message
+}
+
+// KMIPServer serves a subset of KMIP 1.x over TTLV, mapping symmetric keys
+// onto the root keys of the keystore. The lifecycle state of each key is
+// recorded by the keystore, which persists it and enforces it for every
+// protocol, with keys from the config starting out active.
+type KMIPServer struct {
+	keys      KeyFinder
+	limiter   *rateLimiter
+	anomalies *AnomalyDetector
+
+	mu     sync.Mutex
+	ln     net.Listener
+	conns  map[net.Conn]bool // whether a request is being handled
+	closed bool
+}
+
+// KMIPOption configures optional behaviour of the KMIP server.
+type KMIPOption func(*KMIPServer)
+
+// WithKMIPRateLimits limits how quickly each client can perform operations,
+// which are limited as the paths "/kmip/<operation>", such as "/kmip/decrypt".
+// Clients are identified by their remote address.
+func WithKMIPRateLimits(limits RateLimits) KMIPOption {
+	return func(s *KMIPServer) {
+		if limits.Enabled() {
+			s.limiter = newRateLimiter(limits)
+		}
+	}
+}
+
+// WithKMIPAnomalyDetector observes decrypts with the detector, refusing them
+// for clients which it has locked out.
+func WithKMIPAnomalyDetector(d *AnomalyDetector) KMIPOption {
+	return func(s *KMIPServer) {
+		s.anomalies = d
+	}
+}
+
+// NewKMIPServer returns a KMIP server for the keystore.
+func NewKMIPServer(keys KeyFinder, opts ...KMIPOption) *KMIPServer {
+	s := &KMIPServer{
+		keys:  keys,
+		conns: make(map[net.Conn]bool),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// KMIPTLSConfigFromEnv returns the listen address and TLS configuration of
+// the KMIP listener, or an empty address when KMIP is not enabled.
+func KMIPTLSConfigFromEnv() (string, *tls.Config, error) {
+	addr := os.Getenv(EnvKMIPAddr)
+	if addr == "" {
+		return "", nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(os.Getenv(EnvKMIPCert), os.Getenv(EnvKMIPKey))
+	if err != nil {
+		return "", nil, fmt.Errorf("kmip: %w", err)
+	}
+	path := os.Getenv(EnvKMIPClientCA)
+	if path == "" {
+		return "", nil, ErrKMIPClientCARequired
+	}
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("kmip: %w", err)
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    x509.NewCertPool(),
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}
+	if !cfg.ClientCAs.AppendCertsFromPEM(pem) {
+		return "", nil, fmt.Errorf("kmip: no certificates found in %s", path)
+	}
+	return addr, cfg, nil
+}
+
+// Serve accepts KMIP connections on the listener until it is closed.
+func (s *KMIPServer) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		ln.Close()
+		return net.ErrClosed
+	}
+	s.ln = ln
+	s.mu.Unlock()
+
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return net.ErrClosed
+			}
+			return err
+		}
+		s.mu.Lock()
+		s.conns[c] = false
+		s.mu.Unlock()
+		go s.serveConn(c)
+	}
+}
+
+// Shutdown stops the listener, closes idle connections and waits for the
+// requests being handled to complete, until ctx is done, when it closes the
+// remaining connections and returns the context's error.
+func (s *KMIPServer) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.ln != nil {
+		err = s.ln.Close()
+	}
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if s.closeIdle() {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			s.Close()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// closeIdle closes the connections which are not handling a request,
+// reporting whether none remain.
+func (s *KMIPServer) closeIdle() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c, busy := range s.conns {
+		if !busy {
+			c.Close()
+			delete(s.conns, c)
+		}
+	}
+	return len(s.conns) == 0
+}
+
+// Close stops the listener and closes any open connections.
+func (s *KMIPServer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for c := range s.conns {
+		c.Close()
+	}
+	if s.ln != nil {
+		return s.ln.Close()
+	}
+	return nil
+}
+
+func (s *KMIPServer) serveConn(c net.Conn) {
+	client := c.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(client); err == nil {
+		client = host
+	}
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.Close()
+	}()
+
+	for {
+		c.SetReadDeadline(time.Now().Add(kmipIdleTimeout))
+		b, err := ReadTTLV(c, kmipMaxMessage)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Println("kmip:", err)
+			}
+			return
+		}
+
+		if !s.setBusy(c, true) {
+			return
+		}
+
+		var req TTLV
+		res, ok := TTLV{}, false
+		if err := req.UnmarshalBinary(b); err == nil && req.Tag == kmipTagRequestMessage {
+			res, ok = s.handle(client, req)
+		}
+		if !ok {
+			res = kmipResponse(kmipVersion(req), kmipFailure(0, nil, &kmipError{kmipReasonInvalidMessage, "invalid request message"}))
+		}
+
+		out, err := res.MarshalBinary()
+		if err != nil {
+			log.Println("kmip:", err)
+			return
+		}
+		if _, err := c.Write(out); err != nil || !ok {
+			return
+		}
+		if !s.setBusy(c, false) {
+			return
+		}
+	}
+}
+
+// setBusy records whether the connection is handling a request, reporting
+// false when the server is shutting down and the connection should be closed
+// instead.
+func (s *KMIPServer) setBusy(c net.Conn, busy bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed && !busy {
+		return false
+	}
+	if _, ok := s.conns[c]; !ok {
+		return false
+	}
+	s.conns[c] = busy
+	return true
+}
+
+// handle processes each batch item of the request from the client in order.
+func (s *KMIPServer) handle(client string, req TTLV) (TTLV, bool) {
+	header, ok := req.Find(kmipTagRequestHeader)
+	if !ok {
+		return TTLV{}, false
+	}
+	version := kmipVersion(req)
+	if major, _ := version.Find(kmipTagProtocolVersionMajor); major.Uint32() != 1 {
+		return TTLV{}, false
+	}
+
+	items := req.FindAll(kmipTagBatchItem)
+	if count, ok := header.Find(kmipTagBatchCount); !ok || int(count.Uint32()) != len(items) || len(items) == 0 {
+		return TTLV{}, false
+	}
+
+	results := make([]TTLV, 0, len(items))
+	for _, item := range items {
+		op, _ := item.Find(kmipTagOperation)
+		batchID, hasID := item.Find(kmipTagUniqueBatchItemID)
+		payload, _ := item.Find(kmipTagRequestPayload)
+
+		res, err := s.operation(client, op.Uint32(), payload)
+		var id *TTLV
+		if hasID {
+			id = &batchID
+		}
+		if err != nil {
+			results = append(results, kmipFailure(op.Uint32(), id, err))
+			continue
+		}
+		fields := []TTLV{NewTTLVEnumeration(kmipTagOperation, op.Uint32())}
+		if id != nil {
+			fields = append(fields, *id)
+		}
+		fields = append(fields,
+			NewTTLVEnumeration(kmipTagResultStatus, kmipStatusSuccess),
+			NewTTLVStructure(kmipTagResponsePayload, res...),
+		)
+		results = append(results, NewTTLVStructure(kmipTagBatchItem, fields...))
+	}
+	return kmipResponse(version, results...), true
+}
+
+func (s *KMIPServer) operation(client string, op uint32, payload TTLV) ([]TTLV, error) {
+	if s.limiter != nil {
+		if _, ok := s.limiter.allow(client, "/kmip/"+kmipOperations[op]); !ok {
+			return nil, &kmipError{kmipReasonGeneralFailure, "rate limit exceeded"}
+		}
+		if !s.limiter.acquire() {
+			return nil, &kmipError{kmipReasonGeneralFailure, "too many operations in flight"}
+		}
+		defer s.limiter.release()
+	}
+
+	switch op {
+	case kmipOpCreate:
+		return s.create(payload)
+	case kmipOpGetAttributes:
+		return s.getAttributes(payload)
+	case kmipOpActivate:
+		return s.activate(payload)
+	case kmipOpRevoke:
+		return s.revoke(payload)
+	case kmipOpDestroy:
+		return s.destroy(payload)
+	case kmipOpEncrypt:
+		return s.crypt(client, payload, true)
+	case kmipOpDecrypt:
+		return s.crypt(client, payload, false)
+	}
+	return nil, &kmipError{kmipReasonOperationNotSupported, "operation not supported"}
+}
+
+// create generates a pre-active AES-256 key and imports it as a root key.
+func (s *KMIPServer) create(payload TTLV) ([]TTLV, error) {
+	imp, ok := s.keys.(KeyImporter)
+	if !ok {
+		return nil, &kmipError{kmipReasonOperationNotSupported, "keystore does not support creating keys"}
+	}
+	st, ok := s.keys.(KeyStater)
+	if !ok {
+		return nil, &kmipError{kmipReasonOperationNotSupported, "keystore does not support key states"}
+	}
+	// keys which would be lost on a restart, along with the data they
+	// protect, are never created.
+	if p, ok := s.keys.(KeyPersister); !ok || !p.Persistent() {
+		return nil, &kmipError{kmipReasonOperationNotSupported, "keys can only be created with a state file"}
+	}
+	if typ, _ := payload.Find(kmipTagObjectType); typ.Uint32() != kmipObjectSymmetricKey {
+		return nil, &kmipError{kmipReasonInvalidField, "only symmetric keys can be created"}
+	}
+
+	tmpl, _ := payload.Find(kmipTagTemplateAttribute)
+	for _, attr := range tmpl.FindAll(kmipTagAttribute) {
+		name, _ := attr.Find(kmipTagAttributeName)
+		value, _ := attr.Find(kmipTagAttributeValue)
+		switch name.Text() {
+		case kmipAttrCryptographicAlgorithm:
+			if value.Uint32() != kmipAlgorithmAES {
+				return nil, &kmipError{kmipReasonInvalidField, "only AES keys can be created"}
+			}
+		case kmipAttrCryptographicLength:
+			if value.Uint32() != RootKeyLength*8 {
+				return nil, &kmipError{kmipReasonInvalidField, "only 256-bit keys can be created"}
+			}
+		}
+	}
+
+	value := make([]byte, RootKeyLength)
+	if _, err := rand.Read(value); err != nil {
+		return nil, err
+	}
+	defer clear(value)
+	id, err := kmipUUID()
+	if err != nil {
+		return nil, err
+	}
+	// the key cannot be used before its identifier has been returned, so it is
+	// imported before its state is recorded and destroyed again if that fails.
+	if err := imp.Import(id, value); err != nil {
+		return nil, kmipErrorFrom(err)
+	}
+	if err := st.SetKeyState(id, KeyStatePreActive); err != nil {
+		if d, ok := s.keys.(KeyDestroyer); ok {
+			_ = d.Destroy(id)
+		}
+		return nil, kmipErrorFrom(err)
+	}
+
+	return []TTLV{
+		NewTTLVEnumeration(kmipTagObjectType, kmipObjectSymmetricKey),
+		NewTTLVText(kmipTagUniqueIdentifier, id),
+	}, nil
+}
+
+func (s *KMIPServer) getAttributes(payload TTLV) ([]TTLV, error) {
+	key, state, err := s.find(payload, false)
+	if err != nil {
+		return nil, err
+	}
+	attrs := map[string]TTLV{
+		kmipAttrUniqueIdentifier:       NewTTLVText(kmipTagAttributeValue, key.ID()),
+		kmipAttrObjectType:             NewTTLVEnumeration(kmipTagAttributeValue, kmipObjectSymmetricKey),
+		kmipAttrCryptographicAlgorithm: NewTTLVEnumeration(kmipTagAttributeValue, kmipAlgorithmAES),
+		kmipAttrCryptographicLength:    NewTTLVInteger(kmipTagAttributeValue, RootKeyLength*8),
+		kmipAttrCryptographicUsageMask: NewTTLVInteger(kmipTagAttributeValue, kmipUsageEncrypt|kmipUsageDecrypt),
+		kmipAttrState:                  NewTTLVEnumeration(kmipTagAttributeValue, uint32(state)),
+	}
+
+	var names []string
+	for _, n := range payload.FindAll(kmipTagAttributeName) {
+		names = append(names, n.Text())
+	}
+	if len(names) == 0 {
+		names = []string{
+			kmipAttrUniqueIdentifier,
+			kmipAttrObjectType,
+			kmipAttrCryptographicAlgorithm,
+			kmipAttrCryptographicLength,
+			kmipAttrCryptographicUsageMask,
+			kmipAttrState,
+		}
+	}
+
+	res := []TTLV{NewTTLVText(kmipTagUniqueIdentifier, key.ID())}
+	for _, name := range names {
+		// unknown attributes are omitted from the response.
+		if v, ok := attrs[name]; ok {
+			res = append(res, NewTTLVStructure(kmipTagAttribute, NewTTLVText(kmipTagAttributeName, name), v))
+		}
+	}
+	return res, nil
+}
+
+func (s *KMIPServer) activate(payload TTLV) ([]TTLV, error) {
+	key, state, err := s.find(payload, false)
+	if err != nil {
+		return nil, err
+	}
+	if state != KeyStatePreActive {
+		return nil, &kmipError{kmipReasonIllegalOperation, "key is not pre-active"}
+	}
+	if err := s.setState(key.ID(), KeyStateActive); err != nil {
+		return nil, err
+	}
+	return []TTLV{NewTTLVText(kmipTagUniqueIdentifier, key.ID())}, nil
+}
+
+func (s *KMIPServer) revoke(payload TTLV) ([]TTLV, error) {
+	key, state, err := s.find(payload, false)
+	if err != nil {
+		return nil, err
+	}
+	reason, ok := payload.Find(kmipTagRevocationReason)
+	if !ok {
+		return nil, &kmipError{kmipReasonMissingData, "revocation reason is required"}
+	}
+	code, _ := reason.Find(kmipTagRevocationReasonCode)
+	// every wrap uses the active root key, which must be rotated in the config
+	// rather than revoked.
+	if active, err := s.keys.Find(ActiveKeyID); err == nil && active.ID() == key.ID() {
+		return nil, &kmipError{kmipReasonIllegalOperation, "the active root key cannot be revoked"}
+	}
+
+	switch {
+	case code.Uint32() == kmipRevokeKeyCompromise:
+		state = KeyStateCompromised
+	case state == KeyStatePreActive || state == KeyStateActive:
+		state = KeyStateDeactivated
+	default:
+		return nil, &kmipError{kmipReasonIllegalOperation, "key has already been revoked"}
+	}
+	if err := s.setState(key.ID(), state); err != nil {
+		return nil, err
+	}
+	return []TTLV{NewTTLVText(kmipTagUniqueIdentifier, key.ID())}, nil
+}
+
+// destroy removes a key which is no longer active from the keystore.
+func (s *KMIPServer) destroy(payload TTLV) ([]TTLV, error) {
+	d, ok := s.keys.(KeyDestroyer)
+	if !ok {
+		return nil, &kmipError{kmipReasonOperationNotSupported, "keystore does not support destroying keys"}
+	}
+	key, state, err := s.find(payload, false)
+	if err != nil {
+		return nil, err
+	}
+	if state == KeyStateActive {
+		return nil, &kmipError{kmipReasonIllegalOperation, "active keys must be revoked before they are destroyed"}
+	}
+	if err := d.Destroy(key.ID()); err != nil {
+		return nil, kmipErrorFrom(err)
+	}
+	return []TTLV{NewTTLVText(kmipTagUniqueIdentifier, key.ID())}, nil
+}
+
+// crypt encrypts with active keys and decrypts with active or deactivated
+// keys, as every other protocol does. Compromised keys can no longer be used.
+// Ciphertexts are prefixed with their nonce, so no IV is returned. Decrypts
+// are observed by the anomaly detector as unwraps by the client.
+func (s *KMIPServer) crypt(client string, payload TTLV, encrypt bool) ([]TTLV, error) {
+	key, _, err := s.find(payload, true)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := payload.Find(kmipTagData)
+	if !ok {
+		return nil, &kmipError{kmipReasonMissingData, "data is required"}
+	}
+
+	if err := checkKeyState(s.keys, key, encrypt); err != nil {
+		return nil, kmipErrorFrom(err)
+	}
+
+	if !encrypt && s.anomalies != nil {
+		if err := s.anomalies.Observe(client, key.ID()); err != nil {
+			return nil, &kmipError{kmipReasonPermissionDenied, err.Error()}
+		}
+	}
+
+	var out []byte
+	if encrypt {
+		out, err = key.Encrypt(data.Bytes())
+	} else {
+		out, err = key.Decrypt(data.Bytes())
+	}
+	if err != nil {
+		return nil, kmipErrorFrom(err)
+	}
+	return []TTLV{
+		NewTTLVText(kmipTagUniqueIdentifier, key.ID()),
+		NewTTLVBytes(kmipTagData, out),
+	}, nil
+}
+
+// find returns the key named by the payload and its state. When allowed, a
+// missing identifier refers to the active key.
+func (s *KMIPServer) find(payload TTLV, defaultActive bool) (RootKey, KeyState, error) {
+	id := ActiveKeyID
+	if uid, ok := payload.Find(kmipTagUniqueIdentifier); ok {
+		id = uid.Text()
+	} else if !defaultActive {
+		return nil, 0, &kmipError{kmipReasonMissingData, "unique identifier is required"}
+	}
+
+	k, err := s.keys.Find(id)
+	if err != nil {
+		return nil, 0, kmipErrorFrom(err)
+	}
+	if _, ok := k.(PublicRootKey); ok {
+		return nil, 0, &kmipError{kmipReasonFeatureNotSupported, "only symmetric root keys are available over KMIP"}
+	}
+
+	state := KeyStateActive
+	if st, ok := s.keys.(KeyStater); ok {
+		state = st.KeyState(k.ID())
+	}
+	return k, state, nil
+}
+
+// setState records the state of the key in the keystore.
+func (s *KMIPServer) setState(id string, state KeyState) error {
+	st, ok := s.keys.(KeyStater)
+	if !ok {
+		return &kmipError{kmipReasonOperationNotSupported, "keystore does not support key states"}
+	}
+	if err := st.SetKeyState(id, state); err != nil {
+		return kmipErrorFrom(err)
+	}
+	return nil
+}
+
+func kmipErrorFrom(err error) error {
+	var ke *kmipError
+	switch {
+	case errors.As(err, &ke):
+		return ke
+	case errors.Is(err, ErrRootKeyNotFound):
+		return &kmipError{kmipReasonItemNotFound, err.Error()}
+	case errors.Is(err, ErrGCMOpen):
+		return &kmipError{kmipReasonCryptographicFailure, "data authentication failed"}
+	case errors.Is(err, ErrActiveRootKey), errors.Is(err, ErrRootKeyInactive):
+		return &kmipError{kmipReasonIllegalOperation, err.Error()}
+	}
+	return &kmipError{kmipReasonGeneralFailure, err.Error()}
+}
+
+func kmipFailure(op uint32, batchID *TTLV, err error) TTLV {
+	var ke *kmipError
+	if !errors.As(kmipErrorFrom(err), &ke) {
+		ke = &kmipError{kmipReasonGeneralFailure, err.Error()}
+	}
+	var fields []TTLV
+	if op != 0 {
+		fields = append(fields, NewTTLVEnumeration(kmipTagOperation, op))
+	}
+	if batchID != nil {
+		fields = append(fields, *batchID)
+	}
+	return NewTTLVStructure(kmipTagBatchItem, append(fields,
+		NewTTLVEnumeration(kmipTagResultStatus, kmipStatusOperationFailed),
+		NewTTLVEnumeration(kmipTagResultReason, ke.reason),
+		NewTTLVText(kmipTagResultMessage, ke.message),
+	)...)
+}
+
+func kmipResponse(version TTLV, items ...TTLV) TTLV {
+	header := NewTTLVStructure(kmipTagResponseHeader,
+		version,
+		NewTTLVDateTime(kmipTagTimeStamp, time.Now()),
+		NewTTLVInteger(kmipTagBatchCount, int32(len(items))),
+	)
+	return NewTTLVStructure(kmipTagResponseMessage, append([]TTLV{header}, items...)...)
+}
+
+// kmipVersion returns the protocol version of the request, defaulting to 1.0.
+func kmipVersion(req TTLV) TTLV {
+	header, _ := req.Find(kmipTagRequestHeader)
+	if v, ok := header.Find(kmipTagProtocolVersion); ok {
+		return v
+	}
+	return NewTTLVStructure(kmipTagProtocolVersion,
+		NewTTLVInteger(kmipTagProtocolVersionMajor, 1),
+		NewTTLVInteger(kmipTagProtocolVersionMinor, 0),
+	)
+}
+
+// kmipUUID returns a random version 4 UUID.
+func kmipUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
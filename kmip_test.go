@@ -0,0 +1,523 @@
+package praetorian_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/karlbateman/praetorian"
+)
+
+const (
+	tagAttribute            = 0x420008
+	tagAttributeName        = 0x42000A
+	tagAttributeValue       = 0x42000B
+	tagBatchCount           = 0x42000D
+	tagBatchItem            = 0x42000F
+	tagObjectType           = 0x420057
+	tagOperation            = 0x42005C
+	tagProtocolVersion      = 0x420069
+	tagProtocolVersionMajor = 0x42006A
+	tagProtocolVersionMinor = 0x42006B
+	tagRequestHeader        = 0x420077
+	tagRequestMessage       = 0x420078
+	tagRequestPayload       = 0x420079
+	tagResponsePayload      = 0x42007C
+	tagResultReason         = 0x42007E
+	tagResultStatus         = 0x42007F
+	tagRevocationReason     = 0x420081
+	tagRevocationReasonCode = 0x420082
+	tagUniqueIdentifier     = 0x420094
+	tagData                 = 0x4200C2
+
+	opCreate        = 0x01
+	opGetAttributes = 0x0B
+	opActivate      = 0x12
+	opRevoke        = 0x13
+	opDestroy       = 0x14
+	opEncrypt       = 0x1F
+	opDecrypt       = 0x20
+
+	reasonItemNotFound     = 0x01
+	reasonInvalidMessage   = 0x04
+	reasonCryptoFailure    = 0x0A
+	reasonIllegalOperation = 0x0B
+	reasonPermissionDenied = 0x0C
+	reasonGeneralFailure   = 0x100
+
+	statePreActive   = 0x01
+	stateActive      = 0x02
+	stateDeactivated = 0x03
+)
+
+// kmipClient sends single item batches over a TLS connection.
+type kmipClient struct {
+	t    *testing.T
+	srv  *praetorian.KMIPServer
+	conn *tls.Conn
+}
+
+func newKMIPClient(t *testing.T, keys praetorian.KeyFinder, opts ...praetorian.KMIPOption) *kmipClient {
+	t.Helper()
+	ca, caKey := newTestCertificate(t, nil, nil, "kmip ca")
+	serverCert, _ := newTestCertificate(t, ca, caKey, "localhost")
+	clientCert, _ := newTestCertificate(t, ca, caKey, "kmip client")
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{*serverCert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	srv := praetorian.NewKMIPServer(keys, opts...)
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{
+		Certificates: []tls.Certificate{*clientCert},
+		RootCAs:      pool,
+		ServerName:   "localhost",
+	})
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &kmipClient{t: t, srv: srv, conn: conn}
+}
+
+func (c *kmipClient) send(req praetorian.TTLV) praetorian.TTLV {
+	c.t.Helper()
+	b, err := req.MarshalBinary()
+	if err != nil {
+		c.t.Fatalf("MarshalBinary() error = %v", err)
+	}
+	return c.sendRaw(b)
+}
+
+func (c *kmipClient) sendRaw(b []byte) praetorian.TTLV {
+	c.t.Helper()
+	c.conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := c.conn.Write(b); err != nil {
+		c.t.Fatalf("failed to write request: %v", err)
+	}
+	b, err := praetorian.ReadTTLV(c.conn, 1<<20)
+	if err != nil {
+		c.t.Fatalf("failed to read response: %v", err)
+	}
+	var res praetorian.TTLV
+	if err := res.UnmarshalBinary(b); err != nil {
+		c.t.Fatalf("UnmarshalBinary() error = %v", err)
+	}
+	return res
+}
+
+// call returns the response payload, or the result reason of a failure.
+func (c *kmipClient) call(op uint32, payload ...praetorian.TTLV) (praetorian.TTLV, uint32) {
+	c.t.Helper()
+	res := c.send(praetorian.NewTTLVStructure(tagRequestMessage,
+		praetorian.NewTTLVStructure(tagRequestHeader,
+			praetorian.NewTTLVStructure(tagProtocolVersion,
+				praetorian.NewTTLVInteger(tagProtocolVersionMajor, 1),
+				praetorian.NewTTLVInteger(tagProtocolVersionMinor, 4),
+			),
+			praetorian.NewTTLVInteger(tagBatchCount, 1),
+		),
+		praetorian.NewTTLVStructure(tagBatchItem,
+			praetorian.NewTTLVEnumeration(tagOperation, op),
+			praetorian.NewTTLVStructure(tagRequestPayload, payload...),
+		),
+	))
+	item, ok := res.Find(tagBatchItem)
+	if !ok {
+		c.t.Fatalf("response has no batch item")
+	}
+	if status, _ := item.Find(tagResultStatus); status.Uint32() != 0 {
+		reason, _ := item.Find(tagResultReason)
+		return praetorian.TTLV{}, reason.Uint32()
+	}
+	payloadRes, _ := item.Find(tagResponsePayload)
+	return payloadRes, 0
+}
+
+func (c *kmipClient) state(id string) uint32 {
+	c.t.Helper()
+	res, reason := c.call(opGetAttributes,
+		praetorian.NewTTLVText(tagUniqueIdentifier, id),
+		praetorian.NewTTLVText(tagAttributeName, "State"),
+	)
+	if reason != 0 {
+		c.t.Fatalf("GetAttributes reason = %#x", reason)
+	}
+	attr, _ := res.Find(tagAttribute)
+	value, _ := attr.Find(tagAttributeValue)
+	return value.Uint32()
+}
+
+func newTestCertificate(t *testing.T, parent *tls.Certificate, parentKey *ecdsa.PrivateKey, name string) (*tls.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{name},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerCert := priv, tmpl
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerCert = parentKey, parent.Leaf
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signerCert, &priv.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: priv, Leaf: leaf}, priv
+}
+
+// createPayload requests a 256-bit AES key.
+var createPayload = []praetorian.TTLV{
+	praetorian.NewTTLVEnumeration(tagObjectType, 2),
+	praetorian.NewTTLVStructure(0x420091,
+		praetorian.NewTTLVStructure(tagAttribute,
+			praetorian.NewTTLVText(tagAttributeName, "Cryptographic Algorithm"),
+			praetorian.NewTTLVEnumeration(tagAttributeValue, 3),
+		),
+		praetorian.NewTTLVStructure(tagAttribute,
+			praetorian.NewTTLVText(tagAttributeName, "Cryptographic Length"),
+			praetorian.NewTTLVInteger(tagAttributeValue, 256),
+		),
+	),
+}
+
+func TestKMIPServer_Lifecycle(t *testing.T) {
+	cfg, err := praetorian.ParseConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("ParseConfig() failed: %v", err)
+	}
+	cfg.StateFile = filepath.Join(t.TempDir(), "state.json")
+	ks, err := praetorian.NewKeystore(cfg)
+	if err != nil {
+		t.Fatalf("NewKeystore() failed: %v", err)
+	}
+	c := newKMIPClient(t, ks)
+
+	res, reason := c.call(opCreate, createPayload...)
+	if reason != 0 {
+		t.Fatalf("Create reason = %#x", reason)
+	}
+	uid, _ := res.Find(tagUniqueIdentifier)
+	id := uid.Text()
+	if got := c.state(id); got != statePreActive {
+		t.Errorf("state after Create = %d, want %d", got, statePreActive)
+	}
+	restarted, err := praetorian.NewKeystore(cfg)
+	if err != nil {
+		t.Fatalf("NewKeystore() failed to load the state file: %v", err)
+	}
+	if got := restarted.(praetorian.KeyStater).KeyState(id); got != praetorian.KeyStatePreActive {
+		t.Errorf("KeyState() after a restart = %d, want %d", got, praetorian.KeyStatePreActive)
+	}
+
+	plaintext := []byte("keep it secret, keep it safe")
+	encrypt := func() (praetorian.TTLV, uint32) {
+		return c.call(opEncrypt, praetorian.NewTTLVText(tagUniqueIdentifier, id), praetorian.NewTTLVBytes(tagData, plaintext))
+	}
+	if _, reason := encrypt(); reason != reasonIllegalOperation {
+		t.Errorf("Encrypt with a pre-active key reason = %#x, want %#x", reason, reasonIllegalOperation)
+	}
+
+	if _, reason := c.call(opActivate, praetorian.NewTTLVText(tagUniqueIdentifier, id)); reason != 0 {
+		t.Fatalf("Activate reason = %#x", reason)
+	}
+	if got := c.state(id); got != stateActive {
+		t.Errorf("state after Activate = %d, want %d", got, stateActive)
+	}
+
+	res, reason = encrypt()
+	if reason != 0 {
+		t.Fatalf("Encrypt reason = %#x", reason)
+	}
+	data, _ := res.Find(tagData)
+	ciphertext := data.Bytes()
+
+	if _, reason := c.call(opDestroy, praetorian.NewTTLVText(tagUniqueIdentifier, id)); reason != reasonIllegalOperation {
+		t.Errorf("Destroy of an active key reason = %#x, want %#x", reason, reasonIllegalOperation)
+	}
+
+	if _, reason := c.call(opRevoke,
+		praetorian.NewTTLVText(tagUniqueIdentifier, id),
+		praetorian.NewTTLVStructure(tagRevocationReason, praetorian.NewTTLVEnumeration(tagRevocationReasonCode, 6)),
+	); reason != 0 {
+		t.Fatalf("Revoke reason = %#x", reason)
+	}
+	if got := c.state(id); got != stateDeactivated {
+		t.Errorf("state after Revoke = %d, want %d", got, stateDeactivated)
+	}
+	if _, reason := encrypt(); reason != reasonIllegalOperation {
+		t.Errorf("Encrypt with a revoked key reason = %#x, want %#x", reason, reasonIllegalOperation)
+	}
+
+	// revoked keys can still decrypt existing data.
+	res, reason = c.call(opDecrypt, praetorian.NewTTLVText(tagUniqueIdentifier, id), praetorian.NewTTLVBytes(tagData, ciphertext))
+	if reason != 0 {
+		t.Fatalf("Decrypt reason = %#x", reason)
+	}
+	data, _ = res.Find(tagData)
+	if !bytes.Equal(data.Bytes(), plaintext) {
+		t.Errorf("Decrypt = %q, want %q", data.Bytes(), plaintext)
+	}
+
+	if _, reason := c.call(opDestroy, praetorian.NewTTLVText(tagUniqueIdentifier, id)); reason != 0 {
+		t.Fatalf("Destroy reason = %#x", reason)
+	}
+	if _, reason := c.call(opGetAttributes, praetorian.NewTTLVText(tagUniqueIdentifier, id)); reason != reasonItemNotFound {
+		t.Errorf("GetAttributes after Destroy reason = %#x, want %#x", reason, reasonItemNotFound)
+	}
+}
+
+func TestKMIPServer_Errors(t *testing.T) {
+	c := newKMIPClient(t, newTestKeystore(t, testConfig))
+
+	tests := []struct {
+		name       string
+		op         uint32
+		payload    []praetorian.TTLV
+		wantReason uint32
+	}{
+		{
+			name:       "unknown key",
+			op:         opGetAttributes,
+			payload:    []praetorian.TTLV{praetorian.NewTTLVText(tagUniqueIdentifier, "missing")},
+			wantReason: reasonItemNotFound,
+		},
+		{
+			name:       "tampered data",
+			op:         opDecrypt,
+			payload:    []praetorian.TTLV{praetorian.NewTTLVBytes(tagData, make([]byte, 40))},
+			wantReason: reasonCryptoFailure,
+		},
+		{
+			name: "destroy active key",
+			op:   opDestroy,
+			payload: []praetorian.TTLV{
+				praetorian.NewTTLVText(tagUniqueIdentifier, "1"),
+			},
+			wantReason: reasonIllegalOperation,
+		},
+		{
+			name: "revoke active key",
+			op:   opRevoke,
+			payload: []praetorian.TTLV{
+				praetorian.NewTTLVText(tagUniqueIdentifier, "1"),
+				praetorian.NewTTLVStructure(tagRevocationReason, praetorian.NewTTLVEnumeration(tagRevocationReasonCode, 1)),
+			},
+			wantReason: reasonIllegalOperation,
+		},
+		{
+			name:       "create without a state file",
+			op:         opCreate,
+			payload:    createPayload,
+			wantReason: 0x05,
+		},
+		{
+			name:       "unsupported operation",
+			op:         0x0A,
+			wantReason: 0x05,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, reason := c.call(tt.op, tt.payload...); reason != tt.wantReason {
+				t.Errorf("reason = %#x, wantReason = %#x", reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestKMIPServer_DefaultKey(t *testing.T) {
+	c := newKMIPClient(t, newTestKeystore(t, testConfig))
+
+	res, reason := c.call(opEncrypt, praetorian.NewTTLVBytes(tagData, []byte("data")))
+	if reason != 0 {
+		t.Fatalf("Encrypt reason = %#x", reason)
+	}
+	if uid, _ := res.Find(tagUniqueIdentifier); uid.Text() != "1" {
+		t.Errorf("Encrypt unique identifier = %q, want %q", uid.Text(), "1")
+	}
+}
+
+func TestKMIPServer_InvalidMessage(t *testing.T) {
+	c := newKMIPClient(t, &MockKeystore{})
+
+	res := c.sendRaw([]byte{0x42, 0x00, 0x78, 0x01, 0, 0, 0, 8, 0x42, 0, 0, 0x99, 0, 0, 0, 0})
+	item, _ := res.Find(tagBatchItem)
+	if reason, _ := item.Find(tagResultReason); reason.Uint32() != reasonInvalidMessage {
+		t.Errorf("reason = %#x, want %#x", reason.Uint32(), reasonInvalidMessage)
+	}
+}
+
+func TestKMIPServer_Limits(t *testing.T) {
+	t.Run("rate limit", func(t *testing.T) {
+		c := newKMIPClient(t, newTestKeystore(t, testConfig), praetorian.WithKMIPRateLimits(praetorian.RateLimits{
+			Operations: map[string]praetorian.RateLimit{"/kmip/encrypt": {Rate: 0.001, Burst: 1}},
+		}))
+		encrypt := func() uint32 {
+			_, reason := c.call(opEncrypt, praetorian.NewTTLVBytes(tagData, []byte("data")))
+			return reason
+		}
+		if reason := encrypt(); reason != 0 {
+			t.Fatalf("Encrypt reason = %#x", reason)
+		}
+		if reason := encrypt(); reason != reasonGeneralFailure {
+			t.Errorf("Encrypt over the rate limit reason = %#x, want %#x", reason, reasonGeneralFailure)
+		}
+	})
+
+	t.Run("decrypts are observed", func(t *testing.T) {
+		log.SetOutput(io.Discard)
+		defer log.SetOutput(os.Stderr)
+
+		d := praetorian.NewAnomalyDetector(praetorian.AnomalyDetection{MaxCallerUnwraps: 1, Lockout: true}, nil)
+		c := newKMIPClient(t, newTestKeystore(t, testConfig), praetorian.WithKMIPAnomalyDetector(d))
+		res, reason := c.call(opEncrypt, praetorian.NewTTLVBytes(tagData, []byte("data")))
+		if reason != 0 {
+			t.Fatalf("Encrypt reason = %#x", reason)
+		}
+		data, _ := res.Find(tagData)
+		decrypt := func() uint32 {
+			_, reason := c.call(opDecrypt, praetorian.NewTTLVBytes(tagData, data.Bytes()))
+			return reason
+		}
+		if reason := decrypt(); reason != 0 {
+			t.Fatalf("Decrypt reason = %#x", reason)
+		}
+		if reason := decrypt(); reason != reasonPermissionDenied {
+			t.Errorf("Decrypt over the unwrap threshold reason = %#x, want %#x", reason, reasonPermissionDenied)
+		}
+		if stats := d.Stats(); stats.Unwraps != 2 || len(stats.Lockouts) != 1 {
+			t.Errorf("AnomalyDetector.Stats() = %+v, want 2 unwraps and 1 lockout", stats)
+		}
+	})
+
+	t.Run("lockout", func(t *testing.T) {
+		log.SetOutput(io.Discard)
+		defer log.SetOutput(os.Stderr)
+
+		d := praetorian.NewAnomalyDetector(praetorian.AnomalyDetection{MaxCallerUnwraps: 1, Lockout: true}, nil)
+		c := newKMIPClient(t, newTestKeystore(t, testConfig), praetorian.WithKMIPAnomalyDetector(d))
+		d.Observe("127.0.0.1", "1")
+		d.Observe("127.0.0.1", "1")
+		res, reason := c.call(opEncrypt, praetorian.NewTTLVBytes(tagData, []byte("data")))
+		if reason != 0 {
+			t.Fatalf("Encrypt when locked out reason = %#x", reason)
+		}
+		data, _ := res.Find(tagData)
+		if _, reason := c.call(opDecrypt, praetorian.NewTTLVBytes(tagData, data.Bytes())); reason != reasonPermissionDenied {
+			t.Errorf("Decrypt when locked out reason = %#x, want %#x", reason, reasonPermissionDenied)
+		}
+	})
+}
+
+func TestKMIPTLSConfigFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := newTestCertificate(t, nil, nil, "kmip ca")
+	cert, _ := newTestCertificate(t, ca, caKey, "localhost")
+	writePEM := func(name, typ string, b []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: b}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := writePEM("cert.pem", "CERTIFICATE", cert.Certificate[0])
+	keyFile := writePEM("key.pem", "PRIVATE KEY", key)
+	caFile := writePEM("ca.pem", "CERTIFICATE", ca.Certificate[0])
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr error
+	}{
+		{
+			name: "client CA",
+			env:  map[string]string{praetorian.EnvKMIPClientCA: caFile},
+		},
+		{
+			name:    "no client CA",
+			env:     map[string]string{praetorian.EnvKMIPClientCA: ""},
+			wantErr: praetorian.ErrKMIPClientCARequired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(praetorian.EnvKMIPAddr, "127.0.0.1:0")
+			t.Setenv(praetorian.EnvKMIPCert, certFile)
+			t.Setenv(praetorian.EnvKMIPKey, keyFile)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			_, cfg, err := praetorian.KMIPTLSConfigFromEnv()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("KMIPTLSConfigFromEnv() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+			if err == nil && cfg.ClientAuth != tls.RequireAndVerifyClientCert {
+				t.Errorf("KMIPTLSConfigFromEnv() ClientAuth = %v, want %v", cfg.ClientAuth, tls.RequireAndVerifyClientCert)
+			}
+		})
+	}
+}
+
+func TestKMIPServer_Shutdown(t *testing.T) {
+	c := newKMIPClient(t, newTestKeystore(t, testConfig))
+	if _, reason := c.call(opEncrypt, praetorian.NewTTLVBytes(tagData, []byte("data"))); reason != 0 {
+		t.Fatalf("Encrypt reason = %#x", reason)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.srv.Shutdown(ctx); err != nil {
+		t.Fatalf("KMIPServer.Shutdown() error = %v", err)
+	}
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := c.conn.Read(make([]byte, 1)); err == nil {
+		t.Error("KMIPServer.Shutdown() left the idle connection open")
+	}
+}
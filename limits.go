@@ -0,0 +1,159 @@
+package praetorian
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultMaxHeaderBytes limits the size of request headers.
+const DefaultMaxHeaderBytes = 64 << 10
+
+// ServerTimeouts bound how long a connection may take to send a request and
+// receive the response. Request is the deadline of the context passed to the
+// handlers and keystore. Zero values use DefaultTimeouts and negative values
+// disable the timeout.
+type ServerTimeouts struct {
+	ReadHeader time.Duration
+	Read       time.Duration
+	Write      time.Duration
+	Idle       time.Duration
+	Request    time.Duration
+}
+
+// DefaultTimeouts are short, as every request is a single small key operation.
+var DefaultTimeouts = ServerTimeouts{
+	ReadHeader: 5 * time.Second,
+	Read:       10 * time.Second,
+	Write:      15 * time.Second,
+	Idle:       60 * time.Second,
+	Request:    10 * time.Second,
+}
+
+// ContextKeyFinder is implemented by keystores which can stop a lookup once
+// the request deadline has passed.
+type ContextKeyFinder interface {
+	FindContext(ctx context.Context, id string) (RootKey, error)
+}
+
+// WithTimeouts replaces the default timeouts.
+func WithTimeouts(t ServerTimeouts) ServerOption {
+	return func(s *Server) {
+		s.timeouts = t
+	}
+}
+
+// WithMaxHeaderBytes limits the size of request headers.
+func WithMaxHeaderBytes(n int) ServerOption {
+	return func(s *Server) {
+		s.maxHeaderBytes = n
+	}
+}
+
+// WithMaxConnections limits the number of open connections on each listener.
+// Further connections wait to be accepted until one is closed.
+func WithMaxConnections(n int) ServerOption {
+	return func(s *Server) {
+		s.maxConns = n
+	}
+}
+
+// resolve returns the timeouts with defaults applied.
+func (t ServerTimeouts) resolve() ServerTimeouts {
+	pick := func(v, def time.Duration) time.Duration {
+		switch {
+		case v < 0:
+			return 0
+		case v == 0:
+			return def
+		}
+		return v
+	}
+	return ServerTimeouts{
+		ReadHeader: pick(t.ReadHeader, DefaultTimeouts.ReadHeader),
+		Read:       pick(t.Read, DefaultTimeouts.Read),
+		Write:      pick(t.Write, DefaultTimeouts.Write),
+		Idle:       pick(t.Idle, DefaultTimeouts.Idle),
+		Request:    pick(t.Request, DefaultTimeouts.Request),
+	}
+}
+
+// NewRequestTimeout sets a deadline on the context of each request.
+func NewRequestTimeout(d time.Duration, next http.Handler) http.Handler {
+	if d <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// unavailable reports whether the keystore could not be used in time for the
+// request, as opposed to the key not existing.
+func unavailable(err error) bool {
+	return errors.Is(err, ErrKeystoreSealed) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, context.Canceled)
+}
+
+// findKey looks up a root key unless the request has been cancelled or its
+// deadline has passed.
+func findKey(ctx context.Context, keys KeyFinder, id string) (RootKey, error) {
+	if cf, ok := keys.(ContextKeyFinder); ok {
+		return cf.FindContext(ctx, id)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return keys.Find(id)
+}
+
+// LimitListener returns a listener which accepts at most n connections at
+// once.
+func LimitListener(ln net.Listener, n int) net.Listener {
+	return &limitListener{Listener: ln, sem: make(chan struct{}, n), done: make(chan struct{})}
+}
+
+type limitListener struct {
+	net.Listener
+	sem       chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (l *limitListener) Accept() (net.Conn, error) {
+	select {
+	case l.sem <- struct{}{}:
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+	c, err := l.Listener.Accept()
+	if err != nil {
+		<-l.sem
+		return nil, err
+	}
+	return &limitConn{Conn: c, release: func() { <-l.sem }}, nil
+}
+
+func (l *limitListener) Close() error {
+	err := l.Listener.Close()
+	l.closeOnce.Do(func() { close(l.done) })
+	return err
+}
+
+type limitConn struct {
+	net.Conn
+	releaseOnce sync.Once
+	release     func()
+}
+
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.releaseOnce.Do(c.release)
+	return err
+}
@@ -0,0 +1,126 @@
+package praetorian_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/karlbateman/praetorian"
+)
+
+func TestNewServerTimeouts(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeouts praetorian.ServerTimeouts
+		want     praetorian.ServerTimeouts
+	}{
+		{
+			name:     "defaults",
+			timeouts: praetorian.ServerTimeouts{},
+			want:     praetorian.DefaultTimeouts,
+		},
+		{
+			name:     "configured",
+			timeouts: praetorian.ServerTimeouts{ReadHeader: time.Second, Read: 2 * time.Second, Write: 3 * time.Second, Idle: 4 * time.Second},
+			want:     praetorian.ServerTimeouts{ReadHeader: time.Second, Read: 2 * time.Second, Write: 3 * time.Second, Idle: 4 * time.Second},
+		},
+		{
+			name:     "disabled",
+			timeouts: praetorian.ServerTimeouts{ReadHeader: -1, Read: -1, Write: -1, Idle: -1},
+			want:     praetorian.ServerTimeouts{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := praetorian.NewServer(&MockKeystore{}, praetorian.WithTimeouts(tt.timeouts))
+			got := praetorian.ServerTimeouts{
+				ReadHeader: srv.ReadHeaderTimeout,
+				Read:       srv.ReadTimeout,
+				Write:      srv.WriteTimeout,
+				Idle:       srv.IdleTimeout,
+			}
+			tt.want.Request = 0
+			if got != tt.want {
+				t.Errorf("NewServer() timeouts = %+v, want = %+v", got, tt.want)
+			}
+			if srv.MaxHeaderBytes != praetorian.DefaultMaxHeaderBytes {
+				t.Errorf("NewServer() MaxHeaderBytes = %d, want = %d", srv.MaxHeaderBytes, praetorian.DefaultMaxHeaderBytes)
+			}
+		})
+	}
+}
+
+func TestNewRequestTimeout(t *testing.T) {
+	var deadline time.Time
+	h := praetorian.NewRequestTimeout(time.Second, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, _ = r.Context().Deadline()
+	}))
+
+	start := time.Now()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	if deadline.Before(start) || deadline.After(time.Now().Add(time.Second)) {
+		t.Errorf("NewRequestTimeout() deadline = %v, want within 1s of %v", deadline, start)
+	}
+}
+
+func TestRequestDeadlineExceeded(t *testing.T) {
+	ks := newTestKeystore(t, testConfig)
+	body := `{"value": "keep it secret, keep it safe"}`
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req := httptest.NewRequestWithContext(ctx, http.MethodPost, "/wrap", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	praetorian.HandleWrap(praetorian.ActiveKeyID, ks).ServeHTTP(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("HandleWrap() status = %d, wantStatus = %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestLimitListener(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln := praetorian.LimitListener(inner, 1)
+	defer ln.Close()
+
+	for range 2 {
+		c, err := net.Dial("tcp", inner.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+	}
+
+	first, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("Accept() error = %v", err)
+	}
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if c, err := ln.Accept(); err == nil {
+			accepted <- c
+		}
+	}()
+	select {
+	case c := <-accepted:
+		c.Close()
+		t.Fatal("Accept() returned a connection above the limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	first.Close()
+	select {
+	case c := <-accepted:
+		c.Close()
+	case <-time.After(time.Second):
+		t.Fatal("Accept() blocked after a connection was closed")
+	}
+}
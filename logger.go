@@ -1,6 +1,7 @@
 package praetorian
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 	"time"
@@ -21,6 +22,10 @@ func NewLogger(next http.Handler) http.Handler {
 		start := time.Now()
 		lr := &LoggerResponse{ResponseWriter: w, statusCode: http.StatusOK}
 		next.ServeHTTP(lr, r)
-		log.Printf("%s %s %d %s from %s\n", r.Method, r.URL.Path, lr.statusCode, time.Since(start), r.RemoteAddr)
+		from := r.RemoteAddr
+		if p, ok := PeerCredentialsFromContext(r.Context()); ok {
+			from = fmt.Sprintf("uid %d", p.UID)
+		}
+		log.Printf("%s %s %d %s from %s\n", r.Method, r.URL.Path, lr.statusCode, time.Since(start), from)
 	})
 }
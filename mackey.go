@@ -0,0 +1,44 @@
+package praetorian
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+// MACKey computes deterministic keyed hashes, such as blind indexes, without
+// exposing key material.
+type MACKey interface {
+	ID() string
+	MAC(data []byte) []byte
+	Verify(data, mac []byte) error
+}
+
+// MACFinder retrieves MAC keys from an underlying keystore.
+type MACFinder interface {
+	FindMAC(id string) (MACKey, error)
+}
+
+type macKey struct {
+	id    string
+	value []byte
+}
+
+// ID is a getter which returns the keys unique identifier.
+func (k *macKey) ID() string {
+	return k.id
+}
+
+// MAC returns the HMAC-SHA256 of the given data.
+func (k *macKey) MAC(d []byte) []byte {
+	h := hmac.New(sha256.New, k.value)
+	h.Write(d)
+	return h.Sum(nil)
+}
+
+// Verify compares the given MAC against the data in constant time.
+func (k *macKey) Verify(d, mac []byte) error {
+	if !hmac.Equal(k.MAC(d), mac) {
+		return ErrInvalidMAC
+	}
+	return nil
+}
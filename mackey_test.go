@@ -0,0 +1,52 @@
+package praetorian_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+const (
+	testMACConfig = `{"activeKeyId": "1", "rootKeys": {"1": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}, "activeMacKeyId": "7", "macKeys": {"6": "BPK//lj6hlpjuA5gPZo19OIjUDgnIQAAAAAAAAAAAAA=", "7": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}}`
+)
+
+func TestMACKey_MAC(t *testing.T) {
+	macs := newTestKeystore(t, testMACConfig).(praetorian.MACFinder)
+
+	active, err := macs.FindMAC(praetorian.ActiveKeyID)
+	if err != nil {
+		t.Fatalf("Keystore.FindMAC() failed to return key: %v", err)
+	}
+	if active.ID() != "7" {
+		t.Errorf("Keystore.FindMAC() id = %q, want = %q", active.ID(), "7")
+	}
+
+	data := []byte("alice@example.com")
+	first := active.MAC(data)
+	want := "0001452a4c3da3ca3fc22399f0a42e4522f9d1d6d088e57f82c707558b54adf2"
+	if got := hex.EncodeToString(first); got != want {
+		t.Errorf("MACKey.MAC() got = %s, want = %s", got, want)
+	}
+
+	previous, err := macs.FindMAC("6")
+	if err != nil {
+		t.Fatalf("Keystore.FindMAC() failed to return key: %v", err)
+	}
+	if bytes.Equal(first, previous.MAC(data)) {
+		t.Errorf("MACKey.MAC() matches across keys")
+	}
+
+	if err := active.Verify(data, first); err != nil {
+		t.Errorf("MACKey.Verify() failed to verify MAC: %v", err)
+	}
+	if err := previous.Verify(data, first); !errors.Is(err, praetorian.ErrInvalidMAC) {
+		t.Errorf("MACKey.Verify() error = %v, wantErr = %v", err, praetorian.ErrInvalidMAC)
+	}
+
+	if _, err := macs.FindMAC("1"); !errors.Is(err, praetorian.ErrMACKeyNotFound) {
+		t.Errorf("Keystore.FindMAC() error = %v, wantErr = %v", err, praetorian.ErrMACKeyNotFound)
+	}
+}
@@ -0,0 +1,324 @@
+package praetorian
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	EnvAddr         = "PRAETORIAN_ADDR"
+	EnvEndpoints    = "PRAETORIAN_ENDPOINTS"
+	EnvLegacySunset = "PRAETORIAN_LEGACY_SUNSET"
+)
+
+const (
+	// DefaultAddr is used when neither an address nor PORT is configured.
+	DefaultAddr = ":3000"
+	// DefaultMaxBodyBytes limits the size of request bodies.
+	DefaultMaxBodyBytes = 1 << 20 // 1MB limit
+	// DefaultShutdownTimeout is how long in-flight requests have to complete
+	// once the server is stopped.
+	DefaultShutdownTimeout = 5 * time.Second
+)
+
+// Endpoint names a group of routes which can be enabled on the server.
+type Endpoint string
+
+const (
+	EndpointWrap      Endpoint = "wrap"      // /wrap
+	EndpointUnwrap    Endpoint = "unwrap"    // /unwrap
+	EndpointPublicKey Endpoint = "publickey" // /publickey/{id}
+	EndpointRPC       Endpoint = "rpc"       // gRPC and Connect
+	EndpointTransit   Endpoint = "transit"   // /v1/transit/
+	EndpointSign      Endpoint = "sign"      // /sign, /verify and /keys/{id}/public
+	EndpointMAC       Endpoint = "mac"       // /mac and /mac/verify
+	EndpointUnseal    Endpoint = "unseal"    // /unseal
+	EndpointAdmin     Endpoint = "admin"     // /admin/
+)
+
+// Endpoints lists every endpoint. All of them but EndpointAdmin are enabled by
+// default, and the admin endpoints also need AdminAuth to be configured.
+var Endpoints = []Endpoint{
+	EndpointWrap,
+	EndpointUnwrap,
+	EndpointPublicKey,
+	EndpointRPC,
+	EndpointTransit,
+	EndpointSign,
+	EndpointMAC,
+	EndpointUnseal,
+	EndpointAdmin,
+}
+
+// ParseEndpoints parses a comma separated list of endpoint names.
+func ParseEndpoints(s string) ([]Endpoint, error) {
+	var out []Endpoint
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		e := Endpoint(name)
+		if !e.valid() {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownEndpoint, name)
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+func (e Endpoint) valid() bool {
+	for _, v := range Endpoints {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware wraps the handler of every request.
+type Middleware func(http.Handler) http.Handler
+
+// ServerOptions holds the settings of the HTTP server, so they can be
+// populated from the environment and flags before the server is created.
+// Zero values keep the defaults.
+type ServerOptions struct {
+	Addr                string
+	MaxBodyBytes        int64
+	Timeouts            ServerTimeouts
+	MaxHeaderBytes      int
+	MaxConnections      int
+	ShutdownTimeout     time.Duration
+	DrainDelay          time.Duration
+	TLS                 *tls.Config
+	HTTP2               HTTP2Options
+	LegacySunset        time.Time
+	DisableLegacyRoutes bool
+	Middleware          []Middleware
+	Endpoints           []Endpoint
+	Admin               AdminAuth
+	Socket              *SocketConfig
+	KMIPAddr            string
+	KMIPTLS             *tls.Config
+	KMSAddr             string
+	KMSAliases          map[string]string
+}
+
+// ServerOptionsFromEnv reads the listen address from PRAETORIAN_ADDR, or the
+// port from PORT, the enabled endpoints from PRAETORIAN_ENDPOINTS, the sunset
+// of the legacy routes from PRAETORIAN_LEGACY_SUNSET, the admin callers, the
+// TLS and HTTP/2 settings and the socket, KMIP and AWS KMS listeners.
+func ServerOptionsFromEnv() (ServerOptions, error) {
+	var o ServerOptions
+	if addr := os.Getenv(EnvAddr); addr != "" {
+		o.Addr = addr
+	} else if port := os.Getenv("PORT"); port != "" {
+		o.Addr = ":" + port
+	}
+	endpoints, err := ParseEndpoints(os.Getenv(EnvEndpoints))
+	if err != nil {
+		return o, err
+	}
+	o.Endpoints = endpoints
+	if val := os.Getenv(EnvLegacySunset); val != "" {
+		if o.LegacySunset, err = time.Parse(time.RFC3339, val); err != nil {
+			return o, fmt.Errorf("%s: %w", EnvLegacySunset, err)
+		}
+	}
+	if o.Admin, err = AdminAuthFromEnv(); err != nil {
+		return o, err
+	}
+	if o.TLS, err = TLSConfigFromEnv(); err != nil {
+		return o, err
+	}
+	if o.HTTP2, err = HTTP2OptionsFromEnv(); err != nil {
+		return o, err
+	}
+	if o.Socket, err = SocketConfigFromEnv(); err != nil {
+		return o, err
+	}
+	if o.KMIPAddr, o.KMIPTLS, err = KMIPTLSConfigFromEnv(); err != nil {
+		return o, err
+	}
+	if o.KMSAddr = os.Getenv(EnvKMSAddr); o.KMSAddr != "" {
+		if o.KMSAliases, err = KMSAliasesFromEnv(); err != nil {
+			return o, err
+		}
+	}
+	return o, nil
+}
+
+// WithOptions applies every setting of o which is not a zero value.
+func WithOptions(o ServerOptions) ServerOption {
+	return func(s *Server) {
+		if o.Addr != "" {
+			s.addr = o.Addr
+		}
+		if o.MaxBodyBytes > 0 {
+			s.maxBodyBytes = o.MaxBodyBytes
+		}
+		if o.Timeouts != (ServerTimeouts{}) {
+			s.timeouts = o.Timeouts
+		}
+		if o.MaxHeaderBytes > 0 {
+			s.maxHeaderBytes = o.MaxHeaderBytes
+		}
+		if o.MaxConnections > 0 {
+			s.maxConns = o.MaxConnections
+		}
+		if o.ShutdownTimeout > 0 {
+			s.shutdownTimeout = o.ShutdownTimeout
+		}
+		if o.DrainDelay > 0 {
+			s.drainDelay = o.DrainDelay
+		}
+		if o.TLS != nil {
+			s.tlsConfig = o.TLS
+		}
+		if o.HTTP2 != (HTTP2Options{}) {
+			s.http2 = o.HTTP2
+		}
+		if !o.LegacySunset.IsZero() {
+			s.legacySunset = o.LegacySunset
+		}
+		if o.DisableLegacyRoutes {
+			s.disableLegacyRoutes = true
+		}
+		s.middleware = append(s.middleware, o.Middleware...)
+		if len(o.Endpoints) > 0 {
+			s.endpoints = o.Endpoints
+		}
+		if o.Admin.Enabled() {
+			s.adminAuth = o.Admin
+		}
+		if o.Socket != nil {
+			s.socket = o.Socket
+		}
+		if o.KMIPAddr != "" {
+			s.kmipAddr, s.kmipTLS = o.KMIPAddr, o.KMIPTLS
+		}
+		if o.KMSAddr != "" {
+			s.kmsAddr, s.kmsAliases = o.KMSAddr, o.KMSAliases
+		}
+	}
+}
+
+// WithAddr sets the TCP address the server listens on.
+func WithAddr(addr string) ServerOption {
+	return func(s *Server) {
+		s.addr = addr
+	}
+}
+
+// WithSocket listens on a Unix domain socket in place of the TCP address.
+func WithSocket(cfg *SocketConfig) ServerOption {
+	return func(s *Server) {
+		s.socket = cfg
+	}
+}
+
+// WithKMIP also serves KMIP on addr. The TLS config must require and verify
+// client certificates.
+func WithKMIP(addr string, cfg *tls.Config) ServerOption {
+	return func(s *Server) {
+		s.kmipAddr, s.kmipTLS = addr, cfg
+	}
+}
+
+// WithKMS also serves the AWS KMS protocol on addr, resolving the aliases to
+// root key IDs.
+func WithKMS(addr string, aliases map[string]string) ServerOption {
+	return func(s *Server) {
+		s.kmsAddr, s.kmsAliases = addr, aliases
+	}
+}
+
+// WithMaxBodyBytes limits the size of request bodies.
+func WithMaxBodyBytes(n int64) ServerOption {
+	return func(s *Server) {
+		s.maxBodyBytes = n
+	}
+}
+
+// WithShutdownTimeout sets how long in-flight requests have to complete once
+// the server is stopped.
+func WithShutdownTimeout(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.shutdownTimeout = d
+	}
+}
+
+// WithDrainDelay sets how long the server keeps serving requests after it
+// stops being ready and before it shuts down, so load balancers can remove it.
+func WithDrainDelay(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.drainDelay = d
+	}
+}
+
+// WithLegacySunset announces when the unversioned aliases of the /v1
+// endpoints will be removed, in the Sunset header of their responses.
+func WithLegacySunset(t time.Time) ServerOption {
+	return func(s *Server) {
+		s.legacySunset = t
+	}
+}
+
+// WithoutLegacyRoutes serves the endpoints only below /v1.
+func WithoutLegacyRoutes() ServerOption {
+	return func(s *Server) {
+		s.disableLegacyRoutes = true
+	}
+}
+
+// WithMiddleware wraps every request in the given middleware, the first of
+// which is outermost.
+func WithMiddleware(mw ...Middleware) ServerOption {
+	return func(s *Server) {
+		s.middleware = append(s.middleware, mw...)
+	}
+}
+
+// WithEndpoints enables only the given endpoints.
+func WithEndpoints(e ...Endpoint) ServerOption {
+	return func(s *Server) {
+		s.endpoints = e
+	}
+}
+
+// enabled reports whether the routes of the endpoint should be registered.
+// The admin endpoints must always be named.
+func (s *Server) enabled(e Endpoint) bool {
+	if len(s.endpoints) == 0 {
+		return e != EndpointAdmin
+	}
+	for _, v := range s.endpoints {
+		if v == e {
+			return true
+		}
+	}
+	return false
+}
+
+type maxBodyBytesKey struct{}
+
+// NewBodyLimit limits the size of request bodies to n bytes.
+func NewBodyLimit(n int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, n)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), maxBodyBytesKey{}, n)))
+	})
+}
+
+// maxBodyBytes returns the body limit of the request.
+func maxBodyBytes(r *http.Request) int64 {
+	if n, ok := r.Context().Value(maxBodyBytesKey{}).(int64); ok {
+		return n
+	}
+	return DefaultMaxBodyBytes
+}
@@ -0,0 +1,111 @@
+package praetorian_test
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+func TestParseEndpoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []praetorian.Endpoint
+		wantErr error
+	}{
+		{
+			name:  "empty",
+			value: "",
+		},
+		{
+			name:  "list",
+			value: "wrap, unwrap,",
+			want:  []praetorian.Endpoint{praetorian.EndpointWrap, praetorian.EndpointUnwrap},
+		},
+		{
+			name:    "unknown endpoint",
+			value:   "wrap,decrypt",
+			wantErr: praetorian.ErrUnknownEndpoint,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := praetorian.ParseEndpoints(tt.value)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseEndpoints() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseEndpoints() = %v, want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerOptions(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	var seen []string
+	record := func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = append(seen, r.URL.Path)
+			h.ServeHTTP(w, r)
+		})
+	}
+
+	srv := praetorian.NewServer(&MockKeystore{}, praetorian.WithOptions(praetorian.ServerOptions{
+		MaxBodyBytes: 64,
+		Middleware:   []praetorian.Middleware{record},
+		Endpoints:    []praetorian.Endpoint{praetorian.EndpointWrap},
+	}))
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "enabled endpoint",
+			path:       "/wrap",
+			body:       `{"value": "keep it secret, keep it safe"}`,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "body over the limit",
+			path:       "/wrap",
+			body:       `{"value": "` + strings.Repeat("a", 64) + `"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "disabled endpoint",
+			path:       "/unwrap",
+			body:       `{"id": "1", "ciphertext": "AA=="}`,
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("ServeHTTP() status = %d, wantStatus = %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+
+	if len(seen) != len(tests) {
+		t.Errorf("middleware saw %d requests, want %d", len(seen), len(tests))
+	}
+}
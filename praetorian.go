@@ -9,15 +9,45 @@ const (
 )
 
 var (
-	ErrActiveRootKeyNotFound = errors.New("active key does not exist in root keys")
-	ErrEnvConfigEmpty        = errors.New("env config not set or empty")
-	ErrEnvConfigInvalid      = errors.New("unable to parse config data")
-	ErrInvalidRootKey        = errors.New("unable to decode root key")
-	ErrInvalidRootKeyLength  = errors.New("root key length must be 32 bytes")
-	ErrRootKeyNotFound       = errors.New("root key not found")
-	ErrNewCipherBlock        = errors.New("unable to create AES-256 cipher block")
-	ErrNewGCMWithRandomNonce = errors.New("unable to create cipher with Galois-Counter-Mode")
-	ErrGCMOpen               = errors.New("unable to read encrypted data")
+	ErrActiveRootKeyNotFound    = errors.New("active key does not exist in root keys")
+	ErrEnvConfigEmpty           = errors.New("env config not set or empty")
+	ErrEnvConfigInvalid         = errors.New("unable to parse config data")
+	ErrInvalidRootKey           = errors.New("unable to decode root key")
+	ErrInvalidRootKeyLength     = errors.New("root key length must be 32 bytes")
+	ErrRootKeyNotFound          = errors.New("root key not found")
+	ErrNewCipherBlock           = errors.New("unable to create AES-256 cipher block")
+	ErrNewGCMWithRandomNonce    = errors.New("unable to create cipher with Galois-Counter-Mode")
+	ErrGCMOpen                  = errors.New("unable to read encrypted data")
+	ErrKeystoreSealed           = errors.New("keystore is sealed")
+	ErrInvalidShare             = errors.New("unable to decode unseal share")
+	ErrInvalidShareCount        = errors.New("share count must be between the threshold and 255")
+	ErrInvalidThreshold         = errors.New("threshold must be at least 2")
+	ErrUnsealFailed             = errors.New("unable to unseal root keys")
+	ErrRootKeyExists            = errors.New("root key already exists")
+	ErrKeyNotExportable         = errors.New("root key cannot be exported")
+	ErrUnsupportedTransferKey   = errors.New("unsupported transfer key")
+	ErrInvalidKeyExport         = errors.New("unable to decrypt key export")
+	ErrUnsupportedCurve         = errors.New("unsupported ECDH curve")
+	ErrNoPublicKey              = errors.New("root key has no public key")
+	ErrActiveSigningKeyNotFound = errors.New("active signing key does not exist in signing keys")
+	ErrSigningKeyNotFound       = errors.New("signing key not found")
+	ErrUnsupportedAlgorithm     = errors.New("unsupported signing algorithm")
+	ErrInvalidSignature         = errors.New("signature verification failed")
+	ErrActiveMACKeyNotFound     = errors.New("active MAC key does not exist in MAC keys")
+	ErrMACKeyNotFound           = errors.New("MAC key not found")
+	ErrInvalidMAC               = errors.New("MAC verification failed")
+	ErrActiveRootKey            = errors.New("the active root key cannot be destroyed")
+	ErrInvalidKeyBlob           = errors.New("invalid ciphertext")
+	ErrInvalidKeyState          = errors.New("unable to read key state file")
+	ErrRootKeyInactive          = errors.New("root key is not in a usable state")
+	ErrKMIPClientCARequired     = errors.New("kmip requires a client CA to authenticate clients")
+	ErrTransitKeyVersion        = errors.New("transit requires root key ids which are positive integers")
+	ErrInvalidRateLimit         = errors.New("rate limits must be positive")
+	ErrInvalidAnomalyDetection  = errors.New("anomaly thresholds must be positive and the factor above 1")
+	ErrCallerLockedOut          = errors.New("caller is locked out")
+	ErrUnknownEndpoint          = errors.New("unknown endpoint")
+	ErrServe                    = errors.New("unable to listen for requests")
+	ErrShutdown                 = errors.New("graceful shutdown failed")
 )
 
 type RootKey interface {
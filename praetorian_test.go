@@ -1,6 +1,7 @@
 package praetorian_test
 
 import (
+	"crypto"
 	"errors"
 	"io"
 	"strings"
@@ -40,9 +41,32 @@ func (m *MockKeystore) Find(id string) (praetorian.RootKey, error) {
 	if id == "missing" {
 		return nil, errors.New("root key not found")
 	}
+	if id == "sealed" {
+		return nil, praetorian.ErrKeystoreSealed
+	}
 	return &MockKey{}, nil
 }
 
+func (m *MockKeystore) FindSigner(id string) (praetorian.SigningKey, error) {
+	if id == "missing" {
+		return nil, praetorian.ErrSigningKeyNotFound
+	}
+	if id == "sealed" {
+		return nil, praetorian.ErrKeystoreSealed
+	}
+	return &MockSigner{}, nil
+}
+
+func (m *MockKeystore) FindMAC(id string) (praetorian.MACKey, error) {
+	if id == "missing" {
+		return nil, praetorian.ErrMACKeyNotFound
+	}
+	if id == "sealed" {
+		return nil, praetorian.ErrKeystoreSealed
+	}
+	return &MockMACKey{}, nil
+}
+
 type MockKey struct{}
 
 func (k *MockKey) ID() string {
@@ -65,3 +89,66 @@ func (k *MockKey) Decrypt(data []byte) ([]byte, error) {
 	}
 	return []byte(`{"value": "decrypted message"}`), nil
 }
+
+type MockSigner struct{}
+
+func (k *MockSigner) ID() string {
+	return "4"
+}
+
+func (k *MockSigner) Algorithm() string {
+	return praetorian.SigningEdDSA
+}
+
+func (k *MockSigner) Public() crypto.PublicKey {
+	return nil
+}
+
+func (k *MockSigner) Sign(data []byte) ([]byte, error) {
+	if strings.Contains(string(data), "error") {
+		return nil, errors.New("signing failed")
+	}
+	return []byte(`signature`), nil
+}
+
+func (k *MockSigner) Verify(data, sig []byte) error {
+	if string(sig) != "signature" {
+		return praetorian.ErrInvalidSignature
+	}
+	return nil
+}
+
+type MockMACKey struct{}
+
+func (k *MockMACKey) ID() string {
+	return "6"
+}
+
+func (k *MockMACKey) MAC(data []byte) []byte {
+	return []byte(`mac`)
+}
+
+func (k *MockMACKey) Verify(data, mac []byte) error {
+	if string(mac) != "mac" {
+		return praetorian.ErrInvalidMAC
+	}
+	return nil
+}
+
+type MockUnsealer struct{}
+
+func (m *MockUnsealer) Sealed() bool {
+	return true
+}
+
+func (m *MockUnsealer) Unseal(share []byte) (praetorian.UnsealStatus, error) {
+	switch string(share) {
+	case "invalid":
+		return praetorian.UnsealStatus{}, praetorian.ErrInvalidShare
+	case "failed":
+		return praetorian.UnsealStatus{}, praetorian.ErrUnsealFailed
+	case "error":
+		return praetorian.UnsealStatus{}, errors.New("unseal error")
+	}
+	return praetorian.UnsealStatus{Sealed: true, Threshold: 3, Progress: 1}, nil
+}
@@ -0,0 +1,88 @@
+package praetorian
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// protobuf wire types used by the RPC messages.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errProtoInvalid = errors.New("invalid protobuf message")
+
+func protoAppendBytes(b []byte, field int, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = binary.AppendUvarint(b, uint64(field)<<3|wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func protoAppendString(b []byte, field int, v string) []byte {
+	return protoAppendBytes(b, field, []byte(v))
+}
+
+func protoAppendVarint(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = binary.AppendUvarint(b, uint64(field)<<3|wireVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+// protoRange calls fn for every field in the message. Length delimited fields
+// receive their contents and varint fields their value, unknown fields are
+// skipped.
+func protoRange(b []byte, fn func(field int, wire int, v []byte, n uint64) error) error {
+	for len(b) > 0 {
+		tag, size := binary.Uvarint(b)
+		if size <= 0 || tag>>3 == 0 {
+			return errProtoInvalid
+		}
+		b = b[size:]
+		field, wire := int(tag>>3), int(tag&7)
+
+		var (
+			v []byte
+			n uint64
+		)
+		switch wire {
+		case wireVarint:
+			n, size = binary.Uvarint(b)
+			if size <= 0 {
+				return errProtoInvalid
+			}
+			b = b[size:]
+		case wireBytes:
+			l, size := binary.Uvarint(b)
+			if size <= 0 || l > uint64(len(b)-size) {
+				return errProtoInvalid
+			}
+			v = b[size : size+int(l)]
+			b = b[size+int(l):]
+		case wireFixed64:
+			if len(b) < 8 {
+				return errProtoInvalid
+			}
+			b = b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return errProtoInvalid
+			}
+			b = b[4:]
+		default:
+			return errProtoInvalid
+		}
+
+		if err := fn(field, wire, v, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
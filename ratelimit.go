@@ -0,0 +1,226 @@
+package praetorian
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimitSweep is how often idle callers are removed from the limiter.
+const rateLimitSweep = time.Minute
+
+// RateLimit is a token bucket which refills at Rate requests per second and
+// holds at most Burst requests.
+type RateLimit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst,omitempty"`
+}
+
+// RateLimits configure the limits applied to each caller. Operations are
+// matched against the request path, with keys ending in a slash matching
+// every path below them, and requests which match no operation use Default.
+// An unversioned path and its /v1 path are the same operation.
+// MaxInFlight caps the number of requests served at once across all callers.
+type RateLimits struct {
+	MaxInFlight int                  `json:"maxInFlight,omitempty"`
+	Default     *RateLimit           `json:"default,omitempty"`
+	Operations  map[string]RateLimit `json:"operations,omitempty"`
+}
+
+// Enabled reports whether any limit is configured.
+func (l RateLimits) Enabled() bool {
+	return l.MaxInFlight > 0 || l.Default != nil || len(l.Operations) > 0
+}
+
+func (l RateLimits) validate() error {
+	if l.MaxInFlight < 0 {
+		return ErrInvalidRateLimit
+	}
+	if l.Default != nil && !l.Default.valid() {
+		return ErrInvalidRateLimit
+	}
+	for op, rl := range l.Operations {
+		if !strings.HasPrefix(op, "/") || !rl.valid() {
+			return ErrInvalidRateLimit
+		}
+	}
+	return nil
+}
+
+func (rl RateLimit) valid() bool {
+	return rl.Rate > 0 && !math.IsInf(rl.Rate, 0) && rl.Burst >= 0
+}
+
+// burst defaults to one second of requests.
+func (rl RateLimit) burst() float64 {
+	if rl.Burst > 0 {
+		return float64(rl.Burst)
+	}
+	return math.Max(1, math.Ceil(rl.Rate))
+}
+
+type bucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	limits   RateLimits
+	next     http.Handler
+	inFlight chan struct{}
+	now      func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewRateLimiter applies per-caller rate limits and the in-flight cap to the
+// handler, responding with 429 Too Many Requests and Retry-After when a limit
+// is reached. Callers are identified by the UID of a Unix domain socket peer,
+// or otherwise by their remote address.
+func NewRateLimiter(limits RateLimits, next http.Handler) http.Handler {
+	rl := newRateLimiter(limits)
+	rl.next = next
+	return rl
+}
+
+func newRateLimiter(limits RateLimits) *rateLimiter {
+	// legacy aliases share the limits of their versioned paths.
+	ops := make(map[string]RateLimit, len(limits.Operations))
+	for op, limit := range limits.Operations {
+		ops[versioned(op)] = limit
+	}
+	limits.Operations = ops
+
+	rl := &rateLimiter{
+		limits:  limits,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+	if limits.MaxInFlight > 0 {
+		rl.inFlight = make(chan struct{}, limits.MaxInFlight)
+	}
+	return rl
+}
+
+func (rl *rateLimiter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if wait, ok := rl.allow(caller(r), r.URL.Path); !ok {
+		tooManyRequests(w, wait)
+		return
+	}
+	if !rl.acquire() {
+		tooManyRequests(w, time.Second)
+		return
+	}
+	defer rl.release()
+	rl.next.ServeHTTP(w, r)
+}
+
+// acquire takes one of the in-flight slots, reporting false when none are
+// free. A successful acquire must be followed by release.
+func (rl *rateLimiter) acquire() bool {
+	if rl.inFlight == nil {
+		return true
+	}
+	select {
+	case rl.inFlight <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (rl *rateLimiter) release() {
+	if rl.inFlight != nil {
+		<-rl.inFlight
+	}
+}
+
+// allow takes a token from the caller's bucket for the operation at path,
+// returning how long to wait when the bucket is empty.
+func (rl *rateLimiter) allow(caller, path string) (time.Duration, bool) {
+	op, limit, ok := rl.operation(path)
+	if !ok {
+		return 0, true
+	}
+	key := caller + " " + op
+	now := rl.now()
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.sweep(now)
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &bucket{limit: limit, tokens: limit.burst(), last: now}
+		rl.buckets[key] = b
+	}
+	b.tokens = math.Min(limit.burst(), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// operation returns the most specific limit which applies to the path.
+func (rl *rateLimiter) operation(path string) (string, RateLimit, bool) {
+	path = versioned(path)
+	if limit, ok := rl.limits.Operations[path]; ok {
+		return path, limit, true
+	}
+	best := ""
+	for op := range rl.limits.Operations {
+		if strings.HasSuffix(op, "/") && strings.HasPrefix(path, op) && len(op) > len(best) {
+			best = op
+		}
+	}
+	if best != "" {
+		return best, rl.limits.Operations[best], true
+	}
+	if rl.limits.Default != nil {
+		return "", *rl.limits.Default, true
+	}
+	return "", RateLimit{}, false
+}
+
+// sweep removes buckets which have been idle for long enough to refill, as
+// they are the same as a new bucket.
+func (rl *rateLimiter) sweep(now time.Time) {
+	if now.Sub(rl.lastSweep) < rateLimitSweep {
+		return
+	}
+	rl.lastSweep = now
+	for key, b := range rl.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*b.limit.Rate >= b.limit.burst() {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+// caller identifies the client which made the request.
+func caller(r *http.Request) string {
+	if p, ok := PeerCredentialsFromContext(r.Context()); ok {
+		return "uid:" + strconv.FormatUint(uint64(p.UID), 10)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func tooManyRequests(w http.ResponseWriter, wait time.Duration) {
+	secs := int(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(secs, 1)))
+	jsonResponse(w, http.StatusTooManyRequests, &ErrorResponse{
+		Message: "rate limit exceeded",
+	})
+}
@@ -0,0 +1,113 @@
+package praetorian_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limits := praetorian.RateLimits{
+		Default: &praetorian.RateLimit{Rate: 0.5, Burst: 3},
+		Operations: map[string]praetorian.RateLimit{
+			"/unwrap":      {Rate: 0.5, Burst: 1},
+			"/v1/transit/": {Rate: 0.5, Burst: 2},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		requests []string // path and remote address pairs
+		want     []int
+	}{
+		{
+			name:     "operation limit",
+			requests: []string{"/unwrap", "10.0.0.1:1000", "/unwrap", "10.0.0.1:1001"},
+			want:     []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:     "versioned path shares the legacy limit",
+			requests: []string{"/unwrap", "10.0.0.1:1000", "/v1/unwrap", "10.0.0.1:1001"},
+			want:     []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:     "callers are limited separately",
+			requests: []string{"/unwrap", "10.0.0.1:1000", "/unwrap", "10.0.0.2:1000"},
+			want:     []int{http.StatusOK, http.StatusOK},
+		},
+		{
+			name:     "operations are limited separately",
+			requests: []string{"/unwrap", "10.0.0.1:1000", "/wrap", "10.0.0.1:1000"},
+			want:     []int{http.StatusOK, http.StatusOK},
+		},
+		{
+			name: "prefix operation",
+			requests: []string{
+				"/v1/transit/encrypt/app", "10.0.0.1:1000",
+				"/v1/transit/decrypt/app", "10.0.0.1:1000",
+				"/v1/transit/rewrap/app", "10.0.0.1:1000",
+			},
+			want: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name: "default limit",
+			requests: []string{
+				"/wrap", "10.0.0.1:1000",
+				"/mac", "10.0.0.1:1000",
+				"/sign", "10.0.0.1:1000",
+				"/wrap", "10.0.0.1:1000",
+			},
+			want: []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := praetorian.NewRateLimiter(limits, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			for i, want := range tt.want {
+				req := httptest.NewRequest(http.MethodPost, tt.requests[i*2], nil)
+				req.RemoteAddr = tt.requests[i*2+1]
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+
+				if rec.Code != want {
+					t.Errorf("request %d status = %d, want %d", i, rec.Code, want)
+				}
+				if want == http.StatusTooManyRequests && rec.Header().Get("Retry-After") != "2" {
+					t.Errorf("request %d Retry-After = %q, want %q", i, rec.Header().Get("Retry-After"), "2")
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiter_MaxInFlight(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := praetorian.NewRateLimiter(praetorian.RateLimits{MaxInFlight: 1}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/unwrap", nil))
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/unwrap", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("Retry-After header not set")
+	}
+
+	close(release)
+	wg.Wait()
+}
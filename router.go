@@ -0,0 +1,63 @@
+package praetorian
+
+import (
+	"net/http"
+	"strings"
+)
+
+// routeMethods are tried against the routes to build the Allow header.
+var routeMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// NewRouter serves requests with the routes of mux and answers requests which
+// match no route with JSON errors. A path which has routes for other methods
+// receives 405 with an Allow header, or an empty response listing the allowed
+// methods for OPTIONS, and any other path receives 404.
+func NewRouter(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		allow := allowedMethods(mux, r)
+		switch {
+		case len(allow) == 0:
+			jsonResponse(w, http.StatusNotFound, &ErrorResponse{
+				Message: "Not Found",
+			})
+		case r.Method == http.MethodOptions:
+			w.Header().Set("Allow", strings.Join(append(allow, http.MethodOptions), ", "))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			methodNotAllowed(w, allow...)
+		}
+	})
+}
+
+// allowedMethods returns the methods which have a route for the path of r.
+func allowedMethods(mux *http.ServeMux, r *http.Request) []string {
+	var allow []string
+	probe := *r
+	for _, method := range routeMethods {
+		probe.Method = method
+		if _, pattern := mux.Handler(&probe); pattern != "" {
+			allow = append(allow, method)
+		}
+	}
+	return allow
+}
+
+// methodNotAllowed responds with 405 and the methods which are allowed.
+func methodNotAllowed(w http.ResponseWriter, allow ...string) {
+	w.Header().Set("Allow", strings.Join(allow, ", "))
+	jsonResponse(w, http.StatusMethodNotAllowed, &ErrorResponse{
+		Message: "Method Not Allowed",
+	})
+}
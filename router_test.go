@@ -0,0 +1,104 @@
+package praetorian_test
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+func TestNewRouter(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	h := praetorian.NewServer(newTestKeystore(t, testConfig)).Handler
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		wantStatus  int
+		wantAllow   string
+		wantMessage string
+	}{
+		{
+			name:        "method not allowed",
+			method:      http.MethodGet,
+			path:        "/wrap",
+			wantStatus:  http.StatusMethodNotAllowed,
+			wantAllow:   "POST",
+			wantMessage: "Method Not Allowed",
+		},
+		{
+			name:        "method not allowed for a path with wildcards",
+			method:      http.MethodDelete,
+			path:        "/v1/transit/encrypt/app",
+			wantStatus:  http.StatusMethodNotAllowed,
+			wantAllow:   "POST, PUT",
+			wantMessage: "Method Not Allowed",
+		},
+		{
+			name:        "GET routes allow HEAD",
+			method:      http.MethodPost,
+			path:        "/healthz",
+			wantStatus:  http.StatusMethodNotAllowed,
+			wantAllow:   "GET, HEAD",
+			wantMessage: "Method Not Allowed",
+		},
+		{
+			name:       "options",
+			method:     http.MethodOptions,
+			path:       "/unwrap",
+			wantStatus: http.StatusNoContent,
+			wantAllow:  "POST, OPTIONS",
+		},
+		{
+			name:        "unknown path",
+			method:      http.MethodGet,
+			path:        "/decrypt",
+			wantStatus:  http.StatusNotFound,
+			wantMessage: "Not Found",
+		},
+		{
+			name:        "options for an unknown path",
+			method:      http.MethodOptions,
+			path:        "/decrypt",
+			wantStatus:  http.StatusNotFound,
+			wantMessage: "Not Found",
+		},
+		{
+			name:       "allowed method",
+			method:     http.MethodGet,
+			path:       "/healthz",
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("ServeHTTP() status = %d, wantStatus = %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("ServeHTTP() Allow = %q, wantAllow = %q", got, tt.wantAllow)
+			}
+			if tt.wantMessage == "" {
+				return
+			}
+			var res praetorian.ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+				t.Fatalf("ServeHTTP() failed to parse response: %v", err)
+			}
+			if res.Message != tt.wantMessage {
+				t.Errorf("ServeHTTP() message = %q, wantMessage = %q", res.Message, tt.wantMessage)
+			}
+		})
+	}
+}
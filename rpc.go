@@ -0,0 +1,431 @@
+package praetorian
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// RPCServicePath is the path prefix of the KeyService defined in
+// proto/praetorian/v1/praetorian.proto.
+const RPCServicePath = "/praetorian.v1.KeyService/"
+
+// Connect and gRPC status codes.
+const (
+	RPCInvalidArgument    = "invalid_argument"
+	RPCNotFound           = "not_found"
+	RPCPermissionDenied   = "permission_denied"
+	RPCFailedPrecondition = "failed_precondition"
+	RPCUnimplemented      = "unimplemented"
+	RPCInternal           = "internal"
+	RPCUnavailable        = "unavailable"
+)
+
+var rpcCodes = map[string]struct {
+	grpc   int
+	status int
+}{
+	RPCInvalidArgument:    {3, http.StatusBadRequest},
+	RPCNotFound:           {5, http.StatusNotFound},
+	RPCPermissionDenied:   {7, http.StatusForbidden},
+	RPCFailedPrecondition: {9, http.StatusBadRequest},
+	RPCUnimplemented:      {12, http.StatusNotImplemented},
+	RPCInternal:           {13, http.StatusInternalServerError},
+	RPCUnavailable:        {14, http.StatusServiceUnavailable},
+}
+
+// RPCError is the Connect error body, also reported through gRPC trailers.
+type RPCError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+// RPCMessage is implemented by every KeyService request and response.
+type RPCMessage interface {
+	MarshalProto() []byte
+	UnmarshalProto(b []byte) error
+}
+
+type RPCWrapRequest struct {
+	Plaintext []byte `json:"plaintext,omitempty"`
+}
+
+func (m *RPCWrapRequest) MarshalProto() []byte {
+	return protoAppendBytes(nil, 1, m.Plaintext)
+}
+
+func (m *RPCWrapRequest) UnmarshalProto(b []byte) error {
+	return protoRange(b, func(field, wire int, v []byte, _ uint64) error {
+		if field == 1 && wire == wireBytes {
+			m.Plaintext = append([]byte(nil), v...)
+		}
+		return nil
+	})
+}
+
+type RPCWrapResponse struct {
+	ID         string `json:"id,omitempty"`
+	Ciphertext []byte `json:"ciphertext,omitempty"`
+}
+
+func (m *RPCWrapResponse) MarshalProto() []byte {
+	b := protoAppendString(nil, 1, m.ID)
+	return protoAppendBytes(b, 2, m.Ciphertext)
+}
+
+func (m *RPCWrapResponse) UnmarshalProto(b []byte) error {
+	return protoRange(b, func(field, wire int, v []byte, _ uint64) error {
+		switch {
+		case field == 1 && wire == wireBytes:
+			m.ID = string(v)
+		case field == 2 && wire == wireBytes:
+			m.Ciphertext = append([]byte(nil), v...)
+		}
+		return nil
+	})
+}
+
+// RPCUnwrapRequest unwraps a ciphertext. DataKey states that the ciphertext
+// holds a data key from Wrap or GenerateDataKey, which is returned as the
+// plaintext. Without it the plaintext is the wrapped JSON document, as
+// returned by /v1/unwrap.
+type RPCUnwrapRequest struct {
+	ID         string `json:"id,omitempty"`
+	Ciphertext []byte `json:"ciphertext,omitempty"`
+	DataKey    bool   `json:"dataKey,omitempty"`
+}
+
+func (m *RPCUnwrapRequest) MarshalProto() []byte {
+	b := protoAppendString(nil, 1, m.ID)
+	b = protoAppendBytes(b, 2, m.Ciphertext)
+	if m.DataKey {
+		b = protoAppendVarint(b, 3, 1)
+	}
+	return b
+}
+
+func (m *RPCUnwrapRequest) UnmarshalProto(b []byte) error {
+	return protoRange(b, func(field, wire int, v []byte, n uint64) error {
+		switch {
+		case field == 1 && wire == wireBytes:
+			m.ID = string(v)
+		case field == 2 && wire == wireBytes:
+			m.Ciphertext = append([]byte(nil), v...)
+		case field == 3 && wire == wireVarint:
+			m.DataKey = n != 0
+		}
+		return nil
+	})
+}
+
+// RPCRewrapRequest rewraps a ciphertext under the active root key.
+type RPCRewrapRequest = RPCWrapResponse
+
+type RPCUnwrapResponse struct {
+	Plaintext []byte `json:"plaintext,omitempty"`
+}
+
+func (m *RPCUnwrapResponse) MarshalProto() []byte {
+	return protoAppendBytes(nil, 1, m.Plaintext)
+}
+
+func (m *RPCUnwrapResponse) UnmarshalProto(b []byte) error {
+	return protoRange(b, func(field, wire int, v []byte, _ uint64) error {
+		if field == 1 && wire == wireBytes {
+			m.Plaintext = append([]byte(nil), v...)
+		}
+		return nil
+	})
+}
+
+type RPCGenerateDataKeyRequest struct {
+	Length int32 `json:"length,omitempty"`
+}
+
+func (m *RPCGenerateDataKeyRequest) MarshalProto() []byte {
+	return protoAppendVarint(nil, 1, uint64(int64(m.Length)))
+}
+
+func (m *RPCGenerateDataKeyRequest) UnmarshalProto(b []byte) error {
+	return protoRange(b, func(field, wire int, _ []byte, n uint64) error {
+		if field == 1 && wire == wireVarint {
+			m.Length = int32(int64(n))
+		}
+		return nil
+	})
+}
+
+type RPCGenerateDataKeyResponse struct {
+	ID         string `json:"id,omitempty"`
+	Plaintext  []byte `json:"plaintext,omitempty"`
+	Ciphertext []byte `json:"ciphertext,omitempty"`
+}
+
+func (m *RPCGenerateDataKeyResponse) MarshalProto() []byte {
+	b := protoAppendString(nil, 1, m.ID)
+	b = protoAppendBytes(b, 2, m.Plaintext)
+	return protoAppendBytes(b, 3, m.Ciphertext)
+}
+
+func (m *RPCGenerateDataKeyResponse) UnmarshalProto(b []byte) error {
+	return protoRange(b, func(field, wire int, v []byte, _ uint64) error {
+		switch {
+		case field == 1 && wire == wireBytes:
+			m.ID = string(v)
+		case field == 2 && wire == wireBytes:
+			m.Plaintext = append([]byte(nil), v...)
+		case field == 3 && wire == wireBytes:
+			m.Ciphertext = append([]byte(nil), v...)
+		}
+		return nil
+	})
+}
+
+// rpcMethod decodes a request with the codec and returns the response.
+type rpcMethod func(r *http.Request, decode func(RPCMessage) error) (RPCMessage, error)
+
+// HandleRPC serves the KeyService over gRPC (application/grpc) and the
+// Connect unary protocol (application/proto or application/json). gRPC
+// clients require HTTP/2.
+func HandleRPC(activeKey string, keys KeyFinder) http.HandlerFunc {
+	methods := map[string]rpcMethod{
+		"Wrap": func(r *http.Request, decode func(RPCMessage) error) (RPCMessage, error) {
+			var req RPCWrapRequest
+			if err := decode(&req); err != nil {
+				return nil, err
+			}
+			return rpcWrap(r, keys, activeKey, &DataKey{Key: req.Plaintext})
+		},
+		"Unwrap": func(r *http.Request, decode func(RPCMessage) error) (RPCMessage, error) {
+			var req RPCUnwrapRequest
+			if err := decode(&req); err != nil {
+				return nil, err
+			}
+			dec, err := rpcUnwrap(r, keys, req.ID, req.Ciphertext)
+			if err != nil {
+				return nil, err
+			}
+			if !req.DataKey {
+				return &RPCUnwrapResponse{Plaintext: dec}, nil
+			}
+			defer clear(dec)
+			var dk DataKey
+			if err := json.Unmarshal(dec, &dk); err != nil || dk.Key == nil {
+				return nil, &RPCError{RPCInvalidArgument, "ciphertext does not hold a data key"}
+			}
+			return &RPCUnwrapResponse{Plaintext: dk.Key}, nil
+		},
+		"Rewrap": func(r *http.Request, decode func(RPCMessage) error) (RPCMessage, error) {
+			var req RPCRewrapRequest
+			if err := decode(&req); err != nil {
+				return nil, err
+			}
+			dec, err := rpcUnwrap(r, keys, req.ID, req.Ciphertext)
+			if err != nil {
+				return nil, err
+			}
+			defer clear(dec)
+			return rpcWrap(r, keys, activeKey, json.RawMessage(dec))
+		},
+		"GenerateDataKey": func(r *http.Request, decode func(RPCMessage) error) (RPCMessage, error) {
+			var req RPCGenerateDataKeyRequest
+			if err := decode(&req); err != nil {
+				return nil, err
+			}
+			if req.Length == 0 {
+				req.Length = RootKeyLength
+			}
+			if req.Length != 16 && req.Length != 24 && req.Length != 32 {
+				return nil, &RPCError{RPCInvalidArgument, "length must be 16, 24 or 32"}
+			}
+			dek := make([]byte, req.Length)
+			if _, err := rand.Read(dek); err != nil {
+				return nil, err
+			}
+			w, err := rpcWrap(r, keys, activeKey, &DataKey{Key: dek})
+			if err != nil {
+				return nil, err
+			}
+			return &RPCGenerateDataKeyResponse{
+				ID:         w.ID,
+				Plaintext:  dek,
+				Ciphertext: w.Ciphertext,
+			}, nil
+		},
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ct := r.Header.Get("Content-Type")
+		grpc := ct == "application/grpc" || ct == "application/grpc+proto"
+		proto := grpc || ct == "application/proto"
+
+		if !proto && ct != "application/json" {
+			w.Header().Set("Accept-Post", "application/grpc, application/proto, application/json")
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+
+		method, ok := methods[strings.TrimPrefix(r.URL.Path, RPCServicePath)]
+		if !ok {
+			writeRPCError(w, grpc, &RPCError{RPCUnimplemented, "unknown method " + r.URL.Path})
+			return
+		}
+
+		maxBytes := maxBodyBytes(r)
+		b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+		if err != nil {
+			writeRPCError(w, grpc, &RPCError{RPCInvalidArgument, "failed to read request body"})
+			return
+		}
+		defer r.Body.Close()
+
+		if grpc {
+			if b, err = grpcUnframe(b); err != nil {
+				writeRPCError(w, grpc, err)
+				return
+			}
+		}
+
+		res, err := method(r, func(m RPCMessage) error {
+			var err error
+			if proto {
+				err = m.UnmarshalProto(b)
+			} else {
+				err = json.Unmarshal(b, m)
+			}
+			if err != nil {
+				return &RPCError{RPCInvalidArgument, "invalid request message"}
+			}
+			return nil
+		})
+		if err != nil {
+			writeRPCError(w, grpc, err)
+			return
+		}
+
+		switch {
+		case grpc:
+			msg := res.MarshalProto()
+			w.Header().Set("Content-Type", "application/grpc+proto")
+			w.WriteHeader(http.StatusOK)
+			w.Write(grpcFrame(msg))
+			w.Header().Set(http.TrailerPrefix+"Grpc-Status", "0")
+		case proto:
+			w.Header().Set("Content-Type", "application/proto")
+			w.WriteHeader(http.StatusOK)
+			w.Write(res.MarshalProto())
+		default:
+			jsonResponse(w, http.StatusOK, res)
+		}
+	}
+}
+
+func rpcWrap(r *http.Request, keys KeyFinder, activeKey string, v any) (*RPCWrapResponse, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(b)
+
+	key, err := findKey(r.Context(), keys, activeKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkKeyState(keys, key, true); err != nil {
+		return nil, err
+	}
+	enc, err := key.Encrypt(b)
+	if err != nil {
+		return nil, err
+	}
+	return &RPCWrapResponse{ID: key.ID(), Ciphertext: enc}, nil
+}
+
+func rpcUnwrap(r *http.Request, keys KeyFinder, id string, ciphertext []byte) ([]byte, error) {
+	key, err := findKey(r.Context(), keys, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkKeyState(keys, key, false); err != nil {
+		return nil, err
+	}
+	if err := observeUnwrap(r, key.ID()); err != nil {
+		return nil, err
+	}
+	return key.Decrypt(ciphertext)
+}
+
+// rpcErrorFrom maps keystore errors to the status codes which correspond to
+// the responses of the JSON handlers.
+func rpcErrorFrom(err error) *RPCError {
+	var re *RPCError
+	switch {
+	case errors.As(err, &re):
+		return re
+	case unavailable(err):
+		return &RPCError{RPCUnavailable, err.Error()}
+	case errors.Is(err, ErrRootKeyNotFound):
+		return &RPCError{RPCNotFound, err.Error()}
+	case errors.Is(err, ErrGCMOpen):
+		return &RPCError{RPCInvalidArgument, "data authentication failed"}
+	case errors.Is(err, ErrCallerLockedOut):
+		return &RPCError{RPCPermissionDenied, err.Error()}
+	case errors.Is(err, ErrRootKeyInactive):
+		return &RPCError{RPCFailedPrecondition, err.Error()}
+	}
+	return &RPCError{RPCInternal, err.Error()}
+}
+
+func writeRPCError(w http.ResponseWriter, grpc bool, err error) {
+	re := rpcErrorFrom(err)
+	code := rpcCodes[re.Code]
+	if !grpc {
+		jsonResponse(w, code.status, re)
+		return
+	}
+	// gRPC reports errors in a trailers-only response.
+	w.Header().Set("Content-Type", "application/grpc+proto")
+	w.Header().Set("Grpc-Status", strconv.Itoa(code.grpc))
+	w.Header().Set("Grpc-Message", grpcEncodeMessage(re.Message))
+	w.WriteHeader(http.StatusOK)
+}
+
+func grpcFrame(msg []byte) []byte {
+	b := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(b[1:], uint32(len(msg)))
+	return append(b, msg...)
+}
+
+func grpcUnframe(b []byte) ([]byte, error) {
+	if len(b) < 5 || uint64(binary.BigEndian.Uint32(b[1:5])) != uint64(len(b)-5) {
+		return nil, &RPCError{RPCInvalidArgument, "invalid gRPC message frame"}
+	}
+	if b[0] != 0 {
+		return nil, &RPCError{RPCUnimplemented, "compressed messages are not supported"}
+	}
+	return b[5:], nil
+}
+
+// grpcEncodeMessage percent-encodes the status message as required by gRPC.
+func grpcEncodeMessage(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c > 0x7e || c == '%' {
+			fmt.Fprintf(&sb, "%%%02X", c)
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
@@ -0,0 +1,236 @@
+package praetorian_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+func rpcRequest(t *testing.T, handler http.Handler, method, contentType string, body []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, praetorian.RPCServicePath+method, bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandleRPC_Connect(t *testing.T) {
+	keys := newTestKeystore(t, testConfig)
+	handler := praetorian.HandleRPC(praetorian.ActiveKeyID, keys)
+	dek := []byte("0123456789abcdef")
+
+	// Wrap using the JSON codec.
+	body, _ := json.Marshal(praetorian.RPCWrapRequest{Plaintext: dek})
+	rec := rpcRequest(t, handler, "Wrap", "application/json", body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Wrap status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var wrapped praetorian.RPCWrapResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &wrapped); err != nil {
+		t.Fatalf("Wrap failed to parse response: %v", err)
+	}
+	if wrapped.ID != "1" {
+		t.Errorf("Wrap id = %q, want %q", wrapped.ID, "1")
+	}
+
+	// Rewrap and Unwrap using the binary codec.
+	rec = rpcRequest(t, handler, "Rewrap", "application/proto", wrapped.MarshalProto())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Rewrap status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var rewrapped praetorian.RPCWrapResponse
+	if err := rewrapped.UnmarshalProto(rec.Body.Bytes()); err != nil {
+		t.Fatalf("Rewrap failed to parse response: %v", err)
+	}
+	if bytes.Equal(rewrapped.Ciphertext, wrapped.Ciphertext) {
+		t.Error("Rewrap returned the original ciphertext")
+	}
+
+	unwrap := &praetorian.RPCUnwrapRequest{ID: rewrapped.ID, Ciphertext: rewrapped.Ciphertext, DataKey: true}
+	rec = rpcRequest(t, handler, "Unwrap", "application/proto", unwrap.MarshalProto())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Unwrap status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var unwrapped praetorian.RPCUnwrapResponse
+	if err := unwrapped.UnmarshalProto(rec.Body.Bytes()); err != nil {
+		t.Fatalf("Unwrap failed to parse response: %v", err)
+	}
+	if !bytes.Equal(unwrapped.Plaintext, dek) {
+		t.Errorf("Unwrap plaintext = %x, want %x", unwrapped.Plaintext, dek)
+	}
+
+	// without data_key the wrapped JSON document is returned, as by /unwrap.
+	unwrap.DataKey = false
+	rec = rpcRequest(t, handler, "Unwrap", "application/proto", unwrap.MarshalProto())
+	if err := unwrapped.UnmarshalProto(rec.Body.Bytes()); err != nil {
+		t.Fatalf("Unwrap failed to parse response: %v", err)
+	}
+	want, _ := json.Marshal(praetorian.DataKey{Key: dek})
+	if !bytes.Equal(unwrapped.Plaintext, want) {
+		t.Errorf("Unwrap plaintext = %s, want %s", unwrapped.Plaintext, want)
+	}
+
+	// a document other than a data key is not returned as one.
+	wrec := httptest.NewRecorder()
+	praetorian.HandleWrap(praetorian.ActiveKeyID, keys).ServeHTTP(wrec,
+		httptest.NewRequest(http.MethodPost, "/wrap", strings.NewReader(`{"value": "keep it secret, keep it safe"}`)))
+	var doc praetorian.WrapResponse
+	if err := json.Unmarshal(wrec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("HandleWrap() failed to parse response: %v", err)
+	}
+	body, _ = json.Marshal(map[string]any{"id": doc.ID, "ciphertext": doc.Token, "dataKey": true})
+	rec = rpcRequest(t, handler, "Unwrap", "application/json", body)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Unwrap status = %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body)
+	}
+}
+
+func TestHandleRPC_GRPC(t *testing.T) {
+	handler := praetorian.HandleRPC(praetorian.ActiveKeyID, newTestKeystore(t, testConfig))
+
+	req := &praetorian.RPCGenerateDataKeyRequest{Length: 16}
+	msg := req.MarshalProto()
+	frame := append([]byte{0, 0, 0, 0, 0}, msg...)
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(msg)))
+
+	rec := rpcRequest(t, handler, "GenerateDataKey", "application/grpc", frame)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GenerateDataKey status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := rec.Result().Trailer.Get("Grpc-Status"); got != "0" {
+		t.Fatalf("GenerateDataKey grpc-status = %q, want %q", got, "0")
+	}
+
+	b := rec.Body.Bytes()
+	if len(b) < 5 || int(binary.BigEndian.Uint32(b[1:5])) != len(b)-5 {
+		t.Fatalf("GenerateDataKey returned an invalid frame: %x", b)
+	}
+	var res praetorian.RPCGenerateDataKeyResponse
+	if err := res.UnmarshalProto(b[5:]); err != nil {
+		t.Fatalf("GenerateDataKey failed to parse response: %v", err)
+	}
+	if len(res.Plaintext) != 16 {
+		t.Errorf("GenerateDataKey plaintext length = %d, want 16", len(res.Plaintext))
+	}
+
+	// the generated key unwraps to the same plaintext.
+	rec = rpcRequest(t, handler, "Unwrap", "application/proto",
+		(&praetorian.RPCUnwrapRequest{ID: res.ID, Ciphertext: res.Ciphertext, DataKey: true}).MarshalProto())
+	var unwrapped praetorian.RPCUnwrapResponse
+	if err := unwrapped.UnmarshalProto(rec.Body.Bytes()); err != nil {
+		t.Fatalf("Unwrap failed to parse response: %v", err)
+	}
+	if !bytes.Equal(unwrapped.Plaintext, res.Plaintext) {
+		t.Errorf("Unwrap plaintext = %x, want %x", unwrapped.Plaintext, res.Plaintext)
+	}
+}
+
+func TestHandleRPC_Errors(t *testing.T) {
+	keys := newTestKeystore(t, testConfig)
+	tests := []struct {
+		name        string
+		keys        praetorian.KeyFinder
+		method      string
+		contentType string
+		body        string
+		wantStatus  int
+		wantCode    string
+		wantGRPC    string
+	}{
+		{
+			name:        "unknown key",
+			keys:        keys,
+			method:      "Unwrap",
+			contentType: "application/json",
+			body:        `{"id": "missing", "ciphertext": "AAAA"}`,
+			wantStatus:  http.StatusNotFound,
+			wantCode:    praetorian.RPCNotFound,
+		},
+		{
+			name:        "sealed keystore",
+			method:      "Unwrap",
+			contentType: "application/json",
+			body:        `{"id": "sealed", "ciphertext": "AAAA"}`,
+			wantStatus:  http.StatusServiceUnavailable,
+			wantCode:    praetorian.RPCUnavailable,
+		},
+		{
+			name:        "authentication failure",
+			keys:        keys,
+			method:      "Unwrap",
+			contentType: "application/json",
+			body:        `{"id": "1", "ciphertext": "AAAA"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    praetorian.RPCInvalidArgument,
+		},
+		{
+			name:        "invalid length",
+			method:      "GenerateDataKey",
+			contentType: "application/json",
+			body:        `{"length": 20}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    praetorian.RPCInvalidArgument,
+		},
+		{
+			name:        "unknown method",
+			method:      "Delete",
+			contentType: "application/json",
+			body:        `{}`,
+			wantStatus:  http.StatusNotImplemented,
+			wantCode:    praetorian.RPCUnimplemented,
+		},
+		{
+			name:        "unsupported content type",
+			method:      "Wrap",
+			contentType: "text/plain",
+			body:        `{}`,
+			wantStatus:  http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "invalid grpc frame",
+			method:      "Wrap",
+			contentType: "application/grpc",
+			body:        "\x00\x00",
+			wantStatus:  http.StatusOK,
+			wantGRPC:    "3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var keys praetorian.KeyFinder = &MockKeystore{}
+			if tt.keys != nil {
+				keys = tt.keys
+			}
+			handler := praetorian.HandleRPC(praetorian.ActiveKeyID, keys)
+			rec := rpcRequest(t, handler, tt.method, tt.contentType, []byte(tt.body))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("HandleRPC() status = %d, wantStatus = %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantGRPC != "" {
+				if got := rec.Header().Get("Grpc-Status"); got != tt.wantGRPC {
+					t.Errorf("HandleRPC() grpc-status = %q, want %q", got, tt.wantGRPC)
+				}
+				return
+			}
+			if tt.wantCode == "" {
+				return
+			}
+			var res praetorian.RPCError
+			if err := json.NewDecoder(strings.NewReader(rec.Body.String())).Decode(&res); err != nil {
+				t.Fatalf("HandleRPC() failed to parse response: %v", err)
+			}
+			if res.Code != tt.wantCode {
+				t.Errorf("HandleRPC() code = %q, wantCode = %q", res.Code, tt.wantCode)
+			}
+		})
+	}
+}
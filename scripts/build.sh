@@ -1,3 +1,4 @@
 #!/usr/bin/env bash
-go build -ldflags="-w -s" -o dist/praetorian ./cmd
-go test -c -o dist/praetorian.test
\ No newline at end of file
+VERSION="$(git describe --tags --always --dirty 2>/dev/null || echo dev)"
+go build -ldflags="-w -s -X main.version=${VERSION}" -o dist/praetorian ./cmd
+go test -c -o dist/praetorian.test
@@ -0,0 +1,165 @@
+package praetorian
+
+import (
+	"bytes"
+	"crypto/rand"
+)
+
+// MasterKeyLength is the size of the secret which protects sealed root keys.
+const MasterKeyLength = 32
+
+// a sealed key is the AES-GCM nonce, the key and the authentication tag.
+const sealedRootKeyLength = 12 + RootKeyLength + 16
+
+// Unsealer accepts key shares until the keystore can be unsealed.
+type Unsealer interface {
+	Sealed() bool
+	Unseal(share []byte) (UnsealStatus, error)
+}
+
+// UnsealStatus describes the progress towards unsealing the keystore.
+type UnsealStatus struct {
+	Sealed    bool `json:"sealed"`
+	Threshold int  `json:"threshold"`
+	Progress  int  `json:"progress"`
+}
+
+// SealConfig encrypts the root keys of cfg under a newly generated master key
+// and splits that key into n shares, any k of which will unseal the keystore.
+func SealConfig(cfg *Config, n, k int) (*Config, [][]byte, error) {
+	if cfg.Sealed() {
+		return nil, nil, ErrKeystoreSealed
+	}
+
+	master := make([]byte, MasterKeyLength)
+	if _, err := rand.Read(master); err != nil {
+		return nil, nil, err
+	}
+	defer clear(master)
+
+	shares, err := SplitSecret(master, n, k)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mk := &key{value: master}
+	sealed, err := transformConfig(cfg, mk.Encrypt)
+	if err != nil {
+		return nil, nil, err
+	}
+	sealed.UnsealThreshold = k
+	return sealed, shares, nil
+}
+
+// Sealed reports whether the keystore is waiting for unseal shares.
+func (ks *keystore) Sealed() bool {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	return ks.sealed != nil
+}
+
+// Unseal records a master key share. Once the threshold has been reached the
+// master key is reconstructed and used to decrypt the root keys. A failed
+// attempt discards all collected shares.
+func (ks *keystore) Unseal(share []byte) (UnsealStatus, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if ks.sealed == nil {
+		return ks.status(), nil
+	}
+	if len(share) != MasterKeyLength+1 {
+		return ks.status(), ErrInvalidShare
+	}
+	for _, s := range ks.shares {
+		if s[MasterKeyLength] == share[MasterKeyLength] {
+			if !bytes.Equal(s, share) {
+				return ks.status(), ErrInvalidShare
+			}
+			return ks.status(), nil
+		}
+	}
+
+	ks.shares = append(ks.shares, bytes.Clone(share))
+	if len(ks.shares) < ks.threshold {
+		return ks.status(), nil
+	}
+
+	master, err := CombineShares(ks.shares)
+	ks.resetShares()
+	if err != nil {
+		return ks.status(), ErrUnsealFailed
+	}
+	defer clear(master)
+
+	mk := &key{value: master}
+	cfg, err := transformConfig(ks.sealed, mk.Decrypt)
+	if err != nil {
+		return ks.status(), ErrUnsealFailed
+	}
+	if err := ks.load(cfg); err != nil {
+		return ks.status(), ErrUnsealFailed
+	}
+	ks.sealed = nil
+	return ks.status(), nil
+}
+
+func (ks *keystore) resetShares() {
+	for _, s := range ks.shares {
+		clear(s)
+	}
+	ks.shares = nil
+}
+
+func (ks *keystore) status() UnsealStatus {
+	return UnsealStatus{
+		Sealed:    ks.sealed != nil,
+		Threshold: ks.threshold,
+		Progress:  len(ks.shares),
+	}
+}
+
+// transformConfig returns a copy of cfg with every private key passed through fn.
+func transformConfig(cfg *Config, fn func([]byte) ([]byte, error)) (*Config, error) {
+	out := &Config{
+		ActiveKeyID:        cfg.ActiveKeyID,
+		RootKeys:           make(map[string][]byte, len(cfg.RootKeys)),
+		ECDHKeys:           make(map[string]ECDHKeyConfig, len(cfg.ECDHKeys)),
+		ActiveSigningKeyID: cfg.ActiveSigningKeyID,
+		SigningKeys:        make(map[string]SigningKeyConfig, len(cfg.SigningKeys)),
+		ActiveMACKeyID:     cfg.ActiveMACKeyID,
+		MACKeys:            make(map[string][]byte, len(cfg.MACKeys)),
+		RateLimits:         cfg.RateLimits,
+		AnomalyDetection:   cfg.AnomalyDetection,
+		StateFile:          cfg.StateFile,
+	}
+	for id, val := range cfg.RootKeys {
+		b, err := fn(val)
+		if err != nil {
+			return nil, err
+		}
+		out.RootKeys[id] = b
+	}
+	for id, val := range cfg.ECDHKeys {
+		b, err := fn(val.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		out.ECDHKeys[id] = ECDHKeyConfig{Curve: val.Curve, PrivateKey: b}
+	}
+	for id, val := range cfg.SigningKeys {
+		b, err := fn(val.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		out.SigningKeys[id] = SigningKeyConfig{Algorithm: val.Algorithm, PrivateKey: b}
+	}
+	for id, val := range cfg.MACKeys {
+		b, err := fn(val)
+		if err != nil {
+			return nil, err
+		}
+		out.MACKeys[id] = b
+	}
+	return out, nil
+}
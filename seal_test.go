@@ -0,0 +1,157 @@
+package praetorian_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+func TestKeystore_Unseal(t *testing.T) {
+	t.Setenv(praetorian.EnvKey, testECDHConfig)
+	cfg, err := praetorian.NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig() failed to create config: %v", err)
+	}
+
+	sealed, shares, err := praetorian.SealConfig(cfg, 3, 2)
+	if err != nil {
+		t.Fatalf("SealConfig() failed to seal config: %v", err)
+	}
+	b, err := json.Marshal(sealed)
+	if err != nil {
+		t.Fatalf("json.Marshal() failed to encode sealed config: %v", err)
+	}
+
+	t.Setenv(praetorian.EnvKey, string(b))
+	cfg, err = praetorian.NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig() failed to read sealed config: %v", err)
+	}
+	ks, err := praetorian.NewKeystore(cfg)
+	if err != nil {
+		t.Fatalf("NewKeystore() failed to create keystore: %v", err)
+	}
+
+	if _, err := ks.Find(praetorian.ActiveKeyID); !errors.Is(err, praetorian.ErrKeystoreSealed) {
+		t.Errorf("Keystore.Find() error = %v, wantErr = %v", err, praetorian.ErrKeystoreSealed)
+	}
+
+	u := ks.(praetorian.Unsealer)
+	status, err := u.Unseal(shares[2])
+	if err != nil {
+		t.Fatalf("Keystore.Unseal() failed to accept share: %v", err)
+	}
+	if !status.Sealed || status.Progress != 1 {
+		t.Errorf("Keystore.Unseal() status = %+v, want sealed with progress 1", status)
+	}
+
+	status, err = u.Unseal(shares[0])
+	if err != nil {
+		t.Fatalf("Keystore.Unseal() failed to accept share: %v", err)
+	}
+	if status.Sealed {
+		t.Errorf("Keystore.Unseal() status = %+v, want unsealed", status)
+	}
+
+	k, err := ks.Find(praetorian.ActiveKeyID)
+	if err != nil {
+		t.Fatalf("Keystore.Find() failed to return key: %v", err)
+	}
+	if k.ID() != "1" {
+		t.Errorf("Keystore.Find() id = %q, want = %q", k.ID(), "1")
+	}
+	if _, err := ks.Find("2"); err != nil {
+		t.Errorf("Keystore.Find() failed to return ECDH key: %v", err)
+	}
+}
+
+func TestKeystore_UnsealErrors(t *testing.T) {
+	t.Setenv(praetorian.EnvKey, testConfig)
+	cfg, err := praetorian.NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig() failed to create config: %v", err)
+	}
+
+	sealed, _, err := praetorian.SealConfig(cfg, 3, 2)
+	if err != nil {
+		t.Fatalf("SealConfig() failed to seal config: %v", err)
+	}
+	_, foreign, err := praetorian.SealConfig(cfg, 3, 2)
+	if err != nil {
+		t.Fatalf("SealConfig() failed to seal config: %v", err)
+	}
+
+	ks, err := praetorian.NewKeystore(sealed)
+	if err != nil {
+		t.Fatalf("NewKeystore() failed to create keystore: %v", err)
+	}
+	u := ks.(praetorian.Unsealer)
+
+	if _, err := u.Unseal([]byte("short")); !errors.Is(err, praetorian.ErrInvalidShare) {
+		t.Errorf("Keystore.Unseal() error = %v, wantErr = %v", err, praetorian.ErrInvalidShare)
+	}
+
+	if _, err := u.Unseal(foreign[0]); err != nil {
+		t.Fatalf("Keystore.Unseal() failed to accept share: %v", err)
+	}
+	status, err := u.Unseal(foreign[1])
+	if !errors.Is(err, praetorian.ErrUnsealFailed) {
+		t.Errorf("Keystore.Unseal() error = %v, wantErr = %v", err, praetorian.ErrUnsealFailed)
+	}
+	if !status.Sealed || status.Progress != 0 {
+		t.Errorf("Keystore.Unseal() status = %+v, want sealed with progress reset", status)
+	}
+}
+
+func TestKeystore_UnsealInvalidState(t *testing.T) {
+	cfg, err := praetorian.ParseConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("ParseConfig() failed: %v", err)
+	}
+	cfg.StateFile = filepath.Join(t.TempDir(), "state.json")
+	sealed, shares, err := praetorian.SealConfig(cfg, 3, 2)
+	if err != nil {
+		t.Fatalf("SealConfig() failed to seal config: %v", err)
+	}
+	ks, err := praetorian.NewKeystore(sealed)
+	if err != nil {
+		t.Fatalf("NewKeystore() failed to create keystore: %v", err)
+	}
+	u := ks.(praetorian.Unsealer)
+
+	unseal := func() error {
+		if _, err := u.Unseal(shares[0]); err != nil {
+			return err
+		}
+		_, err := u.Unseal(shares[1])
+		return err
+	}
+
+	if err := os.WriteFile(cfg.StateFile, []byte("not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := unseal(); !errors.Is(err, praetorian.ErrUnsealFailed) {
+		t.Fatalf("Keystore.Unseal() error = %v, wantErr = %v", err, praetorian.ErrUnsealFailed)
+	}
+	if !u.Sealed() {
+		t.Error("Keystore.Sealed() = false after a failed unseal")
+	}
+	if _, err := ks.Find(praetorian.ActiveKeyID); !errors.Is(err, praetorian.ErrKeystoreSealed) {
+		t.Errorf("Keystore.Find() error = %v after a failed unseal, wantErr = %v", err, praetorian.ErrKeystoreSealed)
+	}
+
+	// once the state file is repaired, a retry succeeds.
+	if err := os.Remove(cfg.StateFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := unseal(); err != nil {
+		t.Fatalf("Keystore.Unseal() retry error = %v", err)
+	}
+	if _, err := ks.Find(praetorian.ActiveKeyID); err != nil {
+		t.Errorf("Keystore.Find() error = %v after unsealing", err)
+	}
+}
@@ -2,12 +2,18 @@ package praetorian
 
 import (
 	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
-	"os"
-	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,72 +22,384 @@ type ErrorResponse struct {
 	Message string `json:"message"`
 }
 
-type server struct {
+// Server serves the wrapping endpoints over HTTP. It can listen by itself with
+// Start, or its Handler can be served or mounted by another program.
+type Server struct {
 	*http.Server
-	keys     KeyFinder
-	mux      *http.ServeMux
-	Shutdown func(context.Context) error
+	keys       KeyFinder
+	mux        *http.ServeMux
+	rateLimits RateLimits
+	anomalies  *AnomalyDetector
+	Shutdown   func(context.Context) error
+
+	addr                string
+	maxBodyBytes        int64
+	timeouts            ServerTimeouts
+	maxHeaderBytes      int
+	maxConns            int
+	shutdownTimeout     time.Duration
+	drainDelay          time.Duration
+	tlsConfig           *tls.Config
+	deprecations        *Deprecations
+	legacySunset        time.Time
+	disableLegacyRoutes bool
+	http2               HTTP2Options
+	draining            atomic.Bool
+	middleware          []Middleware
+	endpoints           []Endpoint
+	adminAuth           AdminAuth
+	socket              *SocketConfig
+	kmipAddr            string
+	kmipTLS             *tls.Config
+	kmsAddr             string
+	kmsAliases          map[string]string
+	listeners           []listener
+}
+
+// listener is a KMIP or AWS KMS server started alongside the HTTP server.
+type listener interface {
+	Shutdown(ctx context.Context) error
+	Close() error
+}
+
+// ServerOption configures optional behaviour of the server.
+type ServerOption func(*Server)
+
+// WithRateLimits limits how quickly each caller can make requests.
+func WithRateLimits(limits RateLimits) ServerOption {
+	return func(s *Server) {
+		s.rateLimits = limits
+	}
+}
+
+// WithAnomalyDetection flags unusual unwrap volumes, calling onEvent for each
+// anomaly when it is not nil.
+func WithAnomalyDetection(cfg AnomalyDetection, onEvent func(AnomalyEvent)) ServerOption {
+	return func(s *Server) {
+		if cfg.Enabled() {
+			s.anomalies = NewAnomalyDetector(cfg, onEvent)
+		}
+	}
 }
 
 // NewServer allows wrapping and unwrapping to occur over a HTTP interface.
-func NewServer(keys KeyFinder) *server {
-	addr := fmt.Sprintf(":%s", port())
+// Nothing listens until Start is called.
+func NewServer(keys KeyFinder, opts ...ServerOption) *Server {
 	mux := http.NewServeMux()
 
-	srv := &server{
-		keys: keys,
-		mux:  mux,
+	srv := &Server{
+		keys:            keys,
+		mux:             mux,
+		addr:            DefaultAddr,
+		maxBodyBytes:    DefaultMaxBodyBytes,
+		shutdownTimeout: DefaultShutdownTimeout,
+		deprecations:    NewDeprecations(),
+	}
+	for _, opt := range opts {
+		opt(srv)
 	}
 	srv.Routes()
 
-	srv.Server = &http.Server{
-		Addr:    addr,
-		Handler: NewLogger(mux),
-	}
+	srv.Server = srv.newHTTPServer(NewRouter(mux))
+	srv.Addr = srv.addr
+	srv.TLSConfig = srv.tlsConfig
 	srv.Shutdown = srv.Server.Shutdown
 
 	return srv
 }
 
+// NewHandler returns the endpoints of a server, with its middleware, without
+// listening for requests.
+func NewHandler(keys KeyFinder, opts ...ServerOption) http.Handler {
+	return NewServer(keys, opts...).Handler
+}
+
+// Mount serves the endpoints of the server on mux below prefix, so that
+// "/kms" serves "/kms/wrap" and "/kms/unwrap".
+func (s *Server) Mount(mux *http.ServeMux, prefix string) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	mux.Handle(prefix+"/", http.StripPrefix(prefix, s.Handler))
+}
+
 // Routes sets up HTTP endpoints and configures the respective handlers.
-func (s *server) Routes() {
-	s.mux.HandleFunc("/wrap", HandleWrap(ActiveKeyID, s.keys))
-	s.mux.HandleFunc("/unwrap", HandleUnwrap(s.keys))
+func (s *Server) Routes() {
+	s.mux.HandleFunc("GET /healthz", HandleHealth())
+	s.mux.HandleFunc("GET /readyz", HandleReady(s.keys, &s.draining))
+	if s.enabled(EndpointWrap) {
+		s.handle("POST /wrap", HandleWrap(ActiveKeyID, s.keys))
+	}
+	if s.enabled(EndpointUnwrap) {
+		s.handle("POST /unwrap", HandleUnwrap(s.keys))
+	}
+	if s.enabled(EndpointPublicKey) {
+		s.handle("GET /publickey/{id}", HandlePublicKey(s.keys))
+	}
+	if s.enabled(EndpointRPC) {
+		s.mux.HandleFunc("POST "+RPCServicePath, HandleRPC(ActiveKeyID, s.keys))
+	}
+	if s.enabled(EndpointTransit) {
+		// Vault accepts both POST and PUT for every transit operation.
+		transit := HandleTransit(ActiveKeyID, s.keys)
+		for _, method := range []string{http.MethodPost, http.MethodPut} {
+			s.mux.HandleFunc(method+" /v1/transit/{op}/{name}", transit)
+			s.mux.HandleFunc(method+" /v1/transit/datakey/{type}/{name}", transit)
+		}
+	}
+	if sf, ok := s.keys.(SignerFinder); ok && s.enabled(EndpointSign) {
+		s.handle("POST /sign", HandleSign(ActiveKeyID, sf))
+		s.handle("POST /verify", HandleVerify(sf))
+		s.handle("GET /keys/{id}/public", HandleJWK(sf, s.keys))
+	}
+	if mf, ok := s.keys.(MACFinder); ok && s.enabled(EndpointMAC) {
+		s.handle("POST /mac", HandleMAC(ActiveKeyID, mf))
+		s.handle("POST /mac/verify", HandleMACVerify(mf))
+	}
+	if u, ok := s.keys.(Unsealer); ok && s.enabled(EndpointUnseal) {
+		s.handle("POST /unseal", HandleUnseal(u))
+	}
+	if !s.enabled(EndpointAdmin) {
+		return
+	}
+	if !s.adminAuth.Enabled() {
+		log.Println("admin endpoints disabled: no admin token or UIDs are configured")
+		return
+	}
+	s.handle("POST /admin/keys/export", s.admin(HandleExportKey(s.keys)))
+	s.mux.HandleFunc("GET "+APIVersion+"/admin/deprecations", s.admin(HandleDeprecations(s.deprecations)))
+	if s.anomalies != nil {
+		s.handle("GET /admin/lockouts", s.admin(HandleLockouts(s.anomalies)))
+		s.handle("DELETE /admin/lockouts/{caller}", s.admin(HandleClearLockout(s.anomalies)))
+	}
+	if imp, ok := s.keys.(KeyImporter); ok {
+		// the transfer key only exists in memory for the lifetime of the process.
+		if priv, err := ecdh.P256().GenerateKey(rand.Reader); err == nil {
+			s.handle("GET /admin/keys/import", s.admin(HandleImportPublicKey(priv)))
+			s.handle("POST /admin/keys/import", s.admin(HandleImportKey(imp, priv)))
+		} else {
+			log.Println("key import disabled:", err)
+		}
+	}
+}
+
+// admin only allows the configured admin callers to use the handler.
+func (s *Server) admin(h http.HandlerFunc) http.HandlerFunc {
+	return NewAdminAuth(s.adminAuth, h).ServeHTTP
+}
+
+// handle registers the handler below APIVersion, and at the unversioned
+// pattern as a deprecated alias unless legacy routes are disabled.
+func (s *Server) handle(pattern string, h http.HandlerFunc) {
+	method, path, _ := strings.Cut(pattern, " ")
+	s.mux.HandleFunc(method+" "+APIVersion+path, h)
+	if s.disableLegacyRoutes {
+		return
+	}
+	dep := LegacyDeprecation
+	dep.Sunset = s.legacySunset
+	dep.Successor = APIVersion + path
+	s.mux.Handle(pattern, s.deprecations.Deprecate(pattern, dep, h))
 }
 
-// Start launches the server which listens for HTTP requests.
-func (s *server) Start() error {
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt)
+// Start listens for HTTP requests, on a Unix domain socket when one is
+// configured, along with the KMIP and AWS KMS listeners when they are
+// configured, and serves them until ctx is cancelled.
+func (s *Server) Start(ctx context.Context) error {
+	// the additional listeners are shut down by Serve, or closed here when
+	// the HTTP listener cannot be created.
+	for _, listen := range []func() (listener, error){s.listenKMIP, s.listenKMS} {
+		l, err := listen()
+		if err != nil {
+			s.closeListeners()
+			return err
+		}
+		if l != nil {
+			s.listeners = append(s.listeners, l)
+		}
+	}
+
+	var ln net.Listener
+	var err error
+	if s.socket != nil {
+		ln, err = ListenUnix(s.socket)
+	} else {
+		ln, err = net.Listen("tcp", s.Addr)
+	}
+	if err != nil {
+		s.closeListeners()
+		return fmt.Errorf("%w: %w", ErrServe, err)
+	}
+
+	if s.socket != nil {
+		log.Printf("listening on socket %s...\n", s.socket.Path)
+	} else {
+		log.Printf("listening on %s...\n", s.Addr)
+	}
+	return s.Serve(ctx, ln)
+}
 
+// Serve accepts connections on ln, using TLS when it is configured, until ctx
+// is cancelled, then stops being ready, keeps serving for the drain delay and
+// waits for in-flight requests to complete. The KMIP and AWS KMS listeners
+// started by Start are shut down along with the HTTP server, within the same
+// shutdown timeout. Errors from the listener are wrapped in ErrServe and
+// errors from shutting down in ErrShutdown. The listener is closed on return.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	served := make(chan error, 1)
 	go func() {
-		log.Printf("listening on port %s...\n", port())
-		if err := s.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Println("server error:", err)
+		if s.TLSConfig != nil {
+			served <- s.Server.ServeTLS(s.limitConns(ln), "", "")
+			return
 		}
+		served <- s.Server.Serve(s.limitConns(ln))
 	}()
 
-	<-stop
-	log.Println("performing graceful shutdown...")
+	select {
+	case err := <-served:
+		s.closeListeners()
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return fmt.Errorf("%w: %w", ErrServe, err)
+	case <-ctx.Done():
+		log.Println("performing graceful shutdown...")
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	// load balancers stop sending requests once the server is no longer
+	// ready, so requests which are already on their way can still be served.
+	s.draining.Store(true)
+	if s.drainDelay > 0 {
+		log.Printf("draining connections for %s...\n", s.drainDelay)
+		time.Sleep(s.drainDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.shutdownTimeout)
 	defer cancel()
 
-	if err := s.Shutdown(ctx); err != nil {
+	if err := s.shutdown(ctx); err != nil {
 		log.Println("forced shutdown:", err)
-		return err
+		ln.Close()
+		s.closeListeners()
+		return fmt.Errorf("%w: %w", ErrShutdown, err)
 	}
 
 	log.Println("server shutdown successful")
 	return nil
 }
 
-func port() string {
-	val := os.Getenv("PORT")
-	if val == "" {
-		val = "3000"
+// shutdown gracefully stops the HTTP server and the listeners started by
+// Start at the same time, so they share the shutdown timeout.
+func (s *Server) shutdown(ctx context.Context) error {
+	errs := make([]error, len(s.listeners)+1)
+	var wg sync.WaitGroup
+	for i, l := range s.listeners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i+1] = l.Shutdown(ctx)
+		}()
+	}
+	errs[0] = s.Shutdown(ctx)
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// closeListeners closes the listeners started by Start.
+func (s *Server) closeListeners() {
+	for _, l := range s.listeners {
+		l.Close()
+	}
+	s.listeners = nil
+}
+
+// listenKMIP starts the KMIP listener when it is configured.
+func (s *Server) listenKMIP() (listener, error) {
+	addr, cfg := s.kmipAddr, s.kmipTLS
+	if addr == "" {
+		return nil, nil
+	}
+	if cfg == nil || cfg.ClientCAs == nil || cfg.ClientAuth != tls.RequireAndVerifyClientCert {
+		return nil, ErrKMIPClientCARequired
+	}
+	ln, err := tls.Listen("tcp", addr, cfg)
+	if err != nil {
+		return nil, err
+	}
+	kmip := NewKMIPServer(s.keys, WithKMIPRateLimits(s.rateLimits), WithKMIPAnomalyDetector(s.anomalies))
+	go func() {
+		log.Printf("listening for KMIP on %s...\n", addr)
+		if err := kmip.Serve(s.limitConns(ln)); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Println("kmip server error:", err)
+		}
+	}()
+	return kmip, nil
+}
+
+// listenKMS starts the AWS KMS compatible listener when it is configured.
+func (s *Server) listenKMS() (listener, error) {
+	addr := s.kmsAddr
+	if addr == "" {
+		return nil, nil
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	kms := s.newHTTPServer(HandleKMS(s.keys, s.kmsAliases))
+	go func() {
+		log.Printf("listening for KMS on %s...\n", addr)
+		if err := kms.Serve(s.limitConns(ln)); err != nil && err != http.ErrServerClosed {
+			log.Println("kms server error:", err)
+		}
+	}()
+	return kms, nil
+}
+
+// newHTTPServer returns a server for the handler with the configured
+// timeouts and middleware.
+func (s *Server) newHTTPServer(h http.Handler) *http.Server {
+	t := s.timeouts.resolve()
+	maxHeaderBytes := s.maxHeaderBytes
+	if maxHeaderBytes <= 0 {
+		maxHeaderBytes = DefaultMaxHeaderBytes
+	}
+	maxBodyBytes := s.maxBodyBytes
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = DefaultMaxBodyBytes
+	}
+	for i := len(s.middleware) - 1; i >= 0; i-- {
+		h = s.middleware[i](h)
+	}
+	return &http.Server{
+		Handler:           NewLogger(s.limit(NewRequestTimeout(t.Request, NewBodyLimit(maxBodyBytes, h)))),
+		ReadHeaderTimeout: t.ReadHeader,
+		ReadTimeout:       t.Read,
+		WriteTimeout:      t.Write,
+		IdleTimeout:       t.Idle,
+		MaxHeaderBytes:    maxHeaderBytes,
+		ConnContext:       connContext,
+		Protocols:         s.http2.protocols(),
+		HTTP2:             &http.HTTP2Config{MaxConcurrentStreams: s.http2.MaxConcurrentStreams},
+	}
+}
+
+// limitConns applies the connection limit to the listener.
+func (s *Server) limitConns(ln net.Listener) net.Listener {
+	if s.maxConns <= 0 {
+		return ln
+	}
+	return LimitListener(ln, s.maxConns)
+}
+
+// limit applies the configured rate limits and anomaly detection to the
+// handler.
+func (s *Server) limit(h http.Handler) http.Handler {
+	if s.anomalies != nil {
+		h = s.anomalies.Middleware(h)
+	}
+	if !s.rateLimits.Enabled() {
+		return h
 	}
-	return val
+	return NewRateLimiter(s.rateLimits, h)
 }
 
 func jsonResponse(w http.ResponseWriter, status int, data any) {
@@ -3,9 +3,16 @@ package praetorian_test
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -14,106 +21,299 @@ import (
 )
 
 func TestNewServer(t *testing.T) {
-	t.Setenv(praetorian.EnvKey, testConfig)
-	t.Setenv("PORT", "8080")
+	tests := []struct {
+		name     string
+		options  praetorian.ServerOptions
+		opts     []praetorian.ServerOption
+		wantAddr string
+	}{
+		{
+			name:     "default address",
+			wantAddr: praetorian.DefaultAddr,
+		},
+		{
+			name:     "address option",
+			opts:     []praetorian.ServerOption{praetorian.WithAddr("127.0.0.1:8080")},
+			wantAddr: "127.0.0.1:8080",
+		},
+		{
+			name:     "address from options",
+			options:  praetorian.ServerOptions{Addr: ":8080"},
+			wantAddr: ":8080",
+		},
+		{
+			name:     "address option after options",
+			options:  praetorian.ServerOptions{Addr: ":8080"},
+			opts:     []praetorian.ServerOption{praetorian.WithAddr("127.0.0.1:9090")},
+			wantAddr: "127.0.0.1:9090",
+		},
+	}
 
-	cfg, err := praetorian.NewConfig()
-	if err != nil {
-		t.Errorf("NewConfig() failed to create config: %v", err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := praetorian.NewServer(&MockKeystore{}, append([]praetorian.ServerOption{praetorian.WithOptions(tt.options)}, tt.opts...)...)
+			if srv.Server.Addr != tt.wantAddr {
+				t.Errorf("Server.Addr got = %q, wantAddr = %q", srv.Addr, tt.wantAddr)
+			}
+		})
+	}
+}
+
+func TestServer_Mount(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	cfg := &praetorian.Config{
+		ActiveKeyID: "1",
+		RootKeys:    map[string][]byte{"1": make([]byte, praetorian.RootKeyLength)},
 	}
 	ks, err := praetorian.NewKeystore(cfg)
 	if err != nil {
-		t.Errorf("NewKeystore() failed to create keystore: %v", err)
+		t.Fatalf("NewKeystore() error = %v", err)
 	}
-	srv := praetorian.NewServer(ks)
 
-	wantAddr := ":8080"
-	if srv.Server.Addr != wantAddr {
-		t.Errorf("Server.Addr got = %q, wantAddr = %q", srv.Addr, wantAddr)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	praetorian.NewServer(ks).Mount(mux, "/kms/")
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+	}{
+		{
+			name:       "mounted endpoint",
+			path:       "/kms/wrap",
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "unmounted endpoint",
+			path:       "/wrap",
+			wantStatus: http.StatusTeapot,
+		},
 	}
-}
 
-func TestServer_StartGracefulShutdown(t *testing.T) {
-	t.Setenv(praetorian.EnvKey, testConfig)
-	t.Setenv("PORT", "8080")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(`{"value": "keep it secret, keep it safe"}`))
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("ServeHTTP() status = %d, wantStatus = %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
 
+func TestServer_ServeGracefulShutdown(t *testing.T) {
 	var buff bytes.Buffer
 	log.SetOutput(&buff)
-	defer log.SetOutput(nil)
+	defer log.SetOutput(os.Stderr)
 
-	cfg, err := praetorian.NewConfig()
-	if err != nil {
-		t.Errorf("NewConfig() failed to create config: %v", err)
-	}
-	ks, err := praetorian.NewKeystore(cfg)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
-		t.Errorf("NewKeystore() failed to create keystore: %v", err)
+		t.Fatal(err)
 	}
-	srv := praetorian.NewServer(ks)
+	srv := praetorian.NewServer(&MockKeystore{})
 
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
 	go func() {
-		_ = srv.Start()
+		done <- srv.Serve(ctx, ln)
 	}()
 
-	time.Sleep(500 * time.Millisecond)
-	p, err := os.FindProcess(os.Getpid())
+	res, err := http.Get("http://" + ln.Addr().String() + "/healthz")
 	if err != nil {
-		t.Errorf("os.FindProcess() failed to return the current process: %v", err)
+		t.Fatalf("GET /healthz error = %v", err)
 	}
+	res.Body.Close()
 
-	p.Signal(os.Interrupt)
-	time.Sleep(500 * time.Millisecond)
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Server.Serve() error = %v", err)
+	}
 	out := buff.String()
 
 	wantShutdown := "performing graceful shutdown"
 	if !strings.Contains(out, wantShutdown) {
-		t.Errorf("Server.Start() log = %q, wantShutdown = %q", out, wantShutdown)
+		t.Errorf("Server.Serve() log = %q, wantShutdown = %q", out, wantShutdown)
 	}
 
 	wantSuccess := "server shutdown successful"
 	if !strings.Contains(out, wantSuccess) {
-		t.Errorf("Server.Start() log = %q, wantSuccess = %q", out, wantSuccess)
+		t.Errorf("Server.Serve() log = %q, wantSuccess = %q", out, wantSuccess)
 	}
 }
 
-func TestServer_StartForcedShutdown(t *testing.T) {
-	t.Setenv(praetorian.EnvKey, testConfig)
-	t.Setenv("PORT", "8080")
-
+func TestServer_ServeForcedShutdown(t *testing.T) {
 	var buff bytes.Buffer
 	log.SetOutput(&buff)
-	defer log.SetOutput(nil)
+	defer log.SetOutput(os.Stderr)
 
-	cfg, err := praetorian.NewConfig()
-	if err != nil {
-		t.Errorf("NewConfig() failed to create config: %v", err)
-	}
-	ks, err := praetorian.NewKeystore(cfg)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
-		t.Errorf("NewKeystore() failed to create keystore: %v", err)
+		t.Fatal(err)
 	}
-
-	srv := praetorian.NewServer(ks)
+	srv := praetorian.NewServer(&MockKeystore{})
 	srv.Shutdown = func(ctx context.Context) error {
 		return fmt.Errorf("mock forced shutdown error")
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = srv.Serve(ctx, ln)
+	if !errors.Is(err, praetorian.ErrShutdown) {
+		t.Errorf("Server.Serve() error = %v, wantErr = %v", err, praetorian.ErrShutdown)
+	}
+
+	wantShutdown := "forced shutdown"
+	if out := buff.String(); !strings.Contains(out, wantShutdown) {
+		t.Errorf("Server.Serve() log = %q, wantShutdown = %q", out, wantShutdown)
+	}
+}
+
+func TestServer_ServeDrain(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := praetorian.NewServer(&MockKeystore{}, praetorian.WithDrainDelay(500*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
 	go func() {
-		_ = srv.Start()
+		done <- srv.Serve(ctx, ln)
 	}()
 
-	time.Sleep(500 * time.Millisecond)
-	p, err := os.FindProcess(os.Getpid())
+	ready := func() int {
+		res, err := http.Get("http://" + ln.Addr().String() + "/readyz")
+		if err != nil {
+			t.Fatalf("GET /readyz error = %v", err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+	if got := ready(); got != http.StatusOK {
+		t.Errorf("GET /readyz status = %d, wantStatus = %d", got, http.StatusOK)
+	}
+
+	cancel()
+	time.Sleep(200 * time.Millisecond)
+	if got := ready(); got != http.StatusServiceUnavailable {
+		t.Errorf("GET /readyz status = %d while draining, wantStatus = %d", got, http.StatusServiceUnavailable)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Server.Serve() error = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Server.Serve() did not return after the context was cancelled")
+	}
+}
+
+func TestServer_ServeListenerError(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
-		t.Errorf("os.FindProcess() failed to return the current process: %v", err)
+		t.Fatal(err)
 	}
+	ln.Close()
 
-	p.Signal(os.Interrupt)
-	time.Sleep(500 * time.Millisecond)
-	out := buff.String()
+	err = praetorian.NewServer(&MockKeystore{}).Serve(context.Background(), ln)
+	if !errors.Is(err, praetorian.ErrServe) {
+		t.Errorf("Server.Serve() error = %v, wantErr = %v", err, praetorian.ErrServe)
+	}
+}
 
-	wantShutdown := "forced shutdown"
-	if !strings.Contains(out, wantShutdown) {
-		t.Errorf("Server.Start() log = %q, wantShutdown = %q", out, wantShutdown)
+func TestServer_StartListenError(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	err = praetorian.NewServer(&MockKeystore{}, praetorian.WithAddr(ln.Addr().String())).Start(context.Background())
+	if !errors.Is(err, praetorian.ErrServe) {
+		t.Errorf("Server.Start() error = %v, wantErr = %v", err, praetorian.ErrServe)
+	}
+}
+
+func TestServer_StartListeners(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kmsAddr := ln.Addr().String()
+	ln.Close()
+	path := filepath.Join(t.TempDir(), "praetorian.sock")
+
+	srv := praetorian.NewServer(newTestKeystore(t, testConfig), praetorian.WithOptions(praetorian.ServerOptions{
+		Socket:  &praetorian.SocketConfig{Path: path, Mode: 0o600, UID: -1, GID: -1},
+		KMSAddr: kmsAddr,
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Start(ctx) }()
+	defer cancel()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	get := func(c *http.Client, url string) (*http.Response, error) {
+		for range 50 {
+			res, err := c.Get(url)
+			if err == nil {
+				return res, nil
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return c.Get(url)
+	}
+	res, err := get(client, "http://praetorian/healthz")
+	if err != nil {
+		t.Fatalf("GET /healthz over the socket error = %v", err)
+	}
+	res.Body.Close()
+
+	res, err = get(http.DefaultClient, "http://"+kmsAddr+"/")
+	if err != nil {
+		t.Fatalf("KMS listener error = %v", err)
+	}
+	res.Body.Close()
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Server.Start() error = %v", err)
+	}
+	if c, err := net.Dial("tcp", kmsAddr); err == nil {
+		c.Close()
+		t.Error("KMS listener is still accepting connections after shutdown")
+	}
+}
+
+func TestServer_StartKMIPWithoutClientCA(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	err := praetorian.NewServer(&MockKeystore{}, praetorian.WithKMIP("127.0.0.1:0", &tls.Config{})).Start(context.Background())
+	if !errors.Is(err, praetorian.ErrKMIPClientCARequired) {
+		t.Errorf("Server.Start() error = %v, wantErr = %v", err, praetorian.ErrKMIPClientCARequired)
 	}
 }
@@ -0,0 +1,116 @@
+package praetorian
+
+import (
+	"crypto/rand"
+)
+
+// SplitSecret divides the secret into n shares, any k of which can be
+// combined to reconstruct it. Each share carries its x coordinate in the
+// final byte.
+func SplitSecret(secret []byte, n, k int) ([][]byte, error) {
+	if len(secret) == 0 {
+		return nil, ErrInvalidShare
+	}
+	if n < k || n > 255 {
+		return nil, ErrInvalidShareCount
+	}
+	if k < 2 {
+		return nil, ErrInvalidThreshold
+	}
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][len(secret)] = byte(i + 1)
+	}
+
+	coeffs := make([]byte, k)
+	for idx, b := range secret {
+		coeffs[0] = b
+		if _, err := rand.Read(coeffs[1:]); err != nil {
+			return nil, err
+		}
+		for _, s := range shares {
+			s[idx] = gfEval(coeffs, s[len(secret)])
+		}
+	}
+	clear(coeffs)
+	return shares, nil
+}
+
+// CombineShares reconstructs a secret from shares produced by SplitSecret.
+// Supplying fewer shares than the threshold yields an incorrect secret rather
+// than an error, so callers must verify the result.
+func CombineShares(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, ErrInvalidShareCount
+	}
+
+	size := len(shares[0])
+	if size < 2 {
+		return nil, ErrInvalidShare
+	}
+	seen := make(map[byte]bool, len(shares))
+	for _, s := range shares {
+		if len(s) != size {
+			return nil, ErrInvalidShare
+		}
+		x := s[size-1]
+		if x == 0 || seen[x] {
+			return nil, ErrInvalidShare
+		}
+		seen[x] = true
+	}
+
+	secret := make([]byte, size-1)
+	for i, si := range shares {
+		xi := si[size-1]
+		basis := byte(1)
+		for j, sj := range shares {
+			if i == j {
+				continue
+			}
+			xj := sj[size-1]
+			basis = gfMul(basis, gfMul(xj, gfInv(xi^xj)))
+		}
+		for idx := range secret {
+			secret[idx] ^= gfMul(si[idx], basis)
+		}
+	}
+	return secret, nil
+}
+
+// gfEval evaluates the polynomial with the given coefficients at x.
+func gfEval(coeffs []byte, x byte) byte {
+	var y byte
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		y = gfMul(y, x) ^ coeffs[i]
+	}
+	return y
+}
+
+// gfMul multiplies two elements of GF(2^8) using the AES polynomial.
+func gfMul(a, b byte) byte {
+	var p byte
+	for range 8 {
+		if b&1 == 1 {
+			p ^= a
+		}
+		hi := a & 0x80
+		a <<= 1
+		if hi != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return p
+}
+
+// gfInv returns the multiplicative inverse of a in GF(2^8), computed as a^254.
+func gfInv(a byte) byte {
+	r := byte(1)
+	for range 254 {
+		r = gfMul(r, a)
+	}
+	return r
+}
@@ -0,0 +1,113 @@
+package praetorian_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+func TestSplitSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		n       int
+		k       int
+		wantErr error
+	}{
+		{
+			name:    "threshold too low",
+			n:       3,
+			k:       1,
+			wantErr: praetorian.ErrInvalidThreshold,
+		},
+		{
+			name:    "threshold above share count",
+			n:       2,
+			k:       3,
+			wantErr: praetorian.ErrInvalidShareCount,
+		},
+		{
+			name:    "too many shares",
+			n:       256,
+			k:       3,
+			wantErr: praetorian.ErrInvalidShareCount,
+		},
+		{
+			name:    "split succeeds",
+			n:       5,
+			k:       3,
+			wantErr: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shares, err := praetorian.SplitSecret([]byte("a secret never to be told"), tt.n, tt.k)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SplitSecret() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+			if err == nil && len(shares) != tt.n {
+				t.Errorf("SplitSecret() shares = %d, want = %d", len(shares), tt.n)
+			}
+		})
+	}
+}
+
+func TestCombineShares(t *testing.T) {
+	secret := []byte("a secret never to be told")
+	shares, err := praetorian.SplitSecret(secret, 5, 3)
+	if err != nil {
+		t.Fatalf("SplitSecret() failed to split secret: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		shares    [][]byte
+		wantMatch bool
+		wantErr   error
+	}{
+		{
+			name:      "threshold shares",
+			shares:    [][]byte{shares[4], shares[0], shares[2]},
+			wantMatch: true,
+		},
+		{
+			name:      "all shares",
+			shares:    shares,
+			wantMatch: true,
+		},
+		{
+			name:      "below threshold",
+			shares:    shares[:2],
+			wantMatch: false,
+		},
+		{
+			name:    "single share",
+			shares:  shares[:1],
+			wantErr: praetorian.ErrInvalidShareCount,
+		},
+		{
+			name:    "duplicate share",
+			shares:  [][]byte{shares[0], shares[0], shares[1]},
+			wantErr: praetorian.ErrInvalidShare,
+		},
+		{
+			name:    "mismatched length",
+			shares:  [][]byte{shares[0], shares[1][1:]},
+			wantErr: praetorian.ErrInvalidShare,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := praetorian.CombineShares(tt.shares)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CombineShares() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+			if err == nil && bytes.Equal(got, secret) != tt.wantMatch {
+				t.Errorf("CombineShares() got = %q, wantMatch = %v", got, tt.wantMatch)
+			}
+		})
+	}
+}
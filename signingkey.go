@@ -0,0 +1,140 @@
+package praetorian
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"math/big"
+)
+
+const (
+	SigningEdDSA = "EdDSA"
+	SigningES256 = "ES256"
+)
+
+// SigningKey produces and checks signatures without exposing key material.
+// ECDSA signatures are the fixed size r || s encoding used by JWS.
+type SigningKey interface {
+	ID() string
+	Algorithm() string
+	Public() crypto.PublicKey
+	Sign(data []byte) ([]byte, error)
+	Verify(data, sig []byte) error
+}
+
+// SignerFinder retrieves signing keys from an underlying keystore.
+type SignerFinder interface {
+	FindSigner(id string) (SigningKey, error)
+}
+
+type ed25519Key struct {
+	id   string
+	priv ed25519.PrivateKey
+}
+
+// ID is a getter which returns the keys unique identifier.
+func (k *ed25519Key) ID() string {
+	return k.id
+}
+
+// Algorithm returns the JWS algorithm name of the key.
+func (k *ed25519Key) Algorithm() string {
+	return SigningEdDSA
+}
+
+// Public returns the public half of the key.
+func (k *ed25519Key) Public() crypto.PublicKey {
+	return k.priv.Public()
+}
+
+// Sign the given data using the private key.
+func (k *ed25519Key) Sign(d []byte) ([]byte, error) {
+	return ed25519.Sign(k.priv, d), nil
+}
+
+// Verify the signature of the given data using the public key.
+func (k *ed25519Key) Verify(d, sig []byte) error {
+	if !ed25519.Verify(k.priv.Public().(ed25519.PublicKey), d, sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+type ecdsaKey struct {
+	id   string
+	priv *ecdsa.PrivateKey
+}
+
+// ID is a getter which returns the keys unique identifier.
+func (k *ecdsaKey) ID() string {
+	return k.id
+}
+
+// Algorithm returns the JWS algorithm name of the key.
+func (k *ecdsaKey) Algorithm() string {
+	return SigningES256
+}
+
+// Public returns the public half of the key.
+func (k *ecdsaKey) Public() crypto.PublicKey {
+	return &k.priv.PublicKey
+}
+
+// Sign the SHA-256 digest of the given data using the private key.
+func (k *ecdsaKey) Sign(d []byte) ([]byte, error) {
+	digest := sha256.Sum256(d)
+	r, s, err := ecdsa.Sign(rand.Reader, k.priv, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return sig, nil
+}
+
+// Verify the signature of the given data using the public key.
+func (k *ecdsaKey) Verify(d, sig []byte) error {
+	if len(sig) != 64 {
+		return ErrInvalidSignature
+	}
+	digest := sha256.Sum256(d)
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	if !ecdsa.Verify(&k.priv.PublicKey, digest[:], r, s) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func validSigningAlgorithm(alg string) bool {
+	return alg == SigningEdDSA || alg == SigningES256
+}
+
+func (c SigningKeyConfig) signingKey(id string) (SigningKey, error) {
+	switch c.Algorithm {
+	case SigningEdDSA:
+		return &ed25519Key{id, ed25519.NewKeyFromSeed(c.PrivateKey)}, nil
+	case SigningES256:
+		// validates the scalar and derives the uncompressed public point.
+		ek, err := ecdh.P256().NewPrivateKey(c.PrivateKey)
+		if err != nil {
+			return nil, ErrInvalidRootKey
+		}
+		pub := ek.PublicKey().Bytes()
+		priv := &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(pub[1:33]),
+				Y:     new(big.Int).SetBytes(pub[33:]),
+			},
+			D: new(big.Int).SetBytes(c.PrivateKey),
+		}
+		return &ecdsaKey{id, priv}, nil
+	}
+	return nil, ErrUnsupportedAlgorithm
+}
@@ -0,0 +1,77 @@
+package praetorian_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+const (
+	testSigningConfig = `{"activeKeyId": "1", "rootKeys": {"1": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}, "activeSigningKeyId": "4", "signingKeys": {"4": {"algorithm": "EdDSA", "privateKey": "BPK//lj6hlpjuA5gPZo19OIjUDgnIQAAAAAAAAAAAAA="}, "5": {"algorithm": "ES256", "privateKey": "kSRFQxepULO9UC5SL5pA/mXjbI1GXu9ha2T0yPr3scU="}}}`
+)
+
+func TestSigningKey_SignVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		wantAlg string
+		wantLen int
+	}{
+		{
+			name:    "active signing key",
+			id:      praetorian.ActiveKeyID,
+			wantAlg: praetorian.SigningEdDSA,
+			wantLen: 64,
+		},
+		{
+			name:    "ed25519",
+			id:      "4",
+			wantAlg: praetorian.SigningEdDSA,
+			wantLen: 64,
+		},
+		{
+			name:    "ecdsa p256",
+			id:      "5",
+			wantAlg: praetorian.SigningES256,
+			wantLen: 64,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signers := newTestKeystore(t, testSigningConfig).(praetorian.SignerFinder)
+			k, err := signers.FindSigner(tt.id)
+			if err != nil {
+				t.Fatalf("Keystore.FindSigner() failed to return key: %v", err)
+			}
+			if k.Algorithm() != tt.wantAlg {
+				t.Errorf("SigningKey.Algorithm() = %q, wantAlg = %q", k.Algorithm(), tt.wantAlg)
+			}
+
+			data := []byte("a message never to be forged")
+			sig, err := k.Sign(data)
+			if err != nil {
+				t.Fatalf("SigningKey.Sign() failed to sign data: %v", err)
+			}
+			if len(sig) != tt.wantLen {
+				t.Errorf("SigningKey.Sign() length = %d, wantLen = %d", len(sig), tt.wantLen)
+			}
+			if err := k.Verify(data, sig); err != nil {
+				t.Errorf("SigningKey.Verify() failed to verify signature: %v", err)
+			}
+
+			sig[0] ^= 1
+			if err := k.Verify(data, sig); !errors.Is(err, praetorian.ErrInvalidSignature) {
+				t.Errorf("SigningKey.Verify() error = %v, wantErr = %v", err, praetorian.ErrInvalidSignature)
+			}
+		})
+	}
+}
+
+func TestKeystore_FindSigner(t *testing.T) {
+	signers := newTestKeystore(t, testSigningConfig).(praetorian.SignerFinder)
+	if _, err := signers.FindSigner("1"); !errors.Is(err, praetorian.ErrSigningKeyNotFound) {
+		t.Errorf("Keystore.FindSigner() error = %v, wantErr = %v", err, praetorian.ErrSigningKeyNotFound)
+	}
+}
@@ -0,0 +1,238 @@
+package praetorian
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"os/user"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Environment variables which configure the Unix domain socket listener.
+const (
+	EnvSocket      = "PRAETORIAN_SOCKET"
+	EnvSocketMode  = "PRAETORIAN_SOCKET_MODE"
+	EnvSocketOwner = "PRAETORIAN_SOCKET_OWNER"
+	EnvPeerUIDs    = "PRAETORIAN_PEER_UIDS"
+	EnvPeerGIDs    = "PRAETORIAN_PEER_GIDS"
+)
+
+// DefaultSocketMode only allows the owner of the socket to connect.
+const DefaultSocketMode fs.FileMode = 0o600
+
+var (
+	ErrInvalidSocketConfig        = errors.New("invalid socket configuration")
+	ErrPeerCredentialsUnsupported = errors.New("peer credentials are not supported on this platform")
+)
+
+// SocketConfig describes a Unix domain socket to listen on in place of a TCP
+// port. When AllowUIDs or AllowGIDs are set, connections are only accepted
+// from processes running as one of the users or groups.
+type SocketConfig struct {
+	Path      string
+	Mode      fs.FileMode
+	UID       int
+	GID       int
+	AllowUIDs []uint32
+	AllowGIDs []uint32
+}
+
+// PeerCredentials identify the process on the other end of a Unix domain
+// socket connection.
+type PeerCredentials struct {
+	PID int32
+	UID uint32
+	GID uint32
+}
+
+type peerCredentialsKey struct{}
+
+// PeerCredentialsFromContext returns the credentials of the process which
+// made the request, when it was received over a Unix domain socket.
+func PeerCredentialsFromContext(ctx context.Context) (PeerCredentials, bool) {
+	p, ok := ctx.Value(peerCredentialsKey{}).(PeerCredentials)
+	return p, ok
+}
+
+// SocketConfigFromEnv returns the socket configuration from the environment,
+// or nil when no socket path is set.
+func SocketConfigFromEnv() (*SocketConfig, error) {
+	return ParseSocketConfig(os.Getenv)
+}
+
+// ParseSocketConfig returns the socket configuration from the variables
+// returned by getenv, or nil when no socket path is set.
+func ParseSocketConfig(getenv func(string) string) (*SocketConfig, error) {
+	path := getenv(EnvSocket)
+	if path == "" {
+		return nil, nil
+	}
+	cfg := &SocketConfig{Path: path, Mode: DefaultSocketMode, UID: -1, GID: -1}
+
+	if val := getenv(EnvSocketMode); val != "" {
+		mode, err := strconv.ParseUint(val, 8, 32)
+		if err != nil || mode > 0o777 {
+			return nil, fmt.Errorf("%w: mode %q", ErrInvalidSocketConfig, val)
+		}
+		cfg.Mode = fs.FileMode(mode)
+	}
+
+	if val := getenv(EnvSocketOwner); val != "" {
+		owner, group, _ := strings.Cut(val, ":")
+		var err error
+		if cfg.UID, err = lookupID(owner, true); err != nil {
+			return nil, err
+		}
+		if cfg.GID, err = lookupID(group, false); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	if cfg.AllowUIDs, err = parseIDs(getenv(EnvPeerUIDs)); err != nil {
+		return nil, err
+	}
+	if cfg.AllowGIDs, err = parseIDs(getenv(EnvPeerGIDs)); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// ListenUnix listens on the socket described by the config. A stale socket
+// left at the path is removed, but any other kind of file is an error.
+func ListenUnix(cfg *SocketConfig) (net.Listener, error) {
+	restricted := len(cfg.AllowUIDs) > 0 || len(cfg.AllowGIDs) > 0
+	if restricted && !peerCredentialsSupported {
+		return nil, ErrPeerCredentialsUnsupported
+	}
+
+	if fi, err := os.Lstat(cfg.Path); err == nil {
+		if fi.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("%w: %s exists and is not a socket", ErrInvalidSocketConfig, cfg.Path)
+		}
+		if err := os.Remove(cfg.Path); err != nil {
+			return nil, err
+		}
+	}
+
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: cfg.Path, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	mode := cfg.Mode
+	if mode == 0 {
+		mode = DefaultSocketMode
+	}
+	if err := os.Chmod(cfg.Path, mode); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	if cfg.UID >= 0 || cfg.GID >= 0 {
+		if err := os.Chown(cfg.Path, cfg.UID, cfg.GID); err != nil {
+			ln.Close()
+			return nil, err
+		}
+	}
+	return &unixListener{UnixListener: ln, cfg: cfg, restricted: restricted}, nil
+}
+
+type unixListener struct {
+	*net.UnixListener
+	cfg        *SocketConfig
+	restricted bool
+}
+
+// peerConn carries the credentials of the connecting process so that they
+// can be added to the context of each request.
+type peerConn struct {
+	net.Conn
+	creds PeerCredentials
+}
+
+// Accept returns the next connection from an allowed peer, closing any
+// connections from other processes.
+func (l *unixListener) Accept() (net.Conn, error) {
+	for {
+		c, err := l.AcceptUnix()
+		if err != nil {
+			return nil, err
+		}
+		creds, err := peerCredentials(c)
+		if err != nil {
+			if l.restricted {
+				log.Println("rejected connection:", err)
+				c.Close()
+				continue
+			}
+			return c, nil
+		}
+		if l.restricted && !l.allowed(creds) {
+			log.Printf("rejected connection from uid %d gid %d\n", creds.UID, creds.GID)
+			c.Close()
+			continue
+		}
+		return &peerConn{Conn: c, creds: creds}, nil
+	}
+}
+
+func (l *unixListener) allowed(p PeerCredentials) bool {
+	return slices.Contains(l.cfg.AllowUIDs, p.UID) || slices.Contains(l.cfg.AllowGIDs, p.GID)
+}
+
+// connContext is used as the ConnContext of the HTTP server.
+func connContext(ctx context.Context, c net.Conn) context.Context {
+	if lc, ok := c.(*limitConn); ok {
+		c = lc.Conn
+	}
+	if pc, ok := c.(*peerConn); ok {
+		return context.WithValue(ctx, peerCredentialsKey{}, pc.creds)
+	}
+	return ctx
+}
+
+// lookupID resolves a numeric ID or a user or group name, returning -1 for
+// an empty value.
+func lookupID(val string, isUser bool) (int, error) {
+	if val == "" {
+		return -1, nil
+	}
+	if id, err := strconv.Atoi(val); err == nil && id >= 0 {
+		return id, nil
+	}
+	id := ""
+	if isUser {
+		u, err := user.Lookup(val)
+		if err != nil {
+			return -1, fmt.Errorf("%w: %v", ErrInvalidSocketConfig, err)
+		}
+		id = u.Uid
+	} else {
+		g, err := user.LookupGroup(val)
+		if err != nil {
+			return -1, fmt.Errorf("%w: %v", ErrInvalidSocketConfig, err)
+		}
+		id = g.Gid
+	}
+	return strconv.Atoi(id)
+}
+
+func parseIDs(val string) ([]uint32, error) {
+	if val == "" {
+		return nil, nil
+	}
+	var ids []uint32
+	for _, s := range strings.Split(val, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(s), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%w: id %q", ErrInvalidSocketConfig, s)
+		}
+		ids = append(ids, uint32(id))
+	}
+	return ids, nil
+}
@@ -0,0 +1,27 @@
+package praetorian
+
+import (
+	"net"
+	"syscall"
+)
+
+const peerCredentialsSupported = true
+
+// peerCredentials reads SO_PEERCRED from the connection.
+func peerCredentials(c *net.UnixConn) (PeerCredentials, error) {
+	raw, err := c.SyscallConn()
+	if err != nil {
+		return PeerCredentials{}, err
+	}
+	var cred *syscall.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return PeerCredentials{}, err
+	}
+	if credErr != nil {
+		return PeerCredentials{}, credErr
+	}
+	return PeerCredentials{PID: cred.Pid, UID: cred.Uid, GID: cred.Gid}, nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package praetorian
+
+import "net"
+
+const peerCredentialsSupported = false
+
+func peerCredentials(c *net.UnixConn) (PeerCredentials, error) {
+	return PeerCredentials{}, ErrPeerCredentialsUnsupported
+}
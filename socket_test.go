@@ -0,0 +1,187 @@
+package praetorian_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+func TestParseSocketConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    *praetorian.SocketConfig
+		wantErr error
+	}{
+		{
+			name: "unset",
+			env:  map[string]string{},
+		},
+		{
+			name: "defaults",
+			env:  map[string]string{praetorian.EnvSocket: "/run/praetorian.sock"},
+			want: &praetorian.SocketConfig{Path: "/run/praetorian.sock", Mode: 0o600, UID: -1, GID: -1},
+		},
+		{
+			name: "mode, owner and peers",
+			env: map[string]string{
+				praetorian.EnvSocket:      "/run/praetorian.sock",
+				praetorian.EnvSocketMode:  "660",
+				praetorian.EnvSocketOwner: "1000:2000",
+				praetorian.EnvPeerUIDs:    "1000, 1001",
+				praetorian.EnvPeerGIDs:    "2000",
+			},
+			want: &praetorian.SocketConfig{
+				Path:      "/run/praetorian.sock",
+				Mode:      0o660,
+				UID:       1000,
+				GID:       2000,
+				AllowUIDs: []uint32{1000, 1001},
+				AllowGIDs: []uint32{2000},
+			},
+		},
+		{
+			name: "group only",
+			env: map[string]string{
+				praetorian.EnvSocket:      "/run/praetorian.sock",
+				praetorian.EnvSocketOwner: ":2000",
+			},
+			want: &praetorian.SocketConfig{Path: "/run/praetorian.sock", Mode: 0o600, UID: -1, GID: 2000},
+		},
+		{
+			name: "invalid mode",
+			env: map[string]string{
+				praetorian.EnvSocket:     "/run/praetorian.sock",
+				praetorian.EnvSocketMode: "rw",
+			},
+			wantErr: praetorian.ErrInvalidSocketConfig,
+		},
+		{
+			name: "invalid peer",
+			env: map[string]string{
+				praetorian.EnvSocket:   "/run/praetorian.sock",
+				praetorian.EnvPeerUIDs: "-1",
+			},
+			wantErr: praetorian.ErrInvalidSocketConfig,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := praetorian.ParseSocketConfig(func(k string) string { return tt.env[k] })
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseSocketConfig() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+			if tt.want == nil || got == nil {
+				if got != tt.want {
+					t.Errorf("ParseSocketConfig() = %+v, want = %+v", got, tt.want)
+				}
+				return
+			}
+			if got.Path != tt.want.Path || got.Mode != tt.want.Mode || got.UID != tt.want.UID || got.GID != tt.want.GID ||
+				!slices.Equal(got.AllowUIDs, tt.want.AllowUIDs) || !slices.Equal(got.AllowGIDs, tt.want.AllowGIDs) {
+				t.Errorf("ParseSocketConfig() = %+v, want = %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials are only supported on linux")
+	}
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	tests := []struct {
+		name      string
+		allowUIDs []uint32
+		wantOK    bool
+	}{
+		{name: "unrestricted", wantOK: true},
+		{name: "allowed peer", allowUIDs: []uint32{uint32(os.Getuid())}, wantOK: true},
+		{name: "rejected peer", allowUIDs: []uint32{uint32(os.Getuid()) + 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "praetorian.sock")
+			ln, err := praetorian.ListenUnix(&praetorian.SocketConfig{
+				Path:      path,
+				Mode:      0o660,
+				UID:       -1,
+				GID:       -1,
+				AllowUIDs: tt.allowUIDs,
+			})
+			if err != nil {
+				t.Fatalf("ListenUnix() error = %v", err)
+			}
+
+			fi, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("ListenUnix() did not create the socket: %v", err)
+			}
+			if fi.Mode().Perm() != 0o660 {
+				t.Errorf("ListenUnix() mode = %o, want %o", fi.Mode().Perm(), 0o660)
+			}
+
+			srv := &http.Server{
+				Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					p, ok := praetorian.PeerCredentialsFromContext(r.Context())
+					if !ok {
+						w.WriteHeader(http.StatusUnauthorized)
+						return
+					}
+					fmt.Fprint(w, p.UID)
+				}),
+				ConnContext: praetorian.NewServer(&MockKeystore{}).ConnContext,
+			}
+			go srv.Serve(ln)
+			defer srv.Close()
+
+			client := &http.Client{Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", path)
+				},
+			}}
+			res, err := client.Get("http://praetorian/")
+			if !tt.wantOK {
+				if err == nil {
+					res.Body.Close()
+					t.Fatal("ListenUnix() accepted a connection from a rejected peer")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer res.Body.Close()
+			b, _ := io.ReadAll(res.Body)
+			if want := fmt.Sprint(os.Getuid()); string(b) != want {
+				t.Errorf("PeerCredentialsFromContext() uid = %q, want %q", b, want)
+			}
+		})
+	}
+}
+
+func TestListenUnix_ExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "praetorian.sock")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := praetorian.ListenUnix(&praetorian.SocketConfig{Path: path, UID: -1, GID: -1})
+	if !errors.Is(err, praetorian.ErrInvalidSocketConfig) {
+		t.Errorf("ListenUnix() error = %v, wantErr = %v", err, praetorian.ErrInvalidSocketConfig)
+	}
+}
@@ -0,0 +1,231 @@
+package praetorian
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// TTLV item types used by the KMIP subset.
+const (
+	TTLVStructure   byte = 0x01
+	TTLVInteger     byte = 0x02
+	TTLVLongInteger byte = 0x03
+	TTLVEnumeration byte = 0x05
+	TTLVBoolean     byte = 0x06
+	TTLVTextString  byte = 0x07
+	TTLVByteString  byte = 0x08
+	TTLVDateTime    byte = 0x09
+	TTLVInterval    byte = 0x0A
+)
+
+const (
+	ttlvHeaderLength = 8
+	ttlvMaxDepth     = 16
+)
+
+var ErrInvalidTTLV = errors.New("invalid TTLV encoding")
+
+// TTLV is a KMIP tag, type, length and value item. Structures hold their
+// children in Items and every other type holds its unpadded value.
+type TTLV struct {
+	Tag   uint32
+	Type  byte
+	Value []byte
+	Items []TTLV
+}
+
+// NewTTLVStructure returns a structure containing the items.
+func NewTTLVStructure(tag uint32, items ...TTLV) TTLV {
+	return TTLV{Tag: tag, Type: TTLVStructure, Items: items}
+}
+
+// NewTTLVInteger returns a 32-bit integer item.
+func NewTTLVInteger(tag uint32, v int32) TTLV {
+	return TTLV{Tag: tag, Type: TTLVInteger, Value: binary.BigEndian.AppendUint32(nil, uint32(v))}
+}
+
+// NewTTLVEnumeration returns an enumeration item.
+func NewTTLVEnumeration(tag uint32, v uint32) TTLV {
+	return TTLV{Tag: tag, Type: TTLVEnumeration, Value: binary.BigEndian.AppendUint32(nil, v)}
+}
+
+// NewTTLVText returns a UTF-8 text string item.
+func NewTTLVText(tag uint32, s string) TTLV {
+	return TTLV{Tag: tag, Type: TTLVTextString, Value: []byte(s)}
+}
+
+// NewTTLVBytes returns a byte string item.
+func NewTTLVBytes(tag uint32, b []byte) TTLV {
+	return TTLV{Tag: tag, Type: TTLVByteString, Value: b}
+}
+
+// NewTTLVDateTime returns a date-time item with second precision.
+func NewTTLVDateTime(tag uint32, t time.Time) TTLV {
+	return TTLV{Tag: tag, Type: TTLVDateTime, Value: binary.BigEndian.AppendUint64(nil, uint64(t.Unix()))}
+}
+
+// Find returns the first child item with the given tag.
+func (t TTLV) Find(tag uint32) (TTLV, bool) {
+	for _, item := range t.Items {
+		if item.Tag == tag {
+			return item, true
+		}
+	}
+	return TTLV{}, false
+}
+
+// FindAll returns every child item with the given tag.
+func (t TTLV) FindAll(tag uint32) []TTLV {
+	var items []TTLV
+	for _, item := range t.Items {
+		if item.Tag == tag {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Uint32 returns the value of an integer or enumeration item.
+func (t TTLV) Uint32() uint32 {
+	if len(t.Value) != 4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(t.Value)
+}
+
+// Text returns the value of a text string item.
+func (t TTLV) Text() string {
+	if t.Type != TTLVTextString {
+		return ""
+	}
+	return string(t.Value)
+}
+
+// Bytes returns the value of a byte string item.
+func (t TTLV) Bytes() []byte {
+	if t.Type != TTLVByteString {
+		return nil
+	}
+	return t.Value
+}
+
+// MarshalBinary encodes the item, padding every value to 8 bytes.
+func (t TTLV) MarshalBinary() ([]byte, error) {
+	return t.append(nil, 0)
+}
+
+func (t TTLV) append(b []byte, depth int) ([]byte, error) {
+	if depth > ttlvMaxDepth || t.Tag > 0xffffff {
+		return nil, ErrInvalidTTLV
+	}
+	b = append(b, byte(t.Tag>>16), byte(t.Tag>>8), byte(t.Tag), t.Type)
+	if t.Type != TTLVStructure {
+		if want := ttlvFixedLength(t.Type); want > 0 && len(t.Value) != want {
+			return nil, ErrInvalidTTLV
+		}
+		b = binary.BigEndian.AppendUint32(b, uint32(len(t.Value)))
+		b = append(b, t.Value...)
+		return append(b, make([]byte, ttlvPadding(len(t.Value)))...), nil
+	}
+
+	start := len(b)
+	b = append(b, 0, 0, 0, 0)
+	for _, item := range t.Items {
+		var err error
+		if b, err = item.append(b, depth+1); err != nil {
+			return nil, err
+		}
+	}
+	binary.BigEndian.PutUint32(b[start:], uint32(len(b)-start-4))
+	return b, nil
+}
+
+// UnmarshalBinary decodes a single item which must span the whole input.
+func (t *TTLV) UnmarshalBinary(b []byte) error {
+	n, err := t.parse(b, 0)
+	if err != nil {
+		return err
+	}
+	if n != len(b) {
+		return ErrInvalidTTLV
+	}
+	return nil
+}
+
+func (t *TTLV) parse(b []byte, depth int) (int, error) {
+	if depth > ttlvMaxDepth || len(b) < ttlvHeaderLength {
+		return 0, ErrInvalidTTLV
+	}
+	t.Tag = uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+	t.Type = b[3]
+	length := int(binary.BigEndian.Uint32(b[4:8]))
+	b = b[ttlvHeaderLength:]
+
+	if t.Type == TTLVStructure {
+		if length > len(b) || length%8 != 0 {
+			return 0, ErrInvalidTTLV
+		}
+		for body := b[:length]; len(body) > 0; {
+			var item TTLV
+			n, err := item.parse(body, depth+1)
+			if err != nil {
+				return 0, err
+			}
+			t.Items = append(t.Items, item)
+			body = body[n:]
+		}
+		return ttlvHeaderLength + length, nil
+	}
+
+	switch want := ttlvFixedLength(t.Type); {
+	case want < 0:
+		return 0, ErrInvalidTTLV
+	case want > 0 && length != want:
+		return 0, ErrInvalidTTLV
+	}
+	padded := length + ttlvPadding(length)
+	if padded > len(b) {
+		return 0, ErrInvalidTTLV
+	}
+	t.Value = append([]byte(nil), b[:length]...)
+	return ttlvHeaderLength + padded, nil
+}
+
+// ReadTTLV reads the next encoded item from r, rejecting items longer than
+// max bytes.
+func ReadTTLV(r io.Reader, max int) ([]byte, error) {
+	b := make([]byte, ttlvHeaderLength)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	length := int(binary.BigEndian.Uint32(b[4:8]))
+	length += ttlvPadding(length)
+	if length > max-ttlvHeaderLength {
+		return nil, ErrInvalidTTLV
+	}
+	b = append(b, make([]byte, length)...)
+	if _, err := io.ReadFull(r, b[ttlvHeaderLength:]); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// ttlvFixedLength returns the length of fixed size types, 0 for variable
+// length types and -1 for types which are not supported.
+func ttlvFixedLength(typ byte) int {
+	switch typ {
+	case TTLVInteger, TTLVEnumeration, TTLVInterval:
+		return 4
+	case TTLVLongInteger, TTLVBoolean, TTLVDateTime:
+		return 8
+	case TTLVTextString, TTLVByteString:
+		return 0
+	}
+	return -1
+}
+
+func ttlvPadding(n int) int {
+	return (8 - n%8) % 8
+}
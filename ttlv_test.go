@@ -0,0 +1,92 @@
+package praetorian_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/karlbateman/praetorian"
+)
+
+func TestTTLV_MarshalBinary(t *testing.T) {
+	tests := []struct {
+		name string
+		item praetorian.TTLV
+		want string
+	}{
+		{
+			name: "integer",
+			item: praetorian.NewTTLVInteger(0x420020, 8),
+			want: "42002002000000040000000800000000",
+		},
+		{
+			name: "enumeration",
+			item: praetorian.NewTTLVEnumeration(0x420020, 255),
+			want: "4200200500000004000000ff00000000",
+		},
+		{
+			name: "text string",
+			item: praetorian.NewTTLVText(0x420020, "Hello World"),
+			want: "420020070000000b48656c6c6f20576f726c640000000000",
+		},
+		{
+			name: "byte string",
+			item: praetorian.NewTTLVBytes(0x420020, []byte{1, 2, 3}),
+			want: "42002008000000030102030000000000",
+		},
+		{
+			name: "structure",
+			item: praetorian.NewTTLVStructure(0x420020,
+				praetorian.NewTTLVEnumeration(0x420004, 254),
+				praetorian.NewTTLVInteger(0x420005, 255),
+			),
+			want: "42002001000000204200040500000004000000fe000000004200050200000004000000ff00000000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := tt.item.MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary() error = %v", err)
+			}
+			if got := hex.EncodeToString(b); got != tt.want {
+				t.Errorf("MarshalBinary() = %s, want %s", got, tt.want)
+			}
+
+			var item praetorian.TTLV
+			if err := item.UnmarshalBinary(b); err != nil {
+				t.Fatalf("UnmarshalBinary() error = %v", err)
+			}
+			again, _ := item.MarshalBinary()
+			if !bytes.Equal(again, b) {
+				t.Errorf("UnmarshalBinary() did not round trip: %x", again)
+			}
+		})
+	}
+}
+
+func TestTTLV_UnmarshalBinary_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "short header", data: "420020020000"},
+		{name: "integer length", data: "42002002000000080000000800000000"},
+		{name: "missing padding", data: "420020070000000b48656c6c6f20576f726c64"},
+		{name: "unsupported type", data: "42002004000000080000000000000000"},
+		{name: "structure overflow", data: "4200200100000020"},
+		{name: "trailing data", data: "4200200200000004000000080000000000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := hex.DecodeString(tt.data)
+			var item praetorian.TTLV
+			if err := item.UnmarshalBinary(b); !errors.Is(err, praetorian.ErrInvalidTTLV) {
+				t.Errorf("UnmarshalBinary() error = %v, wantErr = %v", err, praetorian.ErrInvalidTTLV)
+			}
+		})
+	}
+}